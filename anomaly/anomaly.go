@@ -0,0 +1,355 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/report"
+)
+
+// Reason tells why a tick was quarantined.
+type Reason string
+
+const (
+	Unparseable Reason = "unparseable"
+	Zero        Reason = "zero"
+	Jump        Reason = "jump"
+)
+
+// Rule decides when an asset's move is a spike worth an alert.
+type Rule struct {
+	// Move is the percent move within MoveWindow that counts as a spike
+	Move       float64
+	MoveWindow time.Duration
+	// ZScore is how many standard deviations a tick's return must be from
+	// the recent returns, for returns of at least MinMove percent
+	ZScore  float64
+	MinMove float64
+}
+
+// DefaultRule applies to assets without a rule of their own.
+var DefaultRule = Rule{Move: 3, MoveWindow: 15 * time.Minute, ZScore: 4, MinMove: 1}
+
+// Config tunes a Detector.
+type Config struct {
+	Default Rule
+	// Rules override Default per asset key
+	Rules map[string]Rule
+
+	// Samples is how many recent returns the z-score is computed over
+	Samples int
+	// JumpFactor is the ratio to the last good value at which a tick is
+	// quarantined as a data error rather than treated as a move
+	JumpFactor float64
+	// ConfirmTicks is how many further ticks a spike must hold before it is alerted
+	ConfirmTicks int
+	// ReleaseTicks is how many ticks in a row a jumped level must repeat
+	// before it is accepted as real, for example after a redenomination
+	ReleaseTicks int
+	// Cooldown is the minimum time between two alerts for one asset
+	Cooldown time.Duration
+}
+
+// DefaultConfig returns the detector settings used by the bot, with wider
+// thresholds for crypto.
+func DefaultConfig() Config {
+	crypto := Rule{Move: 5, MoveWindow: 30 * time.Minute, ZScore: 4, MinMove: 2}
+	return Config{
+		Default:      DefaultRule,
+		Rules:        map[string]Rule{"btc": crypto, "eth": crypto},
+		Samples:      30,
+		JumpFactor:   10,
+		ConfirmTicks: 1,
+		ReleaseTicks: 5,
+		Cooldown:     30 * time.Minute,
+	}
+}
+
+// ParseRules parses per-asset rules such as "usd=3%/15m,btc=6%/1h/z5" on top
+// of base: a percent move, the window it happens in and optionally a z-score.
+func ParseRules(spec string, base Rule) (map[string]Rule, error) {
+	rules := make(map[string]Rule)
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(field, "=")
+		asset, found := price.FindAsset(key)
+		if !ok || !found {
+			return nil, fmt.Errorf("invalid rule %q: expected <asset>=<move>%%/<window>", field)
+		}
+
+		parts := strings.Split(value, "/")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid rule %q: expected <asset>=<move>%%/<window>", field)
+		}
+		rule := base
+		move, err := strconv.ParseFloat(strings.TrimSuffix(parts[0], "%"), 64)
+		if err != nil || move <= 0 {
+			return nil, fmt.Errorf("invalid rule %q: bad move %q", field, parts[0])
+		}
+		window, err := time.ParseDuration(parts[1])
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid rule %q: bad window %q", field, parts[1])
+		}
+		rule.Move, rule.MoveWindow = move, window
+		if len(parts) == 3 {
+			z, err := strconv.ParseFloat(strings.TrimPrefix(parts[2], "z"), 64)
+			if err != nil || z <= 0 {
+				return nil, fmt.Errorf("invalid rule %q: bad z-score %q", field, parts[2])
+			}
+			rule.ZScore = z
+		}
+		rules[asset.Key] = rule
+	}
+	return rules, nil
+}
+
+// Tick is a quarantined price.
+type Tick struct {
+	Asset  price.Asset
+	Time   time.Time
+	Raw    string
+	Reason Reason
+}
+
+// Spike is a confirmed sharp move of an asset.
+type Spike struct {
+	Asset   price.Asset
+	From    time.Time // when the move started
+	Time    time.Time // when it was confirmed
+	Base    price.Decimal
+	Value   price.Decimal
+	Percent float64
+	ZScore  float64
+}
+
+// Message renders the spike as a Telegram HTML alert post.
+func (s Spike) Message() string {
+	title := "ا🚨 <b>جهش قیمت</b>"
+	if s.Percent < 0 {
+		title = "ا🚨 <b>افت شدید قیمت</b>"
+	}
+	minutes := int(math.Max(1, math.Round(s.Time.Sub(s.From).Minutes())))
+	return fmt.Sprintf("%s\n\nا%s %s %s در %d دقیقه\nا   از %s به <b>%s</b> %s",
+		title, s.Asset.Emoji, s.Asset.Name, report.FormatChange(s.Percent), minutes,
+		s.Base.Format(2), s.Value.Format(2), s.Asset.Unit.Label())
+}
+
+// Result is the outcome of one observation.
+type Result struct {
+	// Values holds the accepted value of every asset, ready to be recorded
+	Values      map[string]price.Decimal
+	Quarantined []Tick
+	Spikes      []Spike
+}
+
+type sample struct {
+	time  time.Time
+	value price.Decimal
+}
+
+// state is what the detector remembers about one asset.
+type state struct {
+	last    price.Detail // last accepted entry, put back over quarantined ticks
+	samples []sample     // accepted values covering the move window, oldest first
+	returns []float64    // recent tick returns in percent
+
+	suspect      price.Decimal // jumped level seen suspectTicks times in a row
+	suspectTicks int
+
+	pending      *Spike
+	pendingTicks int
+	lastAlert    time.Time
+}
+
+// Detector watches the price stream for spikes and bad data. It is not safe
+// for concurrent use.
+type Detector struct {
+	cfg    Config
+	assets map[string]*state
+}
+
+// New returns a detector using cfg.
+func New(cfg Config) *Detector {
+	return &Detector{cfg: cfg, assets: make(map[string]*state)}
+}
+
+func (d *Detector) rule(asset string) Rule {
+	if rule, ok := d.cfg.Rules[asset]; ok {
+		return rule
+	}
+	return d.cfg.Default
+}
+
+// Observe checks the snapshot c taken at t. Quarantined ticks are replaced in
+// c with the asset's last good entry, or blanked before there is one, so
+// they are never published.
+func (d *Detector) Observe(t time.Time, c *price.CurrentData) Result {
+	result := Result{Values: make(map[string]price.Decimal)}
+	for _, asset := range price.Assets {
+		detail := asset.Detail(c)
+		if detail.Price == "" {
+			continue
+		}
+
+		st := d.assets[asset.Key]
+		if st == nil {
+			st = &state{}
+			d.assets[asset.Key] = st
+		}
+
+		value, reason := d.check(asset, st, c)
+		if reason != "" {
+			result.Quarantined = append(result.Quarantined, Tick{Asset: asset, Time: t, Raw: detail.Price, Reason: reason})
+			// with no good entry yet the asset is blanked, and left off
+			// the boards, rather than published as zero or garbage
+			asset.SetDetail(c, st.last)
+			continue
+		}
+
+		if spike, ok := d.accept(asset, st, t, value); ok {
+			result.Spikes = append(result.Spikes, spike)
+		}
+		st.last = detail
+		result.Values[asset.Key] = value
+	}
+	return result
+}
+
+// Replay feeds a recorded value of asset, accepted when it was observed,
+// and reports a spike the way Observe does. Values must come in time order.
+func (d *Detector) Replay(asset price.Asset, t time.Time, value price.Decimal) (Spike, bool) {
+	st := d.assets[asset.Key]
+	if st == nil {
+		st = &state{}
+		d.assets[asset.Key] = st
+	}
+	return d.accept(asset, st, t, value)
+}
+
+// check validates the asset's tick, returning why it is bad, if it is.
+func (d *Detector) check(asset price.Asset, st *state, c *price.CurrentData) (price.Decimal, Reason) {
+	value, err := asset.Value(c)
+	if err != nil {
+		return price.Decimal{}, Unparseable
+	}
+	if value.Sign() <= 0 {
+		return price.Decimal{}, Zero
+	}
+	if len(st.samples) == 0 {
+		return value, ""
+	}
+
+	last := st.samples[len(st.samples)-1].value
+	ratio := value.Float64() / last.Float64()
+	if ratio < d.cfg.JumpFactor && ratio > 1/d.cfg.JumpFactor {
+		st.suspectTicks = 0
+		return value, ""
+	}
+
+	// a jumped level that keeps coming back is real: start over from it
+	if st.suspectTicks > 0 && math.Abs(value.PercentChange(st.suspect)) < 1 {
+		st.suspectTicks++
+	} else {
+		st.suspect, st.suspectTicks = value, 1
+	}
+	if st.suspectTicks >= d.cfg.ReleaseTicks {
+		*st = state{lastAlert: st.lastAlert}
+		return value, ""
+	}
+	return price.Decimal{}, Jump
+}
+
+// accept adds a good value to the asset's window and reports a spike once
+// it is confirmed.
+func (d *Detector) accept(asset price.Asset, st *state, t time.Time, value price.Decimal) (Spike, bool) {
+	rule := d.rule(asset.Key)
+
+	var tickReturn, z float64
+	if n := len(st.samples); n > 0 {
+		tickReturn = value.PercentChange(st.samples[n-1].value)
+		z = zScore(st.returns, tickReturn)
+		st.returns = append(st.returns, tickReturn)
+		if len(st.returns) > d.cfg.Samples {
+			st.returns = st.returns[len(st.returns)-d.cfg.Samples:]
+		}
+	}
+
+	st.samples = append(st.samples, sample{t, value})
+	// keep the last sample at or before the window start as the move base
+	cutoff := t.Add(-rule.MoveWindow)
+	drop := 0
+	for drop+1 < len(st.samples) && !st.samples[drop+1].time.After(cutoff) {
+		drop++
+	}
+	st.samples = st.samples[drop:]
+	base := st.samples[0]
+	move := value.PercentChange(base.value)
+
+	if st.pending != nil {
+		p := st.pending
+		if held := value.PercentChange(p.Base); held*p.Percent > 0 && math.Abs(held) >= math.Abs(p.Percent)/2 {
+			st.pendingTicks++
+			p.Time, p.Value, p.Percent = t, value, held
+		} else {
+			st.pending = nil
+		}
+	} else if t.Sub(st.lastAlert) >= d.cfg.Cooldown {
+		isMove := math.Abs(move) >= rule.Move
+		isOutlier := math.Abs(z) >= rule.ZScore && math.Abs(tickReturn) >= rule.MinMove
+		if isMove || isOutlier {
+			from, fromValue, percent := base.time, base.value, move
+			if !isMove {
+				previous := st.samples[len(st.samples)-2]
+				from, fromValue, percent = previous.time, previous.value, tickReturn
+			}
+			st.pending = &Spike{Asset: asset, From: from, Time: t, Base: fromValue, Value: value, Percent: percent, ZScore: z}
+			st.pendingTicks = 0
+		}
+	}
+
+	if st.pending == nil || st.pendingTicks < d.cfg.ConfirmTicks {
+		return Spike{}, false
+	}
+	spike := *st.pending
+	st.pending = nil
+	st.lastAlert = t
+	return spike, true
+}
+
+// minReturns is how many returns zScore needs before it judges a move.
+const minReturns = 10
+
+// zScore is how many standard deviations r is from the mean of returns. It is
+// zero until enough returns are known, and infinite when they never varied.
+func zScore(returns []float64, r float64) float64 {
+	if len(returns) < minReturns {
+		return 0
+	}
+
+	var mean float64
+	for _, x := range returns {
+		mean += x
+	}
+	mean /= float64(len(returns))
+
+	var variance float64
+	for _, x := range returns {
+		variance += (x - mean) * (x - mean)
+	}
+	std := math.Sqrt(variance / float64(len(returns)-1))
+	if std == 0 {
+		if r == mean {
+			return 0
+		}
+		return math.Inf(int(math.Copysign(1, r-mean)))
+	}
+	return (r - mean) / std
+}
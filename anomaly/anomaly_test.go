@@ -0,0 +1,188 @@
+package anomaly
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/price"
+)
+
+// snapshot builds price data with the given raw tgju prices.
+func snapshot(raw map[string]string) *price.CurrentData {
+	c := &price.CurrentData{}
+	for key, p := range raw {
+		asset, _ := price.FindAsset(key)
+		asset.SetDetail(c, price.Detail{Price: p})
+	}
+	return c
+}
+
+func TestDetector_Quarantine(t *testing.T) {
+	d := New(DefaultConfig())
+	start := time.Date(2025, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	d.Observe(start, snapshot(map[string]string{"usd": "800,000"}))
+
+	testCases := []struct {
+		raw    string
+		reason Reason
+	}{
+		{"0", Zero},
+		{"N/A", Unparseable},
+		{"8,000,000", Jump},
+		{"70,000", Jump},
+	}
+	for i, tc := range testCases {
+		c := snapshot(map[string]string{"usd": tc.raw})
+		result := d.Observe(start.Add(time.Duration(i+1)*time.Minute), c)
+
+		if len(result.Quarantined) != 1 || result.Quarantined[0].Reason != tc.reason {
+			t.Errorf("%s: expected quarantine for %s, got %v", tc.raw, tc.reason, result.Quarantined)
+		}
+		if _, ok := result.Values["usd"]; ok {
+			t.Errorf("%s: expected quarantined value to be left out", tc.raw)
+		}
+		if c.Dollar.Price != "800,000" {
+			t.Errorf("%s: expected last good price to be put back, got %s", tc.raw, c.Dollar.Price)
+		}
+	}
+
+	result := d.Observe(start.Add(10*time.Minute), snapshot(map[string]string{"usd": "810,000"}))
+	if len(result.Quarantined) != 0 || result.Values["usd"].String() != "81000" {
+		t.Errorf("Expected a normal tick to pass, got %+v", result)
+	}
+}
+
+func TestDetector_QuarantineFirstTick(t *testing.T) {
+	d := New(DefaultConfig())
+	start := time.Date(2025, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	for i, raw := range []string{"0", "N/A"} {
+		c := snapshot(map[string]string{"usd": raw, "eur": "900,000"})
+		result := d.Observe(start.Add(time.Duration(i)*time.Minute), c)
+		if len(result.Quarantined) != 1 || result.Quarantined[0].Asset.Key != "usd" {
+			t.Errorf("%s: expected usd quarantined, got %v", raw, result.Quarantined)
+		}
+		if c.Dollar != (price.Detail{}) {
+			t.Errorf("%s: expected usd blanked with no good price to put back, got %+v", raw, c.Dollar)
+		}
+
+		p := price.Price{Current: *c}
+		if rows := p.Rows(nil); len(rows) != 1 || rows[0].Asset.Key != "eur" {
+			t.Errorf("%s: expected only eur on the board, got %+v", raw, rows)
+		}
+	}
+}
+
+func TestDetector_ReleaseRepeatedJump(t *testing.T) {
+	cfg := DefaultConfig()
+	d := New(cfg)
+	start := time.Date(2025, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	d.Observe(start, snapshot(map[string]string{"usd": "800,000"}))
+	for i := 1; i <= cfg.ReleaseTicks; i++ {
+		result := d.Observe(start.Add(time.Duration(i)*time.Minute), snapshot(map[string]string{"usd": "80,000"}))
+		accepted := len(result.Quarantined) == 0
+		if accepted != (i == cfg.ReleaseTicks) {
+			t.Errorf("Tick %d: accepted = %v", i, accepted)
+		}
+		if len(result.Spikes) != 0 {
+			t.Errorf("Tick %d: expected no spike for a released level", i)
+		}
+	}
+}
+
+func TestDetector_MoveSpike(t *testing.T) {
+	d := New(DefaultConfig())
+	start := time.Date(2025, 5, 6, 9, 0, 0, 0, time.UTC)
+	tick := func(minute int, raw string) Result {
+		return d.Observe(start.Add(time.Duration(minute)*time.Minute), snapshot(map[string]string{"usd": raw}))
+	}
+
+	for i := 0; i < 5; i++ {
+		tick(i, "800,000")
+	}
+	if result := tick(5, "840,000"); len(result.Spikes) != 0 {
+		t.Fatal("Expected the spike to wait for confirmation")
+	}
+	result := tick(6, "842,000")
+	if len(result.Spikes) != 1 {
+		t.Fatalf("Expected a confirmed spike, got %+v", result)
+	}
+
+	spike := result.Spikes[0]
+	if spike.Asset.Key != "usd" || spike.Base.String() != "80000" || spike.Value.String() != "84200" {
+		t.Errorf("Unexpected spike %+v", spike)
+	}
+	if spike.Percent < 5.24 || spike.Percent > 5.26 {
+		t.Errorf("Percent = %v; want 5.25", spike.Percent)
+	}
+	message := spike.Message()
+	for _, expected := range []string{"جهش قیمت", "دلار امریکا (5.25%🟢) در 6 دقیقه", "از 80,000 به <b>84,200</b> تومان"} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("Expected alert to contain %q, got %s", expected, message)
+		}
+	}
+
+	// further moves within the cooldown are not alerted again
+	tick(7, "900,000")
+	if result := tick(8, "910,000"); len(result.Spikes) != 0 {
+		t.Error("Expected no alert during the cooldown")
+	}
+}
+
+func TestDetector_RevertedMove(t *testing.T) {
+	d := New(DefaultConfig())
+	start := time.Date(2025, 5, 6, 9, 0, 0, 0, time.UTC)
+	for i, raw := range []string{"800,000", "800,000", "840,000", "800,000", "800,000"} {
+		if result := d.Observe(start.Add(time.Duration(i)*time.Minute), snapshot(map[string]string{"usd": raw})); len(result.Spikes) != 0 {
+			t.Errorf("Tick %d: expected no spike for a move that reverted", i)
+		}
+	}
+}
+
+func TestDetector_ZScoreSpike(t *testing.T) {
+	d := New(DefaultConfig())
+	start := time.Date(2025, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	// bitcoin wobbles by 0.1% per tick, then jumps 2.5%: below the 5% move
+	// rule, but far outside its usual returns
+	value := 60000.0
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			value *= 1.001
+		} else {
+			value /= 1.001
+		}
+		d.Observe(start.Add(time.Duration(i)*time.Minute), snapshot(map[string]string{"btc": price.DecimalFromFloat(value).Round(2, price.RoundHalfUp).String()}))
+	}
+	d.Observe(start.Add(20*time.Minute), snapshot(map[string]string{"btc": "61,500"}))
+	result := d.Observe(start.Add(21*time.Minute), snapshot(map[string]string{"btc": "61,520"}))
+
+	if len(result.Spikes) != 1 {
+		t.Fatalf("Expected a z-score spike, got %+v", result)
+	}
+	if result.Spikes[0].ZScore < 4 {
+		t.Errorf("ZScore = %v; want at least 4", result.Spikes[0].ZScore)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("usd=2%/10m, BTC=6/1h/z5", DefaultRule)
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+	if r := rules["usd"]; r.Move != 2 || r.MoveWindow != 10*time.Minute || r.ZScore != DefaultRule.ZScore {
+		t.Errorf("Unexpected usd rule %+v", r)
+	}
+	if r := rules["btc"]; r.Move != 6 || r.MoveWindow != time.Hour || r.ZScore != 5 {
+		t.Errorf("Unexpected btc rule %+v", r)
+	}
+
+	for _, spec := range []string{"xyz=2%/10m", "usd", "usd=2%", "usd=-2%/10m", "usd=2%/soon", "usd=2%/10m/zz"} {
+		if _, err := ParseRules(spec, DefaultRule); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// Package backfill imports daily history from a provider or a CSV file into
+// the history store, a Jalali month at a time, so that a new store has
+// weeks of history from the start. Progress is saved after every month, so
+// an interrupted run resumes where it stopped.
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/market"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/utils"
+)
+
+// Source supplies daily bars; every price.Provider is one.
+type Source interface {
+	Name() string
+	History(ctx context.Context, asset price.Asset, from, to time.Time) ([]price.Bar, error)
+}
+
+// closeOffset is when in the day a bar's close is recorded. History keeps
+// a bar as its open at midnight and its close at the end of the day.
+const closeOffset = 24*time.Hour - time.Second
+
+// Options tunes a run.
+type Options struct {
+	// From and To are the first and last days to import
+	From, To utils.JDate
+	// Calendar tells which days should have a bar
+	Calendar *market.Calendar
+	// ProgressFile keeps the months already imported, none when empty
+	ProgressFile string
+	// RetryGaps imports months again that had gaps
+	RetryGaps bool
+}
+
+// Gap is a run of trading days without bars, within one month.
+type Gap struct {
+	From, To utils.JDate
+}
+
+func (g Gap) String() string {
+	if g.From == g.To {
+		return g.From.String()
+	}
+	return g.From.String() + ".." + g.To.String()
+}
+
+// Report is what a run did for one asset.
+type Report struct {
+	Asset string
+	// Months imported this run, and skipped as already imported
+	Months, Skipped int
+	// Bars the source returned, of which Existing fell on days the
+	// history already covers
+	Bars, Existing int
+	// Added is how many history points were written
+	Added int
+	Gaps  []Gap
+}
+
+// Run imports the bars of assets from source into store.
+func Run(ctx context.Context, store *history.Store, source Source, assets []price.Asset, opts Options, logger *slog.Logger) ([]Report, error) {
+	progress, err := loadProgress(opts.ProgressFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []Report
+	for _, asset := range assets {
+		report, err := runAsset(ctx, store, source, asset, opts, progress, logger)
+		reports = append(reports, report)
+		if err != nil {
+			return reports, fmt.Errorf("%s: %w", asset.Key, err)
+		}
+	}
+	return reports, nil
+}
+
+func runAsset(ctx context.Context, store *history.Store, source Source, asset price.Asset, opts Options, progress *Progress, logger *slog.Logger) (Report, error) {
+	report := Report{Asset: asset.Key}
+	key := source.Name() + ":" + asset.Key
+	done := progress.Assets[key]
+	if done == nil {
+		done = &AssetProgress{Months: make(map[string][]string)}
+		progress.Assets[key] = done
+	}
+	if done.Covered == nil {
+		done.Covered = make(map[string][2]string)
+	}
+
+	// a month imported only in part, such as the current one, is imported
+	// again over both runs' days once a run reaches past them
+	var pending []span
+	for m := opts.From.StartOfMonth(); !m.After(opts.To); m = m.AddMonths(1) {
+		days := span{later(m, opts.From), earlier(m.AddMonths(1).AddDays(-1), opts.To)}
+		gaps, imported := done.Months[month(m)]
+		covered, ok := done.covered(m)
+		if imported && !covered.first.After(days.first) && !covered.last.Before(days.last) && !(opts.RetryGaps && len(gaps) > 0) {
+			report.Skipped++
+			continue
+		}
+		if imported && ok {
+			days = span{earlier(days.first, covered.first), later(days.last, covered.last)}
+		}
+		pending = append(pending, days)
+	}
+	if len(pending) == 0 {
+		return report, nil
+	}
+
+	from, to := pending[0].first, pending[len(pending)-1].last
+	bars, err := source.History(ctx, asset, from.Time(), to.AddDays(1).Time())
+	if err != nil {
+		return report, err
+	}
+	report.Bars = len(bars)
+
+	for _, days := range pending {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		m, first, last := days.first.StartOfMonth(), days.first, days.last
+
+		var points []history.Point
+		have := make(map[utils.JDate]bool)
+		for _, bar := range bars {
+			if bar.Date.Before(first) || bar.Date.After(last) || have[bar.Date] {
+				continue
+			}
+			have[bar.Date] = true
+			start := bar.Date.Time()
+			if recorded(store, asset, bar.Date) {
+				report.Existing++
+				continue
+			}
+			points = append(points,
+				history.Point{Time: start, Value: bar.Open},
+				history.Point{Time: start.Add(closeOffset), Value: bar.Close})
+		}
+
+		added, err := store.Import(asset.Key, points)
+		if err != nil {
+			return report, err
+		}
+		gaps := findGaps(opts.Calendar, first, last, func(d utils.JDate) bool {
+			return have[d] || recorded(store, asset, d)
+		})
+		report.Months++
+		report.Added += added
+		report.Gaps = append(report.Gaps, gaps...)
+
+		done.Months[month(m)] = make([]string, len(gaps))
+		for i, gap := range gaps {
+			done.Months[month(m)][i] = gap.String()
+		}
+		done.Covered[month(m)] = [2]string{first.String(), last.String()}
+		if err := progress.save(opts.ProgressFile); err != nil {
+			return report, err
+		}
+		logger.Info("backfilled month", "source", source.Name(), "asset", asset.Key,
+			"month", month(m), "added", added, "gaps", len(gaps))
+	}
+	return report, nil
+}
+
+// recorded reports whether the bot recorded prices of asset on d itself,
+// which beat a daily bar. Points at midnight and at closeOffset may be a
+// backfill's own and don't count.
+func recorded(store *history.Store, asset price.Asset, d utils.JDate) bool {
+	start := d.Time()
+	for _, p := range store.Range(asset.Key, start, d.AddDays(1).Time()) {
+		if !p.Time.Equal(start) && !p.Time.Equal(start.Add(closeOffset)) {
+			return true
+		}
+	}
+	return false
+}
+
+// findGaps returns the runs of trading days from first to last, up to
+// yesterday, that aren't covered. Non-trading days don't break a run.
+func findGaps(calendar *market.Calendar, first, last utils.JDate, covered func(utils.JDate) bool) []Gap {
+	yesterday := utils.Now().Date().AddDays(-1)
+	var gaps []Gap
+	var open *Gap
+	for d := first; !d.After(last) && !d.After(yesterday); d = d.AddDays(1) {
+		switch {
+		case covered(d):
+			open = nil
+		case calendar.IsTradingDay(d):
+			if open == nil {
+				gaps = append(gaps, Gap{From: d})
+				open = &gaps[len(gaps)-1]
+			}
+			open.To = d
+		}
+	}
+	return gaps
+}
+
+func month(d utils.JDate) string {
+	return d.Format("yyyy/MM")
+}
+
+func later(a, b utils.JDate) utils.JDate {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func earlier(a, b utils.JDate) utils.JDate {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// Progress is the months imported per source and asset, with their gaps.
+type Progress struct {
+	Assets map[string]*AssetProgress `json:"assets"`
+}
+
+// AssetProgress holds the gaps of each imported month, keyed like "1404/02",
+// and the first and last day imported of it.
+type AssetProgress struct {
+	Months  map[string][]string  `json:"months"`
+	Covered map[string][2]string `json:"covered"`
+}
+
+// span is a run of days within one month.
+type span struct {
+	first, last utils.JDate
+}
+
+// covered returns the days of month m imported, the whole month when the
+// progress has no record of them.
+func (a *AssetProgress) covered(m utils.JDate) (span, bool) {
+	whole := span{m, m.AddMonths(1).AddDays(-1)}
+	days, ok := a.Covered[month(m)]
+	if !ok {
+		return whole, false
+	}
+	from, err := utils.ParseJDate(days[0])
+	if err != nil {
+		return whole, false
+	}
+	to, err := utils.ParseJDate(days[1])
+	if err != nil {
+		return whole, false
+	}
+	return span{from, to}, true
+}
+
+func loadProgress(path string) (*Progress, error) {
+	p := &Progress{Assets: make(map[string]*AssetProgress)}
+	if path == "" {
+		return p, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, a := range p.Assets {
+		if a.Months == nil {
+			a.Months = make(map[string][]string)
+		}
+	}
+	return p, nil
+}
+
+// save replaces the progress file, through a temporary file so a crash
+// leaves the previous progress intact.
+func (p *Progress) save(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,212 @@
+package backfill
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/market"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/utils"
+)
+
+func day(d int) utils.JDate {
+	return utils.JDate{Year: 1404, Month: 2, Day: d}
+}
+
+// fakeSource serves fixed bars and counts History calls.
+type fakeSource struct {
+	bars  []price.Bar
+	calls int
+}
+
+func (f *fakeSource) Name() string { return "fake" }
+
+func (f *fakeSource) History(ctx context.Context, asset price.Asset, from, to time.Time) ([]price.Bar, error) {
+	f.calls++
+	var bars []price.Bar
+	for _, bar := range f.bars {
+		if t := bar.Date.Time(); !t.Before(from) && t.Before(to) {
+			bars = append(bars, bar)
+		}
+	}
+	return bars, nil
+}
+
+func bar(d int, open, closing string) price.Bar {
+	return price.Bar{Date: day(d), Open: price.MustParseDecimal(open), Close: price.MustParseDecimal(closing)}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	store, err := history.Open(filepath.Join(dir, "history.jsonl"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	// the bot was running on 1404/02/14, so that day is left alone
+	live := day(14).Time().Add(10 * time.Hour)
+	if err := store.Record(live, map[string]price.Decimal{"usd": price.MustParseDecimal("81500")}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	usd, _ := price.FindAsset("usd")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts := Options{From: day(13), To: day(17), Calendar: market.Default(), ProgressFile: filepath.Join(dir, "progress.json")}
+	source := &fakeSource{bars: []price.Bar{bar(13, "80000", "80500"), bar(14, "81000", "81200"), bar(16, "82000", "82500")}}
+
+	reports, err := Run(context.Background(), store, source, []price.Asset{usd}, opts, logger)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	report := reports[0]
+	if report.Months != 1 || report.Bars != 3 || report.Existing != 1 || report.Added != 4 {
+		t.Errorf("Expected 1 month, 3 bars, 1 existing and 4 added, got %+v", report)
+	}
+	if expected := []Gap{{day(15), day(15)}, {day(17), day(17)}}; !reflect.DeepEqual(report.Gaps, expected) {
+		t.Errorf("Expected gaps %v, got %v", expected, report.Gaps)
+	}
+	if p, _ := store.At("usd", day(13).Time().Add(12*time.Hour)); p.Value.String() != "80000" {
+		t.Errorf("Expected the open in effect at noon, got %s", p.Value)
+	}
+	if p, _ := store.At("usd", day(14).Time().Add(23*time.Hour)); !p.Time.Equal(live) {
+		t.Errorf("Expected the live value to stand on 1404/02/14, got %+v", p)
+	}
+
+	// a second run finds the month done
+	reports, err = Run(context.Background(), store, source, []price.Asset{usd}, opts, logger)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if reports[0].Skipped != 1 || reports[0].Months != 0 || source.calls != 1 {
+		t.Errorf("Expected the month skipped without fetching, got %+v after %d calls", reports[0], source.calls)
+	}
+
+	// retrying gaps imports the month again once the source has them
+	source.bars = append(source.bars, bar(15, "81800", "81900"), bar(17, "82600", "83000"))
+	opts.RetryGaps = true
+	reports, err = Run(context.Background(), store, source, []price.Asset{usd}, opts, logger)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if reports[0].Months != 1 || reports[0].Added != 4 || len(reports[0].Gaps) != 0 {
+		t.Errorf("Expected the gaps filled, got %+v", reports[0])
+	}
+	progress, err := loadProgress(opts.ProgressFile)
+	if err != nil {
+		t.Fatalf("loadProgress failed: %v", err)
+	}
+	if gaps, ok := progress.Assets["fake:usd"].Months["1404/02"]; !ok || len(gaps) != 0 {
+		t.Errorf("Expected 1404/02 saved without gaps, got %v", progress.Assets["fake:usd"])
+	}
+}
+
+func TestRun_PartialMonth(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := history.Open(filepath.Join(dir, "history.jsonl"))
+	usd, _ := price.FindAsset("usd")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts := Options{From: day(13), To: day(14), Calendar: market.Default(), ProgressFile: filepath.Join(dir, "progress.json")}
+	source := &fakeSource{bars: []price.Bar{bar(13, "80000", "80500"), bar(14, "81000", "81200"), bar(16, "82000", "82500")}}
+
+	if _, err := Run(context.Background(), store, source, []price.Asset{usd}, opts, logger); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// a run reaching further into the month imports it again
+	opts.To = day(16)
+	reports, err := Run(context.Background(), store, source, []price.Asset{usd}, opts, logger)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if reports[0].Months != 1 || reports[0].Skipped != 0 || reports[0].Added != 2 {
+		t.Errorf("Expected the month imported again with the new day, got %+v", reports[0])
+	}
+	if p, _ := store.At("usd", day(16).Time().Add(12*time.Hour)); p.Value.String() != "82000" {
+		t.Errorf("Expected the new day imported, got %s", p.Value)
+	}
+
+	// and so does one starting earlier, while one within the days is skipped
+	for _, tc := range []struct {
+		from, to utils.JDate
+		skipped  int
+	}{
+		{day(10), day(16), 0},
+		{day(12), day(15), 1},
+	} {
+		opts.From, opts.To = tc.from, tc.to
+		reports, err := Run(context.Background(), store, source, []price.Asset{usd}, opts, logger)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if reports[0].Skipped != tc.skipped {
+			t.Errorf("%s..%s: expected %d months skipped, got %+v", tc.from, tc.to, tc.skipped, reports[0])
+		}
+	}
+	progress, _ := loadProgress(opts.ProgressFile)
+	if covered := progress.Assets["fake:usd"].Covered["1404/02"]; covered != [2]string{"1404/02/10", "1404/02/16"} {
+		t.Errorf("Expected 1404/02/10..16 covered, got %v", covered)
+	}
+}
+
+func TestOpenCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.csv")
+	data := "\uFEFFDate,Asset,Open,Close\n" +
+		"1404/02/16,usd,820000,825000\n" +
+		"2025-05-05,usd,815000,821000\n" +
+		"1404/02/16,usd,1,1\n" + // duplicate, dropped
+		"1404/02/16,sekee,,700000000\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := OpenCSV(path, "", price.Rial)
+	if err != nil {
+		t.Fatalf("OpenCSV failed: %v", err)
+	}
+	if c.Duplicates != 1 {
+		t.Errorf("Expected 1 duplicate, got %d", c.Duplicates)
+	}
+	if assets := c.Assets(); !reflect.DeepEqual(assets, []string{"usd", "sekee"}) {
+		t.Errorf("Expected usd and sekee, got %v", assets)
+	}
+
+	usd, _ := price.FindAsset("usd")
+	bars, _ := c.History(context.Background(), usd, day(1).Time(), day(31).Time())
+	if len(bars) != 2 || bars[0].Date != day(15) || bars[1].Date != day(16) {
+		t.Fatalf("Expected 1404/02/15 and 1404/02/16 in order, got %+v", bars)
+	}
+	if bars[1].Open.String() != "82000" || bars[1].Close.String() != "82500" {
+		t.Errorf("Expected rial converted to toman, got %+v", bars[1])
+	}
+
+	sekee, _ := price.FindAsset("sekee")
+	bars, _ = c.History(context.Background(), sekee, day(1).Time(), day(31).Time())
+	if len(bars) != 1 || bars[0].Open.String() != "70000000" || bars[0].High.String() != "70000000" {
+		t.Errorf("Expected missing columns to fall back to close, got %+v", bars)
+	}
+}
+
+func TestOpenCSV_Errors(t *testing.T) {
+	testCases := map[string]string{
+		"missing close": "date,open\n1404/02/16,1\n",
+		"no asset":      "date,close\n1404/02/16,1\n",
+		"unknown asset": "date,close,asset\n1404/02/16,1,xyz\n",
+		"bad date":      "date,close,asset\n1404/13/40,1,usd\n",
+		"bad price":     "date,close,asset\n1404/02/16,abc,usd\n",
+	}
+	for name, data := range testCases {
+		path := filepath.Join(t.TempDir(), "prices.csv")
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := OpenCSV(path, "", ""); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package backfill
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/utils"
+)
+
+// CSV is a source of daily prices read from a CSV file with a header row.
+// The columns, in any order, are date (Jalali like 1404/02/16 or
+// Gregorian like 2025-05-06), close (or price or value), optionally open,
+// high and low, and asset unless the file holds one asset.
+type CSV struct {
+	path string
+	bars map[string][]price.Bar
+	// Duplicates counts rows dropped for repeating an asset's date
+	Duplicates int
+}
+
+// OpenCSV reads the file at path. asset is the asset of rows without an
+// asset column, and unit the unit the prices are written in, each asset's
+// own unit when empty.
+func OpenCSV(path, asset string, unit price.Unit) (*CSV, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\uFEFF"))))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: reading header: %w", path, err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	column := func(names ...string) int {
+		for _, name := range names {
+			if i, ok := columns[name]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	dateCol, closeCol := column("date"), column("close", "price", "value")
+	openCol, highCol, lowCol, assetCol := column("open"), column("high"), column("low"), column("asset")
+	if dateCol < 0 || closeCol < 0 {
+		return nil, fmt.Errorf("%s: want date and close columns, got %v", path, header)
+	}
+	if assetCol < 0 && asset == "" {
+		return nil, fmt.Errorf("%s: no asset column, name the asset", path)
+	}
+
+	c := &CSV{path: path, bars: make(map[string][]price.Bar)}
+	seen := make(map[string]bool)
+	for line := 2; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		field := func(i int) string {
+			if i < 0 || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+
+		key := asset
+		if assetCol >= 0 && field(assetCol) != "" {
+			key = field(assetCol)
+		}
+		a, ok := price.FindAsset(key)
+		if !ok {
+			return nil, fmt.Errorf("%s line %d: unknown asset %q", path, line, key)
+		}
+		date, err := ParseDate(field(dateCol))
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		if seen[a.Key+date.String()] {
+			c.Duplicates++
+			continue
+		}
+		seen[a.Key+date.String()] = true
+
+		bar := price.Bar{Date: date}
+		closing := field(closeCol)
+		for _, f := range []struct {
+			value  *price.Decimal
+			column int
+		}{{&bar.Close, closeCol}, {&bar.Open, openCol}, {&bar.High, highCol}, {&bar.Low, lowCol}} {
+			raw := field(f.column)
+			if raw == "" {
+				raw = closing
+			}
+			if *f.value, err = convert(a, raw, unit); err != nil {
+				return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+			}
+		}
+		c.bars[a.Key] = append(c.bars[a.Key], bar)
+	}
+
+	for _, bars := range c.bars {
+		sort.Slice(bars, func(i, j int) bool { return bars[i].Date.Before(bars[j].Date) })
+	}
+	return c, nil
+}
+
+// convert parses raw, written in unit, into the asset's unit.
+func convert(asset price.Asset, raw string, unit price.Unit) (price.Decimal, error) {
+	value, err := price.ParseDecimal(raw)
+	if err != nil {
+		return price.Decimal{}, fmt.Errorf("invalid %s price %q: %w", asset.Key, raw, err)
+	}
+	if unit == "" || unit == asset.Unit {
+		return value, nil
+	}
+	money, err := price.Money{Amount: value, Unit: unit}.In(asset.Unit, price.Decimal{})
+	if err != nil {
+		return price.Decimal{}, fmt.Errorf("%s: %w", asset.Key, err)
+	}
+	return money.Amount.Normalize(), nil
+}
+
+// ParseDate parses a Jalali date like 1404/02/16 or a Gregorian date like
+// 2025-05-06, told apart by the year.
+func ParseDate(s string) (utils.JDate, error) {
+	d, err := utils.ParseJDate(s)
+	if err != nil {
+		return utils.JDate{}, err
+	}
+	if d.Year < 1700 {
+		return d, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day), utils.Tehran)
+	if err != nil {
+		return utils.JDate{}, fmt.Errorf("invalid date %q", s)
+	}
+	return utils.ToJalali(t), nil
+}
+
+func (c *CSV) Name() string {
+	return "csv:" + c.path
+}
+
+// Assets returns the keys of the assets in the file.
+func (c *CSV) Assets() []string {
+	var keys []string
+	for _, a := range price.Assets {
+		if len(c.bars[a.Key]) > 0 {
+			keys = append(keys, a.Key)
+		}
+	}
+	return keys
+}
+
+func (c *CSV) History(ctx context.Context, asset price.Asset, from, to time.Time) ([]price.Bar, error) {
+	var bars []price.Bar
+	for _, bar := range c.bars[asset.Key] {
+		if date := bar.Date.Time(); !date.Before(from) && date.Before(to) {
+			bars = append(bars, bar)
+		}
+	}
+	return bars, nil
+}
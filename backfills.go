@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/onionj/pricebot/backfill"
+	"github.com/onionj/pricebot/export"
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/market"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/utils"
+)
+
+const (
+	// how far back a backfill reaches when no start is given
+	DEFAULT_BACKFILL_DAYS = 365
+	// months already imported are recorded here, next to the history file,
+	// so a backfill can resume
+	DEFAULT_BACKFILL_PROGRESS_FILE = "backfill_progress.json"
+)
+
+// backfillCommand runs "pricebot backfill", which imports daily history from
+// tgju or a CSV file. It rewrites the history file, so it refuses to run
+// while the bot holds the file.
+func backfillCommand(ctx context.Context, args []string, historyFile string, calendar *market.Calendar, logger *slog.Logger, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	sourceName := flags.String("source", "tgju", "tgju or csv")
+	file := flags.String("file", "", "CSV file to import with -source csv")
+	fileAsset := flags.String("asset", "", "asset of a CSV file without an asset column")
+	unit := flags.String("unit", "", "unit the CSV prices are written in, toman or rial; default each asset's own")
+	assets := flags.String("assets", "", "comma separated assets; default every asset, or those in the CSV file")
+	from := flags.String("from", "", fmt.Sprintf("first day, Jalali like 1403/01/01 or Gregorian; default %d days ago", DEFAULT_BACKFILL_DAYS))
+	to := flags.String("to", "", "last day; default yesterday")
+	progressFile := flags.String("progress", filepath.Join(filepath.Dir(historyFile), DEFAULT_BACKFILL_PROGRESS_FILE), "progress file, empty to always start over")
+	retryGaps := flags.Bool("retry-gaps", false, "import months with gaps again")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	yesterday := utils.Now().Date().AddDays(-1)
+	opts := backfill.Options{
+		From:         yesterday.AddDays(-DEFAULT_BACKFILL_DAYS + 1),
+		To:           yesterday,
+		Calendar:     calendar,
+		ProgressFile: *progressFile,
+		RetryGaps:    *retryGaps,
+	}
+	var err error
+	if *from != "" {
+		if opts.From, err = backfill.ParseDate(*from); err != nil {
+			return err
+		}
+	}
+	if *to != "" {
+		if opts.To, err = backfill.ParseDate(*to); err != nil {
+			return err
+		}
+	}
+	if opts.To.Before(opts.From) {
+		return fmt.Errorf("-to %s is before -from %s", opts.To, opts.From)
+	}
+
+	var source backfill.Source
+	keys := *assets
+	switch *sourceName {
+	case "tgju":
+		tgju := &price.TGJU{}
+		tgju.SetLogger(logger)
+		source = tgju
+		if keys == "" {
+			keys = assetKeys()
+		}
+	case "csv":
+		csvUnit, err := export.ParseUnit(*unit)
+		if err != nil {
+			return err
+		}
+		if *file == "" {
+			return errors.New("-source csv needs -file")
+		}
+		csv, err := backfill.OpenCSV(*file, *fileAsset, csvUnit)
+		if err != nil {
+			return err
+		}
+		if csv.Duplicates > 0 {
+			logger.Warn("duplicate CSV rows dropped", "rows", csv.Duplicates)
+		}
+		source = csv
+		if keys == "" {
+			keys = strings.Join(csv.Assets(), ",")
+		}
+	default:
+		return fmt.Errorf("unknown source %q, want tgju or csv", *sourceName)
+	}
+	selected, err := export.ParseAssets(keys)
+	if err != nil {
+		return err
+	}
+
+	store, err := history.OpenExclusive(historyFile)
+	if errors.Is(err, history.ErrLocked) {
+		return fmt.Errorf("open history: %w; stop the bot first", err)
+	}
+	if err != nil {
+		return fmt.Errorf("open history: %w", err)
+	}
+	defer store.Close()
+	reports, err := backfill.Run(ctx, store, source, selected, opts, logger)
+	for _, r := range reports {
+		gaps := make([]string, len(r.Gaps))
+		for i, gap := range r.Gaps {
+			gaps[i] = gap.String()
+		}
+		fmt.Fprintf(stdout, "%s: %d months imported, %d skipped, %d bars, %d days already recorded, %d points added",
+			r.Asset, r.Months, r.Skipped, r.Bars, r.Existing, r.Added)
+		if len(gaps) > 0 {
+			fmt.Fprintf(stdout, ", gaps %s", strings.Join(gaps, " "))
+		}
+		fmt.Fprintln(stdout)
+	}
+	return err
+}
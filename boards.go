@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"slices"
+	"time"
+
+	"github.com/onionj/pricebot/subscription"
+	"github.com/onionj/pricebot/telegram"
+)
+
+// editBoards brings due private boards up to date, as many as the pacer's
+// budget allows; the rest wait for the next run. The boards are rendered
+// under b.mu and sent without it, so that slow Telegram calls don't hold up
+// the other jobs.
+func (b *bot) editBoards(ctx context.Context) error {
+	var errs []error
+	now := time.Now()
+	for _, edit := range b.dueBoards(now) {
+		if err := b.updateBoard(ctx, edit.sub, edit.text, now); err != nil {
+			errs = append(errs, fmt.Errorf("board of %d: %w", edit.sub.UserID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// boardEdit is a board to send with its new text.
+type boardEdit struct {
+	sub  subscription.Subscription
+	text string
+}
+
+// dueBoards renders the boards due at now whose text changed, taking an
+// edit from the pacer's budget for each.
+func (b *bot) dueBoards(now time.Time) []boardEdit {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.price.LastRefresh.IsZero() {
+		return nil
+	}
+	var edits []boardEdit
+	for _, sub := range b.pacer.Due(b.subscriptions.All(), now) {
+		text := subscription.Board(*b.price, sub)
+		if sub.MessageID != 0 && text == b.boardTexts[sub.UserID] {
+			b.pacer.Done(sub.UserID, now)
+			continue
+		}
+		if !b.pacer.Take(now) {
+			break
+		}
+		edits = append(edits, boardEdit{sub, text})
+	}
+	return edits
+}
+
+// updateBoard sends the board of sub and records the result under b.mu.
+// Subscriptions of users who blocked the bot are dropped.
+func (b *bot) updateBoard(ctx context.Context, sub subscription.Subscription, text string, now time.Time) error {
+	messageID, err := b.sendBoard(ctx, sub, text)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// the user may have unsubscribed or subscribed again while it was sent
+	current, ok := b.subscriptions.Get(sub.UserID)
+	if !ok || current.ChatID != sub.ChatID {
+		return nil
+	}
+	if telegram.IsBlocked(err) {
+		b.logger.Info("dropping subscription of unreachable user", "user_id", sub.UserID)
+		b.pacer.Forget(sub.UserID)
+		delete(b.boardTexts, sub.UserID)
+		return b.subscriptions.Delete(sub.UserID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if slices.Equal(current.Assets, sub.Assets) && current.Interval == sub.Interval {
+		b.boardTexts[sub.UserID] = text
+		b.pacer.Done(sub.UserID, now)
+	}
+	if messageID == 0 {
+		return nil
+	}
+	current.MessageID = messageID
+	current.PostedAt = time.Now()
+	return b.subscriptions.Put(current)
+}
+
+// sendBoard edits the board message of sub, posting a new one when there is
+// none yet or it was deleted. It returns the ID of a new message, zero when
+// the old one was edited.
+func (b *bot) sendBoard(ctx context.Context, sub subscription.Subscription, text string) (int, error) {
+	if sub.MessageID != 0 {
+		err := b.tel.UpdateMessageIn(ctx, sub.ChatID, text, sub.MessageID)
+		if err == nil || telegram.IsNotModified(err) {
+			return 0, nil
+		}
+		if !telegram.IsMessageGone(err) {
+			return 0, err
+		}
+	}
+	return b.tel.PostTo(ctx, sub.ChatID, text)
+}
+
+// subscribe handles "/subscribe [assets] [interval]".
+func (b *bot) subscribe(m telegram.Message, args []string) string {
+	assets, interval, err := subscription.Parse(args)
+	if err != nil {
+		return fmt.Sprintf("ا❗️ %s\nا   استفاده: <code>/subscribe usd,sekee,btc 2m</code>\nا   نمادها: %s", html.EscapeString(err.Error()), assetKeys())
+	}
+
+	sub, ok := b.subscriptions.Get(m.From.ID)
+	if !ok {
+		sub = subscription.Subscription{UserID: m.From.ID, Created: time.Now()}
+	}
+	sub.ChatID = m.Chat.ChatID()
+	sub.Assets = assets
+	sub.Interval = interval
+	if err := b.subscriptions.Put(sub); err != nil {
+		b.logger.Error("save subscription error", "error", err)
+		return "ا❗️ ثبت اشتراک ناموفق بود، دوباره تلاش کنید."
+	}
+	b.pacer.Forget(sub.UserID)
+	delete(b.boardTexts, sub.UserID)
+
+	return fmt.Sprintf("ا✅ تابلوی شخصی شما با %d دارایی فعال شد و هر %s بروزرسانی می‌شود.",
+		len(assets), subscription.FormatInterval(interval))
+}
+
+// unsubscribe handles "/unsubscribe".
+func (b *bot) unsubscribe(userID int64) string {
+	if _, ok := b.subscriptions.Get(userID); !ok {
+		return "ا❗️ اشتراک فعالی ندارید."
+	}
+	if err := b.subscriptions.Delete(userID); err != nil {
+		b.logger.Error("delete subscription error", "error", err)
+		return "ا❗️ لغو اشتراک ناموفق بود، دوباره تلاش کنید."
+	}
+	b.pacer.Forget(userID)
+	delete(b.boardTexts, userID)
+	return "ا🗑 اشتراک شما لغو شد؛ تابلو دیگر بروزرسانی نمی‌شود."
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onionj/pricebot/anomaly"
+	"github.com/onionj/pricebot/digest"
+	"github.com/onionj/pricebot/freshness"
+	"github.com/onionj/pricebot/health"
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/market"
+	"github.com/onionj/pricebot/portfolio"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/publish"
+	"github.com/onionj/pricebot/report"
+	"github.com/onionj/pricebot/scheduler"
+	"github.com/onionj/pricebot/state"
+	"github.com/onionj/pricebot/subscription"
+	"github.com/onionj/pricebot/telegram"
+	"github.com/onionj/pricebot/webhook"
+)
+
+// ALERT_PROVIDER_STALLED is the state key of the provider stall alert
+const ALERT_PROVIDER_STALLED = "provider-stalled"
+
+// names of the default jobs; SCHEDULE_<NAME> overrides a job's cron expression,
+// for example SCHEDULE_DAILY_REPORT="0 20 * * sat-thu"
+const (
+	JOB_REFRESH_PRICES     = "refresh-prices"
+	JOB_EDIT_LIVE_MESSAGE  = "edit-live-message"
+	JOB_POST_FRESH_MESSAGE = "post-fresh-message"
+	JOB_DAILY_REPORT       = "daily-report"
+	JOB_PERIOD_REPORTS     = "period-reports"
+	JOB_CLEANUP_HISTORY    = "cleanup-history"
+	JOB_EDIT_BOARDS        = "edit-boards"
+)
+
+// scheduleSlack absorbs timer drift when comparing elapsed time to a period.
+const scheduleSlack = 2 * time.Second
+
+// bot holds the state shared by the scheduled jobs.
+type bot struct {
+	// mu serializes jobs, which all read or write the price snapshot and
+	// message state; boards and command replies are sent without it
+	mu sync.Mutex
+
+	logger   *slog.Logger
+	price    *price.Price
+	tel      *telegram.Telegram
+	store    *history.Store
+	calendar *market.Calendar
+	monitor  *health.Monitor
+	detector *anomaly.Detector
+	fresh    *freshness.Model
+	state    state.Store
+
+	portfolios    *portfolio.Store
+	subscriptions *subscription.Store
+	pacer         *subscription.Pacer
+	boardTexts    map[int64]string // last text sent to each private board
+	webhooks      *webhook.Dispatcher
+	digests       []digest.Recipient
+	mailer        *digest.Mailer
+
+	chatID     string
+	chanelName string
+	proxyLink  string
+
+	// the live message shows changes of changeAssets over windows
+	windows      []report.Window
+	changeAssets []price.Asset
+
+	// outlets keep the live message, the first one in the Telegram channel
+	outlets []*outlet
+	primary publish.Publisher
+
+	lastEdit time.Time
+	stalled  bool // no asset has updated for PROVIDER_STALL_AFTER
+}
+
+// outlet is a publisher of the live message and its edit state.
+type outlet struct {
+	publish.Publisher
+	pausedUntil time.Time // message edits back off after a failure
+	freshDue    bool      // a fresh live message should replace the current one
+}
+
+// setPublishers makes publishers the outlets of the live message; the first
+// is the Telegram channel.
+func (b *bot) setPublishers(publishers ...publish.Publisher) {
+	b.outlets = nil
+	for _, p := range publishers {
+		b.outlets = append(b.outlets, &outlet{Publisher: p})
+	}
+	b.primary = publishers[0]
+}
+
+// jobs returns the default job set.
+func (b *bot) jobs() []scheduler.Job {
+	var postedAt time.Time
+	if b.tel.LastMessageTime > 0 {
+		postedAt = time.Unix(b.tel.LastMessageTime, 0)
+	}
+
+	jobs := []scheduler.Job{
+		{
+			Name:        JOB_REFRESH_PRICES,
+			Spec:        fmt.Sprintf("@every %ds", UPDATE_PRICE_PERIOD),
+			Run:         b.refreshPrices,
+			Immediately: true,
+		},
+		{
+			Name: JOB_EDIT_LIVE_MESSAGE,
+			Spec: fmt.Sprintf("@every %ds", UPDATE_MESSAGE_PERIOD),
+			Run:  b.editLiveMessage,
+		},
+		{
+			Name:    JOB_POST_FRESH_MESSAGE,
+			Spec:    fmt.Sprintf("@every %ds", NEW_MESSAGE_PERIOD),
+			Run:     b.postFreshMessage,
+			Missed:  scheduler.RunMissed,
+			LastRun: postedAt,
+		},
+		{
+			Name:        JOB_DAILY_REPORT,
+			Spec:        fmt.Sprintf("%d %d * * *", DAILY_REPORT_MINUTE, DAILY_REPORT_HOUR),
+			Run:         b.postDailyReport,
+			Jitter:      30 * time.Second,
+			Missed:      scheduler.RunMissed,
+			Immediately: true, // catches up after a restart; posting is idempotent
+		},
+		{
+			Name:        JOB_PERIOD_REPORTS,
+			Spec:        fmt.Sprintf("0 %d * * *", PERIOD_REPORT_HOUR),
+			Run:         b.postPeriodReports,
+			Jitter:      30 * time.Second,
+			Missed:      scheduler.RunMissed,
+			Immediately: true,
+		},
+		{
+			Name:   JOB_CLEANUP_HISTORY,
+			Spec:   "0 4 * * *",
+			Run:    b.cleanupHistory,
+			Jitter: 5 * time.Minute,
+		},
+		{
+			Name: JOB_EDIT_BOARDS,
+			Spec: "@every 1s",
+			Run:  b.editBoards,
+		},
+	}
+
+	for i, job := range jobs {
+		// editBoards takes b.mu itself, leaving it for its Telegram calls
+		if job.Name != JOB_EDIT_BOARDS {
+			jobs[i].Run = b.locked(job.Run)
+		}
+		if spec := os.Getenv("SCHEDULE_" + strings.ToUpper(strings.ReplaceAll(job.Name, "-", "_"))); spec != "" {
+			jobs[i].Spec = spec
+		}
+	}
+	return append(jobs, b.digestJobs()...)
+}
+
+func (b *bot) locked(run func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return run(ctx)
+	}
+}
+
+// onJobError records failures of jobs that don't report to the monitor themselves.
+func (b *bot) onJobError(job string, err error) {
+	switch job {
+	case JOB_REFRESH_PRICES, JOB_EDIT_LIVE_MESSAGE, JOB_POST_FRESH_MESSAGE:
+		return
+	}
+	b.monitor.JobFailed(job, err)
+}
+
+// periods returns the refresh and edit periods and, while the markets are
+// closed, the notice shown in the live message.
+func (b *bot) periods(now time.Time) (pricePeriod, messagePeriod time.Duration, notice string) {
+	if status, closed := marketsClosed(b.calendar, now); closed {
+		return CLOSED_UPDATE_PRICE_PERIOD * time.Second, CLOSED_UPDATE_MESSAGE_PERIOD * time.Second, marketClosedNotice(status)
+	}
+	return UPDATE_PRICE_PERIOD * time.Second, UPDATE_MESSAGE_PERIOD * time.Second, ""
+}
+
+func (b *bot) refreshPrices(ctx context.Context) error {
+	pricePeriod, _, _ := b.periods(time.Now())
+	if time.Since(b.price.LastRefresh) < pricePeriod-scheduleSlack {
+		return nil
+	}
+
+	if err := b.price.Refresh(ctx); err != nil {
+		b.monitor.RefreshFailed(err)
+		b.webhooks.ProviderFailed("refresh", err, time.Now())
+		return fmt.Errorf("refresh price error: %w", err)
+	}
+	b.monitor.RefreshSucceeded(b.price.LastRefresh)
+	b.webhooks.ProviderRecovered("refresh")
+
+	result := b.detector.Observe(b.price.LastRefresh, &b.price.Current)
+	for _, tick := range result.Quarantined {
+		b.logger.Warn("price quarantined", "asset", tick.Asset.Key, "raw", tick.Raw, "reason", tick.Reason)
+	}
+	statuses := b.fresh.CheckAll(&b.price.Current, b.price.LastRefresh)
+	b.price.Marks = freshness.Marks(statuses)
+	b.logger.Debug("prices refreshed", "provider", b.price.Provider(), "snapshot", b.price.String())
+
+	if err := b.store.Record(b.price.LastRefresh, result.Values); err != nil {
+		return fmt.Errorf("record price history error: %w", err)
+	}
+	b.webhooks.Refreshed(b.price.LastRefresh, result.Values)
+	if err := b.checkStalled(ctx, statuses); err != nil {
+		return err
+	}
+
+	for _, spike := range result.Spikes {
+		b.logger.Info("price spike", "asset", spike.Asset.Key,
+			"from", spike.Base.String(), "to", spike.Value.String(), "percent", spike.Percent)
+		if _, err := b.tel.Post(ctx, spike.Message()); err != nil {
+			b.monitor.MessageFailed(b.chatID, err)
+			return fmt.Errorf("post spike alert error: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkStalled posts a bot-wide alert when every asset stops updating, which
+// usually means the provider is broken rather than the markets closed.
+func (b *bot) checkStalled(ctx context.Context, statuses []freshness.Status) error {
+	since, stalled := freshness.Stalled(statuses, PROVIDER_STALL_AFTER)
+	switch {
+	case stalled && !b.stalled:
+		err := fmt.Errorf("no price has changed since %s", since.Format(time.DateTime))
+		b.logger.Warn("prices stalled", "since", since)
+		b.monitor.JobFailed("freshness", err)
+		b.webhooks.ProviderFailed("stalled", err, since)
+		if _, err := b.tel.Post(ctx, freshness.StalledMessage(since)); err != nil {
+			b.monitor.MessageFailed(b.chatID, err)
+			return fmt.Errorf("post stalled alert error: %w", err)
+		}
+	case !stalled && b.stalled:
+		b.logger.Info("prices updating again")
+		b.webhooks.ProviderRecovered("stalled")
+	}
+	if stalled == b.stalled {
+		return nil
+	}
+	b.stalled = stalled
+	return b.state.Put(state.Alerts, ALERT_PROVIDER_STALLED, state.Alert{Active: stalled, Since: since})
+}
+
+// loadAlerts restores the alerts raised before a restart, so they are not
+// posted again.
+func (b *bot) loadAlerts() error {
+	var stalled state.Alert
+	_, err := b.state.Get(state.Alerts, ALERT_PROVIDER_STALLED, &stalled)
+	b.stalled = stalled.Active
+	return err
+}
+
+// snapshot captures what the live message shows, or its final form when ending.
+func (b *bot) snapshot(ending bool) publish.Snapshot {
+	pricePeriod, _, notice := b.periods(time.Now())
+
+	var changes []report.AssetChanges
+	for _, asset := range b.changeAssets {
+		if c, ok := report.Changes(b.store, asset, b.price.LastRefresh, b.windows); ok {
+			changes = append(changes, c)
+		}
+	}
+
+	return publish.Snapshot{
+		Price:      *b.price,
+		Changes:    changes,
+		Notice:     notice,
+		NextUpdate: min(pricePeriod-time.Since(b.price.LastRefresh), pricePeriod),
+		Ending:     ending,
+		Channel:    b.chanelName,
+		ProxyLink:  b.proxyLink,
+	}
+}
+
+// editLiveMessage edits the live message of every outlet once the message
+// period has passed, posting it first where there is none yet.
+func (b *bot) editLiveMessage(ctx context.Context) error {
+	now := time.Now()
+	if b.price.LastRefresh.IsZero() {
+		return nil
+	}
+	_, messagePeriod, _ := b.periods(now)
+	editDue := now.Sub(b.lastEdit) >= messagePeriod-scheduleSlack
+
+	var errs []error
+	snapshot := b.snapshot(false)
+	for _, o := range b.outlets {
+		if now.Before(o.pausedUntil) {
+			continue
+		}
+		if !o.HasMessage() || o.freshDue {
+			errs = append(errs, b.sendFreshMessage(ctx, o))
+			continue
+		}
+		if !editDue {
+			continue
+		}
+
+		if err := o.UpdateMessage(ctx, snapshot); err != nil {
+			b.messageFailed(o, err)
+			errs = append(errs, fmt.Errorf("update %s error: %w", o.Name(), err))
+			continue
+		}
+		b.messageSucceeded(o)
+	}
+	if editDue {
+		b.lastEdit = now
+	}
+	return errors.Join(errs...)
+}
+
+// postFreshMessage replaces the live messages with new ones. Without prices
+// yet, the edit job posts them once the first refresh succeeds.
+func (b *bot) postFreshMessage(ctx context.Context) error {
+	for _, o := range b.outlets {
+		o.freshDue = true
+	}
+	if b.price.LastRefresh.IsZero() {
+		return nil
+	}
+
+	var errs []error
+	for _, o := range b.outlets {
+		errs = append(errs, b.sendFreshMessage(ctx, o))
+	}
+	return errors.Join(errs...)
+}
+
+func (b *bot) sendFreshMessage(ctx context.Context, o *outlet) error {
+	if o.HasMessage() {
+		o.UpdateMessage(ctx, b.snapshot(true))
+	}
+
+	if err := o.SendMessage(ctx, b.snapshot(false)); err != nil {
+		b.messageFailed(o, err)
+		return fmt.Errorf("send %s error: %w", o.Name(), err)
+	}
+	o.freshDue = false
+	b.lastEdit = time.Now()
+	b.messageSucceeded(o)
+	return nil
+}
+
+// messageFailed pauses edits of o and reports the failure. Only the Telegram
+// channel counts toward readiness.
+func (b *bot) messageFailed(o *outlet, err error) {
+	o.pausedUntil = time.Now().Add(ERROR_BACKOFF)
+	if o.Publisher == b.primary {
+		b.monitor.MessageFailed(b.chatID, err)
+	} else {
+		b.monitor.PublishFailed(o.Name(), err)
+	}
+}
+
+func (b *bot) messageSucceeded(o *outlet) {
+	if o.Publisher == b.primary {
+		b.monitor.MessageSucceeded(b.chatID, b.tel.LastMessageId)
+	}
+}
+
+func (b *bot) cleanupHistory(ctx context.Context) error {
+	return b.store.Prune(time.Now().Add(-HISTORY_RETENTION))
+}
+
+// shutdown leaves the live message in its final "ending" form so readers
+// don't see a stale countdown, and flushes state.
+func (b *bot) shutdown(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.price.LastRefresh.IsZero() {
+		snapshot := b.snapshot(true)
+		for _, o := range b.outlets {
+			if !o.HasMessage() {
+				continue
+			}
+			if err := o.UpdateMessage(ctx, snapshot); err != nil {
+				b.logger.Error("close live message error", "publisher", o.Name(), "error", err)
+			}
+		}
+	}
+	if err := b.tel.SaveState(); err != nil {
+		b.logger.Error("save telegram state error", "error", err)
+	}
+}
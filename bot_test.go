@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/anomaly"
+	"github.com/onionj/pricebot/export"
+	"github.com/onionj/pricebot/freshness"
+	"github.com/onionj/pricebot/health"
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/market"
+	"github.com/onionj/pricebot/portfolio"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/publish"
+	"github.com/onionj/pricebot/state"
+	"github.com/onionj/pricebot/subscription"
+	"github.com/onionj/pricebot/telegram"
+	"github.com/onionj/pricebot/webhook"
+)
+
+// request is a call the fake bot API received.
+type request struct {
+	method, text string
+}
+
+// fakeBotAPI answers every bot API call with a new message ID, recording
+// the calls with their text, or the uploaded document.
+func fakeBotAPI(t *testing.T) (*httptest.Server, func() []request) {
+	var mu sync.Mutex
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Text string `json:"text"`
+		}
+		if file, _, err := r.FormFile("document"); err == nil {
+			data, _ := io.ReadAll(file)
+			payload.Text = string(data)
+		} else {
+			json.NewDecoder(r.Body).Decode(&payload)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, request{r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], payload.Text})
+		fmt.Fprintf(w, `{"ok": true, "result": {"message_id": %d}}`, len(requests))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]request(nil), requests...)
+	}
+}
+
+// TestBot_Replay drives the refresh and live message jobs with a recorded
+// fixture: the dollar moves from 82,150 to 86,500 toman in two minutes,
+// a spike alerted once the next tick confirms it.
+func TestBot_Replay(t *testing.T) {
+	frames, err := price.LoadFixture("price/testdata/tgju.jsonl")
+	if err != nil {
+		t.Fatalf("LoadFixture failed: %v", err)
+	}
+	replay, err := price.NewReplay(frames, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, requests := fakeBotAPI(t)
+	platform := telegram.TelegramPlatform()
+	platform.BaseURL = server.URL + "/bot%s/%s"
+	st, _ := state.OpenFile("")
+	tel := telegram.NewTelegramOn(platform, "token", "-100", st)
+	store, _ := history.Open("")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	b := &bot{
+		logger:   logger,
+		price:    price.NewPriceFrom(replay),
+		tel:      tel,
+		store:    store,
+		calendar: market.Default(),
+		monitor:  health.NewMonitor(5*time.Minute, 5),
+		detector: anomaly.New(anomaly.DefaultConfig()),
+		fresh:    freshness.New(market.Default()),
+		state:    st,
+		webhooks: webhook.New(nil, webhook.Config{}, logger),
+		chatID:   "-100",
+	}
+	b.price.SetLogger(logger)
+	tel.SetLogger(logger)
+	b.setPublishers(publish.NewTelegram(tel))
+
+	ctx := context.Background()
+	for i := range len(frames) + 1 {
+		// every call is due: the price and message periods have passed
+		b.price.LastRefresh = b.price.LastRefresh.Add(-time.Hour)
+		b.lastEdit = time.Time{}
+		if err := b.refreshPrices(ctx); err != nil {
+			t.Fatalf("Refresh %d failed: %v", i, err)
+		}
+		if err := b.editLiveMessage(ctx); err != nil {
+			t.Fatalf("Edit %d failed: %v", i, err)
+		}
+	}
+
+	got := requests()
+	var methods []string
+	for _, r := range got {
+		methods = append(methods, r.method)
+	}
+	expected := []string{"sendMessage", "editMessageText", "editMessageText", "sendMessage", "editMessageText"}
+	if strings.Join(methods, " ") != strings.Join(expected, " ") {
+		t.Fatalf("Expected calls %v, got %v", expected, methods)
+	}
+	if !strings.Contains(got[0].text, "<b>82,150</b>") {
+		t.Errorf("Expected the live message to open at 82,150, got %s", got[0].text)
+	}
+	if !strings.Contains(got[3].text, "جهش قیمت") || !strings.Contains(got[3].text, "<b>86,500</b>") {
+		t.Errorf("Expected a spike alert up to 86,500, got %s", got[3].text)
+	}
+	if !strings.Contains(got[4].text, "<b>86,500</b>") {
+		t.Errorf("Expected the live message edited to 86,500, got %s", got[4].text)
+	}
+	if tel.LastMessageId != 1 {
+		t.Errorf("Expected the first message kept as the live message, got %d", tel.LastMessageId)
+	}
+
+	if p, ok := store.At("usd", time.Now()); !ok || p.Value.String() != "86500" {
+		t.Errorf("Expected 86500 recorded for usd, got %+v", p)
+	}
+}
+
+// TestBot_PortfolioPrivacy checks that "/portfolio export" sends back and
+// "/portfolio delete" forgets every record kept about the user.
+func TestBot_PortfolioPrivacy(t *testing.T) {
+	server, requests := fakeBotAPI(t)
+	platform := telegram.TelegramPlatform()
+	platform.BaseURL = server.URL + "/bot%s/%s"
+	st, _ := state.OpenFile("")
+	tel := telegram.NewTelegramOn(platform, "token", "-100", st)
+	tel.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	portfolios, _ := portfolio.Open(st)
+	subscriptions, _ := subscription.Open(st)
+
+	b := &bot{
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		price:         price.NewPriceFrom(&price.TGJU{}),
+		tel:           tel,
+		state:         st,
+		portfolios:    portfolios,
+		subscriptions: subscriptions,
+		pacer:         subscription.NewPacer(1),
+		boardTexts:    make(map[int64]string),
+	}
+
+	p := portfolios.Get(42)
+	p.Add("usd", price.MustParseDecimal("1500"), price.MustParseDecimal("80000"), time.Now())
+	portfolios.Put(p)
+	subscriptions.Put(subscription.Subscription{UserID: 42, ChatID: "42", Assets: []string{"usd"}, Interval: time.Minute})
+	message := func(text string) telegram.Message {
+		return telegram.Message{From: &telegram.User{ID: 42, Username: "someone"}, Chat: telegram.Chat{ID: 42, Type: "private"}, Text: text}
+	}
+
+	ctx := context.Background()
+	if err := b.handleMessage(ctx, message("/portfolio export")); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	got := requests()
+	if len(got) != 1 || got[0].method != "sendDocument" {
+		t.Fatalf("Expected a document sent, got %v", got)
+	}
+	for _, s := range []string{`"username": "someone"`, `"asset": "usd"`, `"assets": [`} {
+		if !strings.Contains(got[0].text, s) {
+			t.Errorf("Expected %s in the export, got %s", s, got[0].text)
+		}
+	}
+
+	if err := b.handleMessage(ctx, message("/portfolio delete")); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if len(portfolios.Get(42).Holdings) != 0 {
+		t.Error("Expected the portfolio deleted")
+	}
+	if _, ok := subscriptions.Get(42); ok {
+		t.Error("Expected the subscription deleted")
+	}
+	if ok, _ := st.Get(state.Users, "42", &state.User{}); ok {
+		t.Error("Expected the user record deleted")
+	}
+}
+
+// TestBot_EditBoards checks that boards are posted once and then edited, and
+// that neither they nor command replies are sent while b.mu is held.
+func TestBot_EditBoards(t *testing.T) {
+	frames, err := price.LoadFixture("price/testdata/tgju.jsonl")
+	if err != nil {
+		t.Fatalf("LoadFixture failed: %v", err)
+	}
+	replay, _ := price.NewReplay(frames, 0, false)
+
+	b := &bot{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	var heldDuringSend bool
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if b.mu.TryLock() {
+			b.mu.Unlock()
+		} else {
+			heldDuringSend = true
+		}
+		calls = append(calls, r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+		fmt.Fprintf(w, `{"ok": true, "result": {"message_id": %d}}`, 10+len(calls))
+	}))
+	t.Cleanup(server.Close)
+
+	platform := telegram.TelegramPlatform()
+	platform.BaseURL = server.URL + "/bot%s/%s"
+	st, _ := state.OpenFile("")
+	b.tel = telegram.NewTelegramOn(platform, "token", "-100", st)
+	b.tel.SetLogger(b.logger)
+	b.state = st
+	b.price = price.NewPriceFrom(replay)
+	b.price.SetLogger(b.logger)
+	b.portfolios, _ = portfolio.Open(st)
+	b.subscriptions, _ = subscription.Open(st)
+	b.pacer = subscription.NewPacer(10)
+	b.boardTexts = make(map[int64]string)
+
+	ctx := context.Background()
+	if err := b.price.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	m := telegram.Message{From: &telegram.User{ID: 42}, Chat: telegram.Chat{ID: 42, Type: "private"}, Text: "/subscribe usd 30s"}
+	if err := b.handleMessage(ctx, m); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if err := b.editBoards(ctx); err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	if sub, _ := b.subscriptions.Get(42); sub.MessageID != 12 {
+		t.Errorf("Expected the board posted as message 12, got %+v", sub)
+	}
+
+	// the next frame changes the board, due again once forgotten
+	if err := b.price.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	b.pacer.Forget(42)
+	if err := b.editBoards(ctx); err != nil {
+		t.Fatalf("Edit failed: %v", err)
+	}
+
+	expected := []string{"sendMessage", "sendMessage", "editMessageText"}
+	if strings.Join(calls, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected calls %v, got %v", expected, calls)
+	}
+	if heldDuringSend {
+		t.Error("Expected b.mu released while sending")
+	}
+}
+
+func TestParseExportArgs(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		args   string
+		format string
+		unit   price.Unit
+		days   int
+	}{
+		{"usd", export.CSV, "", 30},
+		{"usd xlsx", export.XLSX, "", 30},
+		{"usd rial", export.CSV, price.Rial, 30},
+		{"usd,sekee 7d jsonl rial", export.JSONL, price.Rial, 7},
+	}
+
+	for _, tt := range tests {
+		req, err := parseExportArgs(strings.Fields(tt.args), now)
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", tt.args, err)
+			continue
+		}
+		if days := int(req.To.Sub(req.From).Hours() / 24); req.Format != tt.format || req.Unit != tt.unit || days != tt.days {
+			t.Errorf("%s: expected %s %q over %d days, got %s %q over %d days", tt.args, tt.format, tt.unit, tt.days, req.Format, req.Unit, days)
+		}
+	}
+
+	if _, err := parseExportArgs([]string{"usd", "2y"}, now); err == nil {
+		t.Error("Expected an error for an unknown range")
+	}
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/market"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/publish"
+	"github.com/onionj/pricebot/report"
+	"github.com/onionj/pricebot/state"
+	"github.com/onionj/pricebot/telegram"
+	"github.com/onionj/pricebot/tui"
+	"github.com/onionj/pricebot/utils"
+)
+
+const USAGE = `usage: pricebot [command] [flags]
+
+commands:
+  run        post and keep editing the live message (default)
+  dry-run    run the full loop, logging Telegram requests instead of sending them
+  once       refresh prices once and print them
+  print      refresh prices once and print the live message, -format text, json or html
+  send-test  check the bot token and post a test message to the channel and mirrors
+  tui        full-screen terminal board of live prices, see tui -h
+  state      show or reset the persisted state: state show [bucket], state reset [bucket [key]]
+  export     export recorded history, see export -h
+  backfill   import daily history from tgju or a CSV file, see backfill -h
+  record     append prices to a fixture file for replaying, see record -h
+`
+
+// commandEnv is the configuration the one-shot commands take from the
+// environment.
+type commandEnv struct {
+	logger       *slog.Logger
+	provider     price.Provider
+	calendar     *market.Calendar
+	historyFile  string
+	stateBackend string
+	stateFile    string
+	botToken     string
+	chatID       string
+	chanelName   string
+	proxyLink    string
+	windows      []report.Window
+	changeAssets []price.Asset
+}
+
+// runCommand runs command, any but run and dry-run, with args.
+func runCommand(ctx context.Context, command string, args []string, env commandEnv) error {
+	stdout, stderr := os.Stdout, os.Stderr
+	switch command {
+	case "export":
+		return exportCommand(args, env.historyFile, stdout, stderr)
+	case "backfill":
+		return backfillCommand(ctx, args, env.historyFile, env.calendar, env.logger, stdout, stderr)
+	case "once":
+		p := price.NewPriceFrom(env.provider)
+		p.SetLogger(env.logger)
+		return onceCommand(ctx, p, stdout)
+	case "print":
+		store, err := history.Open(env.historyFile)
+		if err != nil {
+			return fmt.Errorf("open history: %w", err)
+		}
+		b := &bot{
+			logger:       env.logger,
+			price:        price.NewPriceFrom(env.provider),
+			store:        store,
+			calendar:     env.calendar,
+			chanelName:   env.chanelName,
+			proxyLink:    env.proxyLink,
+			windows:      env.windows,
+			changeAssets: env.changeAssets,
+		}
+		b.price.SetLogger(env.logger)
+		return printCommand(ctx, args, b, stdout, stderr)
+	case "send-test":
+		if env.botToken == "" || env.chatID == "" {
+			return errors.New("missing BOT_TOKEN or CHAT_ID in environment variables")
+		}
+		// test posts leave the live message state alone, so it stays in memory
+		store, _ := state.OpenFile("")
+		clients := []*telegram.Telegram{telegram.NewTelegramWithStore(env.botToken, env.chatID, store)}
+		for _, mirror := range mirrors() {
+			if mirror.token == "" {
+				continue
+			}
+			client, err := mirrorClient(mirror.platform, mirror.token, store)
+			if err != nil {
+				return fmt.Errorf("invalid %s configuration: %w", mirror.platform.Name, err)
+			}
+			clients = append(clients, client)
+		}
+		for _, client := range clients {
+			client.SetLogger(env.logger)
+		}
+		return sendTestCommand(ctx, clients, stdout)
+	case "tui":
+		return tuiCommand(ctx, args, env.provider, env.historyFile, env.calendar, os.Stdin, stdout, stderr)
+	case "record":
+		return recordCommand(ctx, args, env.provider, env.logger, stdout, stderr)
+	case "state":
+		store, err := state.Open(env.stateBackend, env.stateFile)
+		if errors.Is(err, state.ErrLocked) {
+			return fmt.Errorf("open state: %w; stop the bot first", err)
+		}
+		if err != nil {
+			return fmt.Errorf("open state: %w", err)
+		}
+		defer store.Close()
+		return stateCommand(args, store, stdout)
+	case "help", "-h", "-help", "--help":
+		_, err := fmt.Fprint(stdout, USAGE)
+		return err
+	}
+	fmt.Fprint(stderr, USAGE)
+	return fmt.Errorf("unknown command %q", command)
+}
+
+// tuiCommand runs "pricebot tui", a terminal dashboard refreshed on the
+// bot's price cadence, with sparklines from the history file.
+func tuiCommand(ctx context.Context, args []string, provider price.Provider, historyFile string, calendar *market.Calendar, stdin *os.File, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("tui", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	lang := flags.String("lang", "auto", "auto, en or fa; auto shows Persian only in terminals that lay it out right to left")
+	group := flags.String("group", "", "show one group: currency, crypto, coin or gold")
+	window := flags.String("window", "24h", "span of the sparklines, like 6h, 7d or mtd")
+	color := flags.Bool("color", os.Getenv("NO_COLOR") == "", "color the board; off when NO_COLOR is set")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	cfg := tui.Config{
+		Price:       price.NewPriceFrom(provider),
+		HistoryFile: historyFile,
+		Color:       *color,
+		In:          stdin,
+		Out:         stdout,
+		Period: func(now time.Time) (time.Duration, bool) {
+			if _, closed := marketsClosed(calendar, now); closed {
+				return CLOSED_UPDATE_PRICE_PERIOD * time.Second, true
+			}
+			return UPDATE_PRICE_PERIOD * time.Second, false
+		},
+	}
+	// logs would scroll the board; refresh errors are shown on it instead
+	cfg.Price.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var err error
+	if cfg.Lang, err = tui.ParseLang(*lang, os.Getenv); err != nil {
+		return err
+	}
+	if *group != "" {
+		cfg.Group = price.Group(strings.ToLower(*group))
+		if !slices.Contains(tui.Groups, cfg.Group) {
+			return fmt.Errorf("unknown group %q, want currency, crypto, coin or gold", *group)
+		}
+	}
+	w, err := report.ParseWindow(*window)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	cfg.TrendWindow, cfg.TrendLabel = now.Sub(w.Start(now)), w.Key
+
+	return tui.Run(ctx, cfg)
+}
+
+// stateBuckets are the buckets "pricebot state" knows, in display order.
+var stateBuckets = []string{state.Meta, state.Messages, state.Chats, state.Offsets, state.Alerts, state.Digests, state.Users, state.Portfolios, state.Boards}
+
+// onceCommand runs "pricebot once", which refreshes prices and prints them.
+func onceCommand(ctx context.Context, p *price.Price, stdout io.Writer) error {
+	if err := p.Refresh(ctx); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(stdout, p.String())
+	return err
+}
+
+// printCommand runs "pricebot print", which refreshes prices and prints the
+// final form of the live message b would post.
+func printCommand(ctx context.Context, args []string, b *bot, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("print", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "text", "text, json or html")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if !slices.Contains([]string{"text", "json", "html"}, *format) {
+		return fmt.Errorf("unknown format %q, want text, json or html", *format)
+	}
+
+	if err := b.price.Refresh(ctx); err != nil {
+		return err
+	}
+	snapshot := b.snapshot(true)
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(snapshotJSON(snapshot))
+	case "html":
+		_, err := fmt.Fprintln(stdout, publish.TelegramText(snapshot))
+		return err
+	}
+	_, err := fmt.Fprintln(stdout, plainText(publish.TelegramText(snapshot)))
+	return err
+}
+
+// printedAsset is one asset in the JSON of "pricebot print".
+type printedAsset struct {
+	Asset   string        `json:"asset"`
+	Name    string        `json:"name"`
+	Unit    price.Unit    `json:"unit"`
+	Value   price.Decimal `json:"value"`
+	Change  float64       `json:"change_percent"`
+	Updated time.Time     `json:"updated"`
+}
+
+type printedSnapshot struct {
+	Time     time.Time             `json:"time"`
+	JTime    string                `json:"jalali_time"`
+	Provider string                `json:"provider"`
+	Notice   string                `json:"notice,omitempty"`
+	Assets   []printedAsset        `json:"assets"`
+	Changes  []report.AssetChanges `json:"changes"`
+}
+
+func snapshotJSON(s publish.Snapshot) printedSnapshot {
+	result := printedSnapshot{
+		Time:     s.Price.LastRefresh,
+		JTime:    utils.NewJTime(s.Price.LastRefresh).String(),
+		Provider: s.Price.Provider(),
+		Notice:   s.Notice,
+		Assets:   []printedAsset{},
+		Changes:  s.Changes,
+	}
+	if result.Changes == nil {
+		result.Changes = []report.AssetChanges{}
+	}
+	for _, a := range price.Assets {
+		value, err := a.Value(&s.Price.Current)
+		if err != nil {
+			continue
+		}
+		detail := a.Detail(&s.Price.Current)
+		change := detail.ChangePercentage
+		if detail.ChangeDirection == "low" {
+			change = -change
+		}
+		updated, _ := detail.Updated()
+		result.Assets = append(result.Assets, printedAsset{
+			Asset: a.Key, Name: a.Name, Unit: a.Unit, Value: value, Change: change, Updated: updated,
+		})
+	}
+	return result
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// plainText strips the HTML and the right-to-left line prefixes of a
+// Telegram message, for a terminal.
+func plainText(message string) string {
+	lines := strings.Split(html.UnescapeString(htmlTag.ReplaceAllString(message, "")), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(strings.TrimSpace(line), "ا")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// sendTestCommand runs "pricebot send-test", which checks the token of each
+// client, posts a test message to its channel and edits it where the
+// platform can, to show the bot may post and edit there. The live message
+// state is left alone.
+func sendTestCommand(ctx context.Context, clients []*telegram.Telegram, stdout io.Writer) error {
+	var errs []error
+	for _, client := range clients {
+		name := client.Platform().Name
+		fail := func(step string, err error) {
+			fmt.Fprintf(stdout, "%s: %s failed: %v\n", name, step, err)
+			errs = append(errs, fmt.Errorf("%s: %s: %w", name, step, err))
+		}
+
+		me, err := client.GetMe(ctx)
+		if err != nil {
+			fail("token check", err)
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: token belongs to @%s\n", name, me.Username)
+
+		text := fmt.Sprintf("ا🧪 پیام آزمایشی ربات قیمت\nا📆 %s", utils.NewJTime(time.Now()).Format("yyyy/MM/dd HH:mm:ss"))
+		messageID, err := client.Post(ctx, text)
+		if err != nil {
+			fail("post", err)
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: posted message %d\n", name, messageID)
+
+		if !client.Platform().Features.Edit {
+			fmt.Fprintf(stdout, "%s: messages can't be edited on this platform, skipped\n", name)
+			continue
+		}
+		if err := client.UpdateMessage(ctx, text+"\nا✅ ویرایش پیام انجام شد", messageID); err != nil {
+			fail("edit", err)
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: edited message %d\n", name, messageID)
+	}
+	return errors.Join(errs...)
+}
+
+// stateCommand runs "pricebot state show [bucket]", which prints the
+// persisted state as JSON, and "pricebot state reset [bucket [key]]", which
+// deletes it, the live messages by default so the next run posts fresh
+// ones. Stop the bot before a reset; it would write its state back.
+func stateCommand(args []string, store state.Store, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("want state show [bucket] or state reset [bucket [key]]")
+	}
+	action, args := args[0], args[1:]
+
+	buckets := stateBuckets
+	if len(args) > 0 {
+		if !slices.Contains(stateBuckets, args[0]) {
+			return fmt.Errorf("unknown bucket %q, want one of %s", args[0], strings.Join(stateBuckets, ", "))
+		}
+		buckets = args[:1]
+	}
+
+	switch action {
+	case "show":
+		if len(args) > 1 {
+			return errors.New("want state show [bucket]")
+		}
+		result := make(map[string]map[string]json.RawMessage)
+		for _, bucket := range buckets {
+			keys, err := store.Keys(bucket)
+			if err != nil {
+				return fmt.Errorf("%s: %w", bucket, err)
+			}
+			values := make(map[string]json.RawMessage, len(keys))
+			for _, key := range keys {
+				var value json.RawMessage
+				if _, err := store.Get(bucket, key, &value); err != nil {
+					return fmt.Errorf("%s/%s: %w", bucket, key, err)
+				}
+				values[key] = value
+			}
+			result[bucket] = values
+		}
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+
+	case "reset":
+		if len(args) > 2 {
+			return errors.New("want state reset [bucket [key]]")
+		}
+		if len(args) == 0 {
+			buckets = []string{state.Messages}
+		}
+		if buckets[0] == state.Meta {
+			return errors.New("the meta bucket holds the schema version and can't be reset")
+		}
+		keys, err := store.Keys(buckets[0])
+		if err != nil {
+			return err
+		}
+		if len(args) == 2 {
+			if !slices.Contains(keys, args[1]) {
+				return fmt.Errorf("no key %q in %s", args[1], buckets[0])
+			}
+			keys = args[1:]
+		}
+		for _, key := range keys {
+			if err := store.Delete(buckets[0], key); err != nil {
+				return fmt.Errorf("%s/%s: %w", buckets[0], key, err)
+			}
+		}
+		_, err = fmt.Fprintf(stdout, "deleted %d keys from %s\n", len(keys), buckets[0])
+		return err
+	}
+	return fmt.Errorf("unknown state command %q, want show or reset", action)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onionj/pricebot/portfolio"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/state"
+	"github.com/onionj/pricebot/subscription"
+	"github.com/onionj/pricebot/telegram"
+)
+
+// how long polling waits after getUpdates fails
+const POLL_ERROR_BACKOFF = 5 * time.Second
+
+const helpMessage = `ا🤖 <b>دستورات ربات</b>
+
+<code>/hold 2 sekee</code> افزودن دارایی به سبد (مقدار منفی برای فروش)
+<code>/portfolio</code> ارزش و سود/زیان سبد
+<code>/portfolio export</code> دریافت فایل اطلاعات ذخیره شده
+<code>/portfolio delete</code> حذف همه اطلاعات شما
+<code>/subscribe usd,sekee,btc 2m</code> تابلوی قیمت شخصی در پیام خصوصی
+<code>/unsubscribe</code> لغو تابلوی شخصی
+<code>/export usd,sekee 30d xlsx rial</code> دریافت تاریخچه قیمت (csv، jsonl یا xlsx)
+
+نماد دارایی‌ها: %s`
+
+// pollCommands long-polls Telegram for user commands until ctx is done.
+func (b *bot) pollCommands(ctx context.Context) {
+	for ctx.Err() == nil {
+		updates, err := b.tel.GetUpdates(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			b.logger.Warn("get updates error", "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(POLL_ERROR_BACKOFF):
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			if u.Message != nil && u.Message.From != nil {
+				if err := b.handleMessage(ctx, *u.Message); err != nil {
+					b.logger.Error("command error", "error", err)
+				}
+			}
+			if err := b.tel.Acknowledge(u); err != nil {
+				b.logger.Error("save update offset error", "error", err)
+			}
+		}
+	}
+}
+
+// reply is the answer to a command: a message, or a file with text as its
+// caption.
+type reply struct {
+	text     string
+	fileName string
+	file     []byte
+}
+
+// handleMessage runs the command in m, if any, and replies in its chat. The
+// reply is built under b.mu and sent without it, so that a slow Telegram
+// call doesn't hold up the jobs.
+func (b *bot) handleMessage(ctx context.Context, m telegram.Message) error {
+	command, args, ok := m.Command()
+	if !ok {
+		return nil
+	}
+
+	b.mu.Lock()
+	r, err := b.runCommand(m, command, args)
+	b.mu.Unlock()
+	if err != nil || r.text == "" {
+		return err
+	}
+
+	if r.file != nil {
+		_, err = b.tel.SendDocumentTo(ctx, m.Chat.ChatID(), r.fileName, r.file, r.text)
+	} else {
+		_, err = b.tel.PostTo(ctx, m.Chat.ChatID(), r.text)
+	}
+	return err
+}
+
+// runCommand runs command and returns its reply, empty for commands the bot
+// doesn't know. b.mu must be held.
+func (b *bot) runCommand(m telegram.Message, command string, args []string) (reply, error) {
+	if err := b.seen(m); err != nil {
+		b.logger.Error("save user error", "error", err)
+	}
+
+	var text string
+	switch command {
+	case "start", "help":
+		text = fmt.Sprintf(helpMessage, assetKeys())
+	case "export":
+		return b.exportHistory(args)
+	case "hold", "portfolio", "subscribe", "unsubscribe":
+		if m.Chat.Type != "private" {
+			text = "ا🔐 برای حفظ حریم خصوصی، دستورات سبد و اشتراک را در پیام خصوصی ربات بفرستید."
+			break
+		}
+		switch {
+		case command == "hold":
+			text = b.hold(m.From.ID, args)
+		case command == "subscribe":
+			text = b.subscribe(m, args)
+		case command == "unsubscribe":
+			text = b.unsubscribe(m.From.ID)
+		case len(args) > 0:
+			return b.portfolioAction(m.From.ID, args[0])
+		default:
+			text = portfolio.Message(portfolio.Value(b.portfolios.Get(m.From.ID), b.pricer(), time.Now()))
+		}
+	}
+	return reply{text: text}, nil
+}
+
+// seen records the sender of m as a known user.
+func (b *bot) seen(m telegram.Message) error {
+	key := strconv.FormatInt(m.From.ID, 10)
+	var user state.User
+	if _, err := b.state.Get(state.Users, key, &user); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if user.FirstSeen.IsZero() {
+		user.FirstSeen = now
+	}
+	user.ID = m.From.ID
+	user.Username = m.From.Username
+	user.LastSeen = now
+	if m.Chat.Type == "private" {
+		user.ChatID = m.Chat.ChatID()
+	}
+	return b.state.Put(state.Users, key, user)
+}
+
+func (b *bot) pricer() portfolio.Pricer {
+	return portfolio.Pricer{Current: &b.price.Current, Store: b.store}
+}
+
+// hold handles "/hold <amount> <asset>".
+func (b *bot) hold(userID int64, args []string) string {
+	if len(args) != 2 {
+		return "ا❗️ استفاده: <code>/hold 2 sekee</code>"
+	}
+	amount, err := price.ParseDecimal(args[0])
+	if err != nil || amount.IsZero() {
+		return fmt.Sprintf("ا❗️ مقدار نامعتبر: %s", html.EscapeString(args[0]))
+	}
+	asset, ok := price.FindAsset(args[1])
+	if !ok {
+		return fmt.Sprintf("ا❗️ دارایی ناشناخته: %s\nا   نمادها: %s", html.EscapeString(args[1]), assetKeys())
+	}
+	entry, ok := b.pricer().Now(asset)
+	if !ok {
+		return fmt.Sprintf("ا❗️ قیمت %s در دسترس نیست، کمی بعد دوباره تلاش کنید.", asset.Name)
+	}
+
+	p := b.portfolios.Get(userID)
+	switch err := p.Add(asset.Key, amount, entry, time.Now()); {
+	case errors.Is(err, portfolio.ErrNotHeld):
+		return fmt.Sprintf("ا❗️ %s در سبد شما نیست.", asset.Name)
+	case errors.Is(err, portfolio.ErrOversold):
+		return fmt.Sprintf("ا❗️ بیشتر از موجودی %s نمی‌توان فروخت.", asset.Name)
+	case err != nil:
+		return fmt.Sprintf("ا❗️ %s", html.EscapeString(err.Error()))
+	}
+	if err := b.portfolios.Put(p); err != nil {
+		b.logger.Error("save portfolio error", "error", err)
+		return "ا❗️ ذخیره سبد ناموفق بود، دوباره تلاش کنید."
+	}
+
+	if amount.Sign() < 0 {
+		return fmt.Sprintf("ا✅ %s %s از سبد شما کم شد.", amount.Neg().Format(8), asset.Name)
+	}
+	return fmt.Sprintf("ا✅ %s %s با قیمت %s تومان به سبد شما اضافه شد.", amount.Format(8), asset.Name, entry.Format(0))
+}
+
+// userData is everything the bot keeps about a user, as "/portfolio export"
+// sends it back.
+type userData struct {
+	User         *state.User                `json:"user,omitempty"`
+	Portfolio    portfolio.Portfolio        `json:"portfolio"`
+	Subscription *subscription.Subscription `json:"subscription,omitempty"`
+}
+
+// portfolioAction handles "/portfolio export" and "/portfolio delete", which
+// cover all the bot keeps about the user: their user record, portfolio and
+// subscription.
+func (b *bot) portfolioAction(userID int64, action string) (reply, error) {
+	switch strings.ToLower(action) {
+	case "export":
+		data := userData{Portfolio: b.portfolios.Get(userID)}
+		var user state.User
+		if ok, err := b.state.Get(state.Users, strconv.FormatInt(userID, 10), &user); err != nil {
+			return reply{}, err
+		} else if ok {
+			data.User = &user
+		}
+		if sub, ok := b.subscriptions.Get(userID); ok {
+			data.Subscription = &sub
+		}
+		raw, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return reply{}, err
+		}
+		return reply{text: "ا📦 اطلاعات ذخیره شده شما", fileName: "portfolio.json", file: raw}, nil
+	case "delete":
+		if err := b.portfolios.Delete(userID); err != nil {
+			return reply{}, err
+		}
+		if err := b.subscriptions.Delete(userID); err != nil {
+			return reply{}, err
+		}
+		b.pacer.Forget(userID)
+		delete(b.boardTexts, userID)
+		if err := b.state.Delete(state.Users, strconv.FormatInt(userID, 10)); err != nil {
+			return reply{}, err
+		}
+		return reply{text: "ا🗑 همه اطلاعات شما، سبد و اشتراک، حذف شد."}, nil
+	}
+	return reply{text: fmt.Sprintf(helpMessage, assetKeys())}, nil
+}
+
+func assetKeys() string {
+	keys := make([]string, len(price.Assets))
+	for i, a := range price.Assets {
+		keys[i] = a.Key
+	}
+	return strings.Join(keys, ", ")
+}
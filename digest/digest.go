@@ -0,0 +1,130 @@
+// Package digest emails recipients a table of closing, opening and current
+// rates built from the price history, with the same table as a CSV
+// attachment.
+package digest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"os"
+	"time"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/scheduler"
+	"github.com/onionj/pricebot/utils"
+)
+
+// DefaultSchedule sends digests at 8:00 Tehran time, Saturday to Wednesday.
+const DefaultSchedule = "0 8 * * sat-wed"
+
+// Recipient is one digest: who gets it, which assets it lists and when it
+// is sent.
+type Recipient struct {
+	// Name identifies the digest in job names and state
+	Name string   `json:"name"`
+	To   []string `json:"to"`
+	// Assets it lists, all when empty
+	Assets []string `json:"assets,omitempty"`
+	// Schedule is a cron expression in Tehran time, DefaultSchedule when empty
+	Schedule string `json:"schedule,omitempty"`
+}
+
+// AssetList returns the assets r lists, in price.Assets order.
+func (r Recipient) AssetList() []price.Asset {
+	if len(r.Assets) == 0 {
+		return price.Assets
+	}
+	var assets []price.Asset
+	for _, asset := range price.Assets {
+		for _, key := range r.Assets {
+			if a, ok := price.FindAsset(key); ok && a.Key == asset.Key {
+				assets = append(assets, asset)
+				break
+			}
+		}
+	}
+	return assets
+}
+
+// LoadRecipients reads a JSON list of recipients from path.
+func LoadRecipients(path string) ([]Recipient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var recipients []Recipient
+	if err := json.Unmarshal(data, &recipients); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for i, r := range recipients {
+		if r.Name == "" || names[r.Name] {
+			return nil, fmt.Errorf("digest %d: missing or duplicate name", i)
+		}
+		names[r.Name] = true
+		if len(r.To) == 0 {
+			return nil, fmt.Errorf("digest %s: no recipients", r.Name)
+		}
+		for _, to := range r.To {
+			if _, err := mail.ParseAddress(to); err != nil {
+				return nil, fmt.Errorf("digest %s: invalid address %q", r.Name, to)
+			}
+		}
+		for _, key := range r.Assets {
+			if _, ok := price.FindAsset(key); !ok {
+				return nil, fmt.Errorf("digest %s: unknown asset %q", r.Name, key)
+			}
+		}
+		if r.Schedule == "" {
+			recipients[i].Schedule = DefaultSchedule
+		}
+		if _, err := scheduler.Parse(recipients[i].Schedule, utils.Tehran); err != nil {
+			return nil, fmt.Errorf("digest %s: %w", r.Name, err)
+		}
+	}
+	return recipients, nil
+}
+
+// Row is one asset of a digest.
+type Row struct {
+	Asset price.Asset
+	// Close is the last value before today
+	Close price.Decimal
+	// Open is the first value recorded today, Close while none is
+	Open    price.Decimal
+	Current price.Decimal
+	// Change is the percent change of Current versus Close
+	Change float64
+}
+
+// Digest is the table sent on one day.
+type Digest struct {
+	Date utils.JDate
+	At   time.Time
+	Rows []Row
+}
+
+// Build returns the digest of assets at now. Assets without a value before
+// today are left out.
+func Build(store *history.Store, assets []price.Asset, now time.Time) Digest {
+	today := utils.NewJTime(now).Date()
+	midnight := today.Time()
+
+	d := Digest{Date: today, At: now}
+	for _, asset := range assets {
+		closing, ok := store.At(asset.Key, midnight.Add(-time.Nanosecond))
+		if !ok {
+			continue
+		}
+		row := Row{Asset: asset, Close: closing.Value, Open: closing.Value, Current: closing.Value}
+		if points := store.Range(asset.Key, midnight, now.Add(time.Nanosecond)); len(points) > 0 {
+			row.Open, row.Current = points[0].Value, points[len(points)-1].Value
+		}
+		row.Change = row.Current.PercentChange(row.Close)
+		d.Rows = append(d.Rows, row)
+	}
+	return d
+}
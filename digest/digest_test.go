@@ -0,0 +1,157 @@
+package digest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/utils"
+)
+
+func TestLoadRecipients(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		valid bool
+	}{
+		{"valid", `[{"name":"finance","to":["Finance <finance@example.com>"],"assets":["usd","sekee"],"schedule":"30 7 * * *"}]`, true},
+		{"default schedule", `[{"name":"finance","to":["finance@example.com"]}]`, true},
+		{"missing name", `[{"to":["finance@example.com"]}]`, false},
+		{"duplicate name", `[{"name":"a","to":["a@example.com"]},{"name":"a","to":["b@example.com"]}]`, false},
+		{"no recipients", `[{"name":"finance"}]`, false},
+		{"invalid address", `[{"name":"finance","to":["finance"]}]`, false},
+		{"unknown asset", `[{"name":"finance","to":["finance@example.com"],"assets":["xyz"]}]`, false},
+		{"invalid schedule", `[{"name":"finance","to":["finance@example.com"],"schedule":"daily"}]`, false},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "digests.json")
+		os.WriteFile(path, []byte(tt.data), 0600)
+		recipients, err := LoadRecipients(path)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %v, got error %v", tt.name, tt.valid, err)
+		}
+		if err == nil && recipients[0].Schedule == "" {
+			t.Errorf("%s: expected a schedule, got none", tt.name)
+		}
+	}
+}
+
+// testDigest builds the digest of 1404/02/16 08:00 over usd, which changed
+// twice that morning, and eur, which hasn't changed since the day before.
+func testDigest(t *testing.T) Digest {
+	store, _ := history.Open("")
+	yesterday := time.Date(2025, 5, 5, 12, 0, 0, 0, utils.Tehran)
+	today := time.Date(2025, 5, 6, 7, 0, 0, 0, utils.Tehran)
+	records := []struct {
+		at     time.Time
+		values map[string]string
+	}{
+		{yesterday, map[string]string{"usd": "80000", "eur": "90000"}},
+		{yesterday.Add(time.Hour), map[string]string{"usd": "80400", "eur": "90000"}},
+		{today, map[string]string{"usd": "81000", "eur": "90000"}},
+		{today.Add(30 * time.Minute), map[string]string{"usd": "81200", "eur": "90000"}},
+	}
+	for _, r := range records {
+		values := make(map[string]price.Decimal)
+		for key, value := range r.values {
+			values[key] = price.MustParseDecimal(value)
+		}
+		store.Record(r.at, values)
+	}
+
+	r := Recipient{Assets: []string{"eur", "usd", "gbp"}}
+	return Build(store, r.AssetList(), today.Add(time.Hour))
+}
+
+func TestBuild(t *testing.T) {
+	d := testDigest(t)
+
+	if d.Date.String() != "1404/02/16" || len(d.Rows) != 2 {
+		t.Fatalf("Expected usd and eur on 1404/02/16, got %s %+v", d.Date, d.Rows)
+	}
+	usd, eur := d.Rows[0], d.Rows[1]
+	if usd.Asset.Key != "usd" || usd.Close.String() != "80400" || usd.Open.String() != "81000" || usd.Current.String() != "81200" {
+		t.Errorf("Expected usd 80400/81000/81200, got %+v", usd)
+	}
+	if usd.Change < 0.99 || usd.Change > 1.0 {
+		t.Errorf("Expected usd change 0.995, got %v", usd.Change)
+	}
+	if eur.Asset.Key != "eur" || eur.Close.String() != "90000" || eur.Open.String() != "90000" || eur.Change != 0 {
+		t.Errorf("Expected unchanged eur, got %+v", eur)
+	}
+}
+
+func TestCompose(t *testing.T) {
+	d := testDigest(t)
+	raw, err := d.Compose("Price Bot <bot@example.com>", []string{"finance@example.com"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Expected a valid message, got %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "خلاصه قیمت‌ها 1404/02/16 | 2025-05-06" {
+		t.Errorf("Expected subject with both dates, got %q", subject)
+	}
+	if !strings.HasSuffix(msg.Header.Get("Message-ID"), "@example.com>") {
+		t.Errorf("Expected message ID at the sender's domain, got %q", msg.Header.Get("Message-ID"))
+	}
+
+	_, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+
+	alternative, err := parts.NextPart()
+	if err != nil {
+		t.Fatalf("Expected the alternatives part, got %v", err)
+	}
+	_, params, _ = mime.ParseMediaType(alternative.Header.Get("Content-Type"))
+	alternatives := multipart.NewReader(alternative, params["boundary"])
+	var bodies []string
+	for {
+		p, err := alternatives.NextPart() // decodes quoted-printable
+		if err != nil {
+			break
+		}
+		body, _ := io.ReadAll(p)
+		bodies = append(bodies, string(body))
+	}
+	if len(bodies) != 2 || !strings.Contains(bodies[0], "دلار امریکا: 80,400 | 81,000 | 81,200 تومان") ||
+		!strings.Contains(bodies[1], "<td><b>81,200</b></td><td>(1.00%🟢)</td>") {
+		t.Errorf("Expected text and HTML tables, got %q", bodies)
+	}
+
+	attachment, err := parts.NextPart()
+	if err != nil {
+		t.Fatalf("Expected the CSV attachment, got %v", err)
+	}
+	if attachment.FileName() != "prices-2025-05-06.csv" {
+		t.Errorf("Expected prices-2025-05-06.csv, got %q", attachment.FileName())
+	}
+	encoded, _ := io.ReadAll(attachment)
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+	if err != nil || !bytes.HasPrefix(data, []byte("\uFEFF")) {
+		t.Fatalf("Expected base64 CSV with a byte order mark, got %v", err)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(data[3:])).ReadAll()
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got %v %v", rows, err)
+	}
+	if strings.Join(rows[1], ",") != "usd,دلار امریکا,toman,2025-05-06,1404/02/16,80400,81000,81200,1.00" {
+		t.Errorf("Unexpected usd row %v", rows[1])
+	}
+}
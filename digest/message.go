@@ -0,0 +1,167 @@
+package digest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onionj/pricebot/report"
+	"github.com/onionj/pricebot/utils"
+)
+
+// Subject is the subject line of d, with its Jalali and Gregorian dates.
+func (d Digest) Subject() string {
+	return fmt.Sprintf("خلاصه قیمت‌ها %s | %s", d.Date.Format("yyyy/MM/dd"), d.Date.Time().Format("2006-01-02"))
+}
+
+var columns = []string{"دارایی", "پایانی دیروز", "بازگشایی امروز", "اکنون", "تغییر", "واحد"}
+
+// HTML renders d as a right-to-left HTML table.
+func (d Digest) HTML() string {
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html><html lang="fa" dir="rtl"><head><meta charset="utf-8"></head><body style="font-family:Tahoma,sans-serif">`)
+	fmt.Fprintf(&b, "<h2>%s</h2>\n", html.EscapeString(d.Subject()))
+	b.WriteString(`<table border="1" cellpadding="6" style="border-collapse:collapse">` + "\n<tr>")
+	for _, c := range columns {
+		fmt.Fprintf(&b, "<th>%s</th>", c)
+	}
+	b.WriteString("</tr>\n")
+	for _, r := range d.Rows {
+		fmt.Fprintf(&b, "<tr><td>%s %s</td><td>%s</td><td>%s</td><td><b>%s</b></td><td>%s</td><td>%s</td></tr>\n",
+			r.Asset.Emoji, html.EscapeString(r.Asset.Name), r.Close.Format(2), r.Open.Format(2), r.Current.Format(2),
+			report.FormatChange(r.Change), r.Asset.Unit.Label())
+	}
+	b.WriteString("</table>\n")
+	fmt.Fprintf(&b, "<p>%s</p></body></html>\n", d.at())
+	return b.String()
+}
+
+// Text renders d as plain text for mail clients without HTML.
+func (d Digest) Text() string {
+	var b strings.Builder
+	b.WriteString(d.Subject() + "\n\n")
+	for _, r := range d.Rows {
+		fmt.Fprintf(&b, "%s: %s | %s | %s %s %s\n", r.Asset.Name,
+			r.Close.Format(2), r.Open.Format(2), r.Current.Format(2), r.Asset.Unit.Label(), report.FormatChange(r.Change))
+	}
+	b.WriteString("\n(" + strings.Join(columns[1:4], " | ") + ")\n" + d.at() + "\n")
+	return b.String()
+}
+
+func (d Digest) at() string {
+	return "📆 " + utils.NewJTime(d.At).Format("yyyy/MM/dd HH:mm") + " | " + utils.NewJTime(d.At).Time().Format("2006-01-02 15:04")
+}
+
+// CSV renders d as CSV with a byte order mark, so spreadsheets read the
+// Persian names as UTF-8.
+func (d Digest) CSV() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\uFEFF")
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"asset", "name", "unit", "date", "jalali_date", "previous_close", "open", "current", "change_percent"})
+	for _, r := range d.Rows {
+		w.Write([]string{
+			r.Asset.Key, r.Asset.Name, string(r.Asset.Unit),
+			d.Date.Time().Format(time.DateOnly), d.Date.Format("yyyy/MM/dd"),
+			r.Close.String(), r.Open.String(), r.Current.String(),
+			strconv.FormatFloat(r.Change, 'f', 2, 64),
+		})
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+// Compose builds the MIME message of d from from to to: the text and HTML
+// alternatives and the CSV attachment.
+func (d Digest) Compose(from string, to []string) ([]byte, error) {
+	var body bytes.Buffer
+	mixed := multipart.NewWriter(&body)
+
+	var alternatives bytes.Buffer
+	alternative := multipart.NewWriter(&alternatives)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", d.Text()},
+		{"text/html; charset=utf-8", d.HTML()},
+	} {
+		w, err := alternative.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		qp.Write([]byte(part.content))
+		qp.Close()
+	}
+	alternative.Close()
+
+	w, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"multipart/alternative; boundary=" + alternative.Boundary()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.Write(alternatives.Bytes())
+
+	filename := "prices-" + d.Date.Time().Format(time.DateOnly) + ".csv"
+	w, err = mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {`text/csv; charset=utf-8; name="` + filename + `"`},
+		"Content-Disposition":       {`attachment; filename="` + filename + `"`},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.Write(wrap(base64.StdEncoding.EncodeToString(d.CSV())))
+	mixed.Close()
+
+	var msg bytes.Buffer
+	headers := [][2]string{
+		{"From", from},
+		{"To", strings.Join(to, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", d.Subject())},
+		{"Date", d.At.Format(time.RFC1123Z)},
+		{"Message-ID", messageID(from)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/mixed; boundary=" + mixed.Boundary()},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&msg, "%s: %s\r\n", h[0], h[1])
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// wrap breaks base64 into the 76 character lines mail allows.
+func wrap(s string) []byte {
+	var b bytes.Buffer
+	for len(s) > 76 {
+		b.WriteString(s[:76] + "\r\n")
+		s = s[76:]
+	}
+	b.WriteString(s + "\r\n")
+	return b.Bytes()
+}
+
+func messageID(from string) string {
+	var id [12]byte
+	rand.Read(id[:])
+	domain := "pricebot"
+	if _, host, ok := strings.Cut(from, "@"); ok {
+		domain = strings.TrimSuffix(host, ">")
+	}
+	return "<" + hex.EncodeToString(id[:]) + "@" + domain + ">"
+}
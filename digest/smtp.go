@@ -0,0 +1,138 @@
+package digest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// Connection security of an SMTP server.
+const (
+	StartTLS = "starttls" // plain connection upgraded with STARTTLS, usually port 587
+	TLS      = "tls"      // implicit TLS, usually port 465
+	None     = "none"     // no encryption, for local relays and test sinks
+)
+
+// Make this a package variable so it can be modified in tests
+var sendTimeout = 30 * time.Second
+
+// SMTPConfig is the server digests are sent through.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// Security is StartTLS, TLS or None
+	Security string
+	// TLSConfig overrides the TLS settings, by default verifying Host
+	TLSConfig *tls.Config
+}
+
+// Mailer sends mail through an SMTP server.
+type Mailer struct {
+	cfg SMTPConfig
+}
+
+// NewMailer returns a mailer for cfg.
+func NewMailer(cfg SMTPConfig) (*Mailer, error) {
+	if cfg.Host == "" || cfg.From == "" {
+		return nil, errors.New("missing SMTP host or from address")
+	}
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return nil, fmt.Errorf("invalid SMTP from address %q", cfg.From)
+	}
+	switch cfg.Security {
+	case "":
+		cfg.Security = StartTLS
+	case StartTLS, TLS, None:
+	default:
+		return nil, fmt.Errorf("unknown SMTP security %q", cfg.Security)
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+		if cfg.Security == TLS {
+			cfg.Port = 465
+		}
+	}
+	if cfg.TLSConfig == nil {
+		cfg.TLSConfig = &tls.Config{ServerName: cfg.Host}
+	}
+	return &Mailer{cfg: cfg}, nil
+}
+
+// From is the sender address.
+func (m *Mailer) From() string {
+	return m.cfg.From
+}
+
+// Send delivers msg to every address of to. With StartTLS it refuses to
+// continue when the server doesn't offer it, rather than sending in clear.
+func (m *Mailer) Send(ctx context.Context, to []string, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("smtp connect: %w", err)
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	if m.cfg.Security == TLS {
+		conn = tls.Client(conn, m.cfg.TLSConfig)
+	}
+
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp greeting: %w", err)
+	}
+	defer c.Close()
+
+	if m.cfg.Security == StartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not offer STARTTLS")
+		}
+		if err := c.StartTLS(m.cfg.TLSConfig); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if m.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	from, _ := mail.ParseAddress(m.cfg.From)
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	for _, rcpt := range to {
+		addr, err := mail.ParseAddress(rcpt)
+		if err != nil {
+			return fmt.Errorf("smtp rcpt %q: %w", rcpt, err)
+		}
+		if err := c.Rcpt(addr.Address); err != nil {
+			return fmt.Errorf("smtp rcpt %s: %w", addr.Address, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	return c.Quit()
+}
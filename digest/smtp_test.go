@@ -0,0 +1,173 @@
+package digest
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// sink is a local SMTP server that accepts one message per connection.
+type sink struct {
+	listener net.Listener
+	tls      *tls.Config
+	// implicit wraps connections in TLS, startTLS offers STARTTLS
+	implicit, startTLS bool
+
+	commands chan []string
+}
+
+func newSink(t *testing.T, implicit, startTLS bool) (*sink, *tls.Config) {
+	server := httptest.NewUnstartedServer(nil)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	clientTLS := server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	clientTLS.ServerName = "127.0.0.1"
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected a listener, got %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &sink{listener: listener, tls: server.TLS, implicit: implicit, startTLS: startTLS, commands: make(chan []string, 1)}
+	go s.serve()
+	return s, clientTLS
+}
+
+func (s *sink) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *sink) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if s.implicit {
+		conn = tls.Server(conn, s.tls)
+	}
+
+	var commands []string
+	defer func() { s.commands <- commands }()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP sink")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.Fields(line + " ")[0])
+		commands = append(commands, verb)
+
+		switch verb {
+		case "EHLO":
+			text.PrintfLine("250-localhost")
+			if s.startTLS {
+				text.PrintfLine("250-STARTTLS")
+			}
+			text.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			text.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			conn, text = tlsConn, textproto.NewConn(tlsConn)
+		case "AUTH":
+			text.PrintfLine("235 ok")
+		case "MAIL", "RCPT":
+			commands[len(commands)-1] = line
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, _ := text.ReadDotBytes()
+			commands = append(commands, string(data))
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
+
+func TestMailer_Send(t *testing.T) {
+	tests := []struct {
+		name     string
+		security string
+		implicit bool
+		startTLS bool
+		username string
+		commands []string
+		err      string
+	}{
+		{"plain sink", None, false, false, "", []string{"EHLO", "MAIL FROM:<bot@example.com>", "RCPT TO:<finance@example.com>", "RCPT TO:<ops@example.com>", "DATA"}, ""},
+		{"starttls with auth", StartTLS, false, true, "bot", []string{"EHLO", "STARTTLS", "EHLO", "AUTH", "MAIL FROM:<bot@example.com>"}, ""},
+		{"implicit tls", TLS, true, false, "bot", []string{"EHLO", "AUTH", "MAIL FROM:<bot@example.com>"}, ""},
+		{"starttls not offered", StartTLS, false, false, "bot", nil, "does not offer STARTTLS"},
+	}
+
+	for _, tt := range tests {
+		s, clientTLS := newSink(t, tt.implicit, tt.startTLS)
+		mailer, err := NewMailer(SMTPConfig{
+			Host: "127.0.0.1", Port: s.port(), Username: tt.username, Password: "secret",
+			From: "Price Bot <bot@example.com>", Security: tt.security, TLSConfig: clientTLS,
+		})
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", tt.name, err)
+		}
+
+		err = mailer.Send(context.Background(), []string{"finance@example.com", "Ops <ops@example.com>"}, []byte("Subject: test\r\n\r\nbody\r\n"))
+		commands := <-s.commands
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected error %q, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", tt.name, err)
+			continue
+		}
+
+		joined := strings.Join(commands, "\n")
+		for _, expected := range tt.commands {
+			if !strings.Contains(joined, expected) {
+				t.Errorf("%s: expected command %q, got %q", tt.name, expected, commands)
+			}
+		}
+		if !strings.Contains(joined, "Subject: test\n\nbody\n") {
+			t.Errorf("%s: expected the message, got %q", tt.name, commands)
+		}
+	}
+}
+
+func TestNewMailer(t *testing.T) {
+	tests := []struct {
+		cfg   SMTPConfig
+		port  int
+		valid bool
+	}{
+		{SMTPConfig{Host: "smtp.example.com", From: "bot@example.com"}, 587, true},
+		{SMTPConfig{Host: "smtp.example.com", From: "bot@example.com", Security: TLS}, 465, true},
+		{SMTPConfig{Host: "smtp.example.com", From: "bot@example.com", Port: 2525, Security: None}, 2525, true},
+		{SMTPConfig{Host: "smtp.example.com", From: "bot@example.com", Security: "ssl"}, 0, false},
+		{SMTPConfig{Host: "smtp.example.com", From: "bot"}, 0, false},
+		{SMTPConfig{From: "bot@example.com"}, 0, false},
+	}
+
+	for i, tt := range tests {
+		m, err := NewMailer(tt.cfg)
+		if (err == nil) != tt.valid {
+			t.Errorf("%d: expected valid %v, got error %v", i, tt.valid, err)
+			continue
+		}
+		if err == nil && m.cfg.Port != tt.port {
+			t.Errorf("%d: expected port %d, got %d", i, tt.port, m.cfg.Port)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/onionj/pricebot/digest"
+	"github.com/onionj/pricebot/scheduler"
+	"github.com/onionj/pricebot/state"
+)
+
+// JOB_DIGEST_PREFIX names the job of each email digest, like "digest-finance"
+const JOB_DIGEST_PREFIX = "digest-"
+
+// digestJobs returns a job per email digest. They only read the history and
+// state stores, which lock themselves, so they don't hold the bot lock while
+// talking to the SMTP server.
+func (b *bot) digestJobs() []scheduler.Job {
+	var jobs []scheduler.Job
+	for _, r := range b.digests {
+		var sent state.Digest
+		if _, err := b.state.Get(state.Digests, r.Name, &sent); err != nil {
+			b.logger.Error("load digest state error", "digest", r.Name, "error", err)
+		}
+		jobs = append(jobs, scheduler.Job{
+			Name:    JOB_DIGEST_PREFIX + r.Name,
+			Spec:    r.Schedule,
+			Run:     func(ctx context.Context) error { return b.sendDigest(ctx, r) },
+			Jitter:  30 * time.Second,
+			Missed:  scheduler.RunMissed,
+			LastRun: sent.SentAt,
+		})
+	}
+	return jobs
+}
+
+// sendDigest emails r its digest of the history up to now.
+func (b *bot) sendDigest(ctx context.Context, r digest.Recipient) error {
+	now := time.Now()
+	d := digest.Build(b.store, r.AssetList(), now)
+	if len(d.Rows) == 0 {
+		b.logger.Info("digest skipped, no history", "digest", r.Name)
+		return nil
+	}
+
+	msg, err := d.Compose(b.mailer.From(), r.To)
+	if err != nil {
+		return fmt.Errorf("compose digest %s: %w", r.Name, err)
+	}
+	if err := b.mailer.Send(ctx, r.To, msg); err != nil {
+		return fmt.Errorf("send digest %s: %w", r.Name, err)
+	}
+	b.logger.Info("digest sent", "digest", r.Name, "recipients", len(r.To), "assets", len(d.Rows))
+	return b.state.Put(state.Digests, r.Name, state.Digest{SentAt: now})
+}
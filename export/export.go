@@ -0,0 +1,212 @@
+// Package export dumps recorded price history as CSV, JSON Lines or XLSX,
+// with Jalali and Gregorian dates and a choice of toman or rial.
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/report"
+	"github.com/onionj/pricebot/utils"
+)
+
+// Formats.
+const (
+	CSV   = "csv"
+	JSONL = "jsonl"
+	XLSX  = "xlsx"
+)
+
+// MaxRange is the longest range an export may cover, the history retention.
+const MaxRange = 400 * 24 * time.Hour
+
+// Request selects what to export.
+type Request struct {
+	Assets []price.Asset
+	// From and To bound the exported points, From <= t < To
+	From, To time.Time
+	// Unit converts toman and rial assets to Toman or Rial; empty keeps each
+	// asset's own unit. Dollar-priced assets are never converted.
+	Unit   price.Unit
+	Format string
+}
+
+// ParseAssets parses a comma separated asset list like "usd,sekee".
+func ParseAssets(s string) ([]price.Asset, error) {
+	var assets []price.Asset
+	for _, key := range strings.Split(s, ",") {
+		if strings.TrimSpace(key) == "" {
+			continue
+		}
+		asset, ok := price.FindAsset(key)
+		if !ok {
+			return nil, fmt.Errorf("unknown asset %q", key)
+		}
+		assets = append(assets, asset)
+	}
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("no assets in %q", s)
+	}
+	return assets, nil
+}
+
+// ParseRange parses a range ending at now, written as a window like "30d" or
+// "ytd" (see report.ParseWindow), or as Jalali dates "1404/01/01..1404/01/31"
+// including both days.
+func ParseRange(s string, now time.Time) (from, to time.Time, err error) {
+	if first, last, ok := strings.Cut(s, ".."); ok {
+		fromDate, err := utils.ParseJDate(first)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		toDate, err := utils.ParseJDate(last)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from, to = fromDate.Time(), toDate.AddDays(1).Time()
+	} else {
+		w, err := report.ParseWindow(s)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from, to = w.Start(now), now.Add(time.Second)
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("empty range %q", s)
+	}
+	if to.Sub(from) > MaxRange {
+		return time.Time{}, time.Time{}, fmt.Errorf("range %q is longer than %d days", s, int(MaxRange.Hours()/24))
+	}
+	return from, to, nil
+}
+
+// ParseUnit parses "toman", "rial" or "" for each asset's own unit.
+func ParseUnit(s string) (price.Unit, error) {
+	switch unit := price.Unit(strings.ToLower(s)); unit {
+	case "", price.Toman, price.Rial:
+		return unit, nil
+	}
+	return "", fmt.Errorf("unknown unit %q, want toman or rial", s)
+}
+
+// ParseFormat parses an export format, CSV when empty.
+func ParseFormat(s string) (string, error) {
+	switch format := strings.ToLower(s); format {
+	case "":
+		return CSV, nil
+	case CSV, JSONL, XLSX:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q, want csv, jsonl or xlsx", s)
+}
+
+// Row is one exported point.
+type Row struct {
+	Time      time.Time     `json:"time"`
+	Gregorian string        `json:"gregorian"`
+	Jalali    string        `json:"jalali"`
+	Asset     string        `json:"asset"`
+	Name      string        `json:"name"`
+	Unit      price.Unit    `json:"unit"`
+	Value     price.Decimal `json:"value"`
+}
+
+var header = []string{"gregorian", "jalali", "asset", "name", "unit", "value"}
+
+func (r Row) fields() []string {
+	return []string{r.Gregorian, r.Jalali, r.Asset, r.Name, string(r.Unit), r.Value.String()}
+}
+
+// Rows returns the points req selects from store, by asset and then time.
+// The value in effect at From leads each asset's rows, so the export shows
+// the price at the start of the range even when it was recorded earlier.
+func Rows(store *history.Store, req Request) []Row {
+	var rows []Row
+	for _, asset := range req.Assets {
+		points := store.Range(asset.Key, req.From, req.To)
+		if first, ok := store.At(asset.Key, req.From); ok && (len(points) == 0 || points[0].Time.After(req.From)) {
+			points = append([]history.Point{{Time: req.From, Value: first.Value}}, points...)
+		}
+
+		unit := asset.Unit
+		if req.Unit != "" && unit != price.USD {
+			unit = req.Unit
+		}
+		for _, p := range points {
+			value := p.Value
+			if unit != asset.Unit {
+				money, _ := price.Money{Amount: value, Unit: asset.Unit}.In(unit, price.Decimal{})
+				value = money.Amount.Normalize()
+			}
+			jtime := utils.NewJTime(p.Time)
+			rows = append(rows, Row{
+				Time:      jtime.Time(),
+				Gregorian: jtime.Time().Format(time.DateTime),
+				Jalali:    jtime.String(),
+				Asset:     asset.Key,
+				Name:      asset.Name,
+				Unit:      unit,
+				Value:     value,
+			})
+		}
+	}
+	return rows
+}
+
+// Write encodes rows in format to w. CSV starts with a byte order mark so
+// Excel reads it as UTF-8.
+func Write(w io.Writer, format string, rows []Row) error {
+	switch format {
+	case CSV:
+		if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+			return err
+		}
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		for _, r := range rows {
+			cw.Write(r.fields())
+		}
+		cw.Flush()
+		return cw.Error()
+	case JSONL:
+		enc := json.NewEncoder(w)
+		for _, r := range rows {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case XLSX:
+		return writeXLSX(w, rows)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// Export returns the file req selects from store and its name.
+func Export(store *history.Store, req Request) (name string, data []byte, rows int, err error) {
+	selected := Rows(store, req)
+	var buf bytes.Buffer
+	if err := Write(&buf, req.Format, selected); err != nil {
+		return "", nil, 0, err
+	}
+	return FileName(req), buf.Bytes(), len(selected), nil
+}
+
+// FileName names the export of req, like "prices-usd-2025-04-06-2025-05-06.csv".
+func FileName(req Request) string {
+	keys := make([]string, len(req.Assets))
+	for i, a := range req.Assets {
+		keys[i] = a.Key
+	}
+	last := utils.NewJTime(req.To.Add(-time.Second)).Time()
+	return fmt.Sprintf("prices-%s-%s-%s.%s", strings.Join(keys, "-"),
+		utils.NewJTime(req.From).Time().Format(time.DateOnly), last.Format(time.DateOnly), req.Format)
+}
@@ -0,0 +1,156 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/utils"
+)
+
+func TestParseRange(t *testing.T) {
+	now := time.Date(2025, 5, 6, 12, 0, 0, 0, utils.Tehran)
+	tests := []struct {
+		spec     string
+		from, to string
+		valid    bool
+	}{
+		{"30d", "2025-04-06 12:00", "2025-05-06 12:00", true},
+		{"ytd", "2025-03-21 00:00", "2025-05-06 12:00", true},
+		{"1404/01/01..1404/01/31", "2025-03-21 00:00", "2025-04-21 00:00", true},
+		{"1404/02/01..1404/01/01", "", "", false},
+		{"1400/01/01..1404/01/01", "", "", false},
+		{"2y", "", "", false},
+	}
+
+	for _, tt := range tests {
+		from, to, err := ParseRange(tt.spec, now)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %v, got error %v", tt.spec, tt.valid, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := from.In(utils.Tehran).Format("2006-01-02 15:04"); got != tt.from {
+			t.Errorf("%s: expected from %s, got %s", tt.spec, tt.from, got)
+		}
+		if got := to.In(utils.Tehran).Format("2006-01-02 15:04"); got != tt.to {
+			t.Errorf("%s: expected to %s, got %s", tt.spec, tt.to, got)
+		}
+	}
+}
+
+func testRequest(t *testing.T, unit price.Unit) (*history.Store, Request) {
+	store, err := history.Open("")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	start := time.Date(2025, 5, 5, 12, 0, 0, 0, utils.Tehran)
+	for i, values := range []map[string]string{
+		{"usd": "80000", "btc": "95000.5"},
+		{"usd": "80400"},
+		{"usd": "81000", "btc": "96000"},
+	} {
+		decimals := make(map[string]price.Decimal)
+		for key, value := range values {
+			decimals[key] = price.MustParseDecimal(value)
+		}
+		if err := store.Record(start.Add(time.Duration(i)*time.Hour), decimals); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	assets, err := ParseAssets("usd,btc")
+	if err != nil {
+		t.Fatalf("ParseAssets failed: %v", err)
+	}
+	return store, Request{Assets: assets, From: start.Add(30 * time.Minute), To: start.Add(3 * time.Hour), Unit: unit}
+}
+
+func TestRows(t *testing.T) {
+	store, req := testRequest(t, price.Rial)
+	rows := Rows(store, req)
+
+	expected := []string{
+		"2025-05-05 12:30:00,1404/02/15 12:30:00,usd,rial,800000",
+		"2025-05-05 13:00:00,1404/02/15 13:00:00,usd,rial,804000",
+		"2025-05-05 14:00:00,1404/02/15 14:00:00,usd,rial,810000",
+		"2025-05-05 12:30:00,1404/02/15 12:30:00,btc,usd,95000.5",
+		"2025-05-05 14:00:00,1404/02/15 14:00:00,btc,usd,96000",
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %+v", len(expected), rows)
+	}
+	for i, r := range rows {
+		got := strings.Join([]string{r.Gregorian, r.Jalali, r.Asset, string(r.Unit), r.Value.String()}, ",")
+		if got != expected[i] {
+			t.Errorf("Row %d: expected %s, got %s", i, expected[i], got)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	store, req := testRequest(t, "")
+	rows := Rows(store, req)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, CSV, rows); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("\uFEFF")) {
+		t.Error("Expected CSV to start with a byte order mark")
+	}
+	records, err := csv.NewReader(bytes.NewReader(buf.Bytes()[3:])).ReadAll()
+	if err != nil || len(records) != 6 || strings.Join(records[1], ",") != "2025-05-05 12:30:00,1404/02/15 12:30:00,usd,دلار امریکا,toman,80000" {
+		t.Errorf("Unexpected CSV %v %v", records, err)
+	}
+
+	buf.Reset()
+	if err := Write(&buf, JSONL, rows); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var row Row
+	if err := json.Unmarshal([]byte(lines[4]), &row); err != nil || len(lines) != 5 || row.Asset != "btc" || row.Value.String() != "96000" {
+		t.Errorf("Unexpected JSON Lines %v %v", lines, err)
+	}
+
+	buf.Reset()
+	if err := Write(&buf, XLSX, rows); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Expected a zip archive, got %v", err)
+	}
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, _ := f.Open()
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			sheet = string(data)
+		}
+	}
+	for _, expected := range []string{`<c r="A1" t="inlineStr"><is><t>gregorian</t></is></c>`, `<c r="D2" t="inlineStr"><is><t>دلار امریکا</t></is></c>`, `<c r="F6"><v>96000</v></c>`} {
+		if !strings.Contains(sheet, expected) {
+			t.Errorf("Expected sheet to contain %s, got %s", expected, sheet)
+		}
+	}
+}
+
+func TestFileName(t *testing.T) {
+	_, req := testRequest(t, "")
+	req.Format = XLSX
+	if name := FileName(req); name != "prices-usd-btc-2025-05-05-2025-05-05.xlsx" {
+		t.Errorf("Expected prices-usd-btc-2025-05-05-2025-05-05.xlsx, got %s", name)
+	}
+}
@@ -0,0 +1,71 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// xlsxParts are the fixed parts of a one-sheet workbook.
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="prices" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+// writeXLSX writes rows as a minimal XLSX workbook: one sheet with inline
+// strings and numeric values, which Excel and LibreOffice open without a
+// shared string table or styles.
+func writeXLSX(w io.Writer, rows []Row) error {
+	zw := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	writeRow(&b, 1, header, -1)
+	for i, r := range rows {
+		writeRow(&b, i+2, r.fields(), len(header)-1)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	if _, err := io.WriteString(f, b.String()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeRow writes the cells of row n, with column number as a number and
+// the others as inline strings.
+func writeRow(b *strings.Builder, n int, cells []string, number int) {
+	fmt.Fprintf(b, `<row r="%d">`, n)
+	for i, cell := range cells {
+		ref := fmt.Sprintf("%c%d", 'A'+i, n)
+		if i == number {
+			fmt.Fprintf(b, `<c r="%s"><v>%s</v></c>`, ref, cell)
+			continue
+		}
+		fmt.Fprintf(b, `<c r="%s" t="inlineStr"><is><t>`, ref)
+		xml.EscapeText(b, []byte(cell))
+		b.WriteString(`</t></is></c>`)
+	}
+	b.WriteString(`</row>`)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"time"
+
+	"github.com/onionj/pricebot/export"
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/utils"
+)
+
+// DEFAULT_EXPORT_RANGE is exported when no range is given
+const DEFAULT_EXPORT_RANGE = "30d"
+
+// exportHistory handles "/export usd,eur 30d [csv|jsonl|xlsx] [toman|rial]",
+// replying with the file. The format and unit may come in either order.
+func (b *bot) exportHistory(args []string) (reply, error) {
+	req, err := parseExportArgs(args, time.Now())
+	if err != nil {
+		return reply{text: fmt.Sprintf("ا❗️ %s\nا   استفاده: <code>/export usd,sekee 30d xlsx rial</code>\nا   نمادها: %s", html.EscapeString(err.Error()), assetKeys())}, nil
+	}
+
+	name, data, rows, err := export.Export(b.store, req)
+	if err != nil {
+		return reply{}, err
+	}
+	if rows == 0 {
+		return reply{text: "ا📭 در این بازه قیمتی ثبت نشده است."}, nil
+	}
+	caption := fmt.Sprintf("ا📤 تاریخچه قیمت از %s تا %s (%d ردیف)",
+		utils.NewJTime(req.From).Date(), utils.NewJTime(req.To.Add(-time.Second)).Date(), rows)
+	return reply{text: caption, fileName: name, file: data}, nil
+}
+
+// parseExportArgs parses the arguments of /export: assets, range and then
+// format and unit in any order. The range may be left out, as in
+// "/export usd xlsx", for DEFAULT_EXPORT_RANGE.
+func parseExportArgs(args []string, now time.Time) (export.Request, error) {
+	assets, spec := "usd", DEFAULT_EXPORT_RANGE
+	if len(args) > 0 {
+		assets = args[0]
+	}
+	options := args[min(len(args), 1):]
+	if len(options) > 0 && !isExportOption(options[0]) {
+		spec, options = options[0], options[1:]
+	}
+
+	req := export.Request{Format: export.CSV}
+	var err error
+	if req.Assets, err = export.ParseAssets(assets); err != nil {
+		return req, err
+	}
+	if req.From, req.To, err = export.ParseRange(spec, now); err != nil {
+		return req, err
+	}
+	for _, arg := range options {
+		if format, err := export.ParseFormat(arg); err == nil {
+			req.Format = format
+		} else if unit, err := export.ParseUnit(arg); err == nil {
+			req.Unit = unit
+		} else {
+			return req, fmt.Errorf("unknown option %q", arg)
+		}
+	}
+	return req, nil
+}
+
+// isExportOption reports whether arg is a format or unit rather than a range.
+func isExportOption(arg string) bool {
+	_, formatErr := export.ParseFormat(arg)
+	_, unitErr := export.ParseUnit(arg)
+	return formatErr == nil || unitErr == nil
+}
+
+// exportCommand runs "pricebot export", which writes the history selected by
+// its flags to a file or, with -o -, to stdout.
+func exportCommand(args []string, historyFile string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	assets := flags.String("assets", "usd", "comma separated assets, like usd,sekee")
+	spec := flags.String("range", DEFAULT_EXPORT_RANGE, "window like 30d or ytd, or Jalali dates like 1404/01/01..1404/01/31")
+	format := flags.String("format", export.CSV, "csv, jsonl or xlsx")
+	unit := flags.String("unit", "", "toman or rial, default each asset's own unit")
+	out := flags.String("o", "", "output file, - for stdout, default a name made from the request")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	var req export.Request
+	var err error
+	if req.Assets, err = export.ParseAssets(*assets); err != nil {
+		return err
+	}
+	if req.From, req.To, err = export.ParseRange(*spec, time.Now()); err != nil {
+		return err
+	}
+	if req.Format, err = export.ParseFormat(*format); err != nil {
+		return err
+	}
+	if req.Unit, err = export.ParseUnit(*unit); err != nil {
+		return err
+	}
+
+	store, err := history.Open(historyFile)
+	if err != nil {
+		return fmt.Errorf("open history: %w", err)
+	}
+	name, data, rows, err := export.Export(store, req)
+	if err != nil {
+		return err
+	}
+
+	switch *out {
+	case "-":
+		_, err = stdout.Write(data)
+		return err
+	case "":
+		*out = name
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "wrote %d rows to %s\n", rows, *out)
+	return nil
+}
@@ -0,0 +1,174 @@
+// Package feed serves the daily summaries and significant moves as Atom and
+// JSON Feed documents for readers who follow the bot from a feed reader.
+package feed
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onionj/pricebot/anomaly"
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/market"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/report"
+	"github.com/onionj/pricebot/utils"
+)
+
+// Entry kinds.
+const (
+	KindDaily = "daily"
+	KindMove  = "move"
+)
+
+// Entry is one item of the feed.
+type Entry struct {
+	ID        string
+	Kind      string
+	Asset     string // the moved asset of a move entry
+	Title     string
+	HTML      string
+	Text      string
+	Published time.Time
+	Jalali    string // Jalali date and time of Published, like 1404/02/16 19:30
+	Gregorian string // Gregorian date and time of Published in Tehran
+}
+
+// Config tunes a Feed.
+type Config struct {
+	Title string
+	// Days is how far back entries reach
+	Days int
+	// DailyAt is when, after midnight Tehran time, a trading day's summary
+	// is published
+	DailyAt time.Duration
+	// Anomaly decides which moves get an entry, like the live spike alerts
+	Anomaly anomaly.Config
+	// TTL is how long generated entries are served before regenerating
+	TTL time.Duration
+	// BaseURL is the public URL the feeds are served under, which IDs and
+	// links are built from. Without it links follow the request and IDs
+	// are tag: URIs.
+	BaseURL string
+}
+
+// Feed generates entries from the price history.
+type Feed struct {
+	store    *history.Store
+	calendar *market.Calendar
+	cfg      Config
+
+	mu          sync.Mutex
+	entries     []Entry
+	generatedAt time.Time
+}
+
+// New returns a feed over store. Daily summaries are generated for the
+// trading days of calendar.
+func New(store *history.Store, calendar *market.Calendar, cfg Config) *Feed {
+	// history holds only changes, so a move can't wait for further ticks
+	// to confirm it the way live alerts do
+	cfg.Anomaly.ConfirmTicks = 0
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	return &Feed{store: store, calendar: calendar, cfg: cfg}
+}
+
+// Entries returns the entries published up to now, newest first. They are
+// regenerated at most once per TTL.
+func (f *Feed) Entries(now time.Time) []Entry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.entries == nil || now.Sub(f.generatedAt) >= f.cfg.TTL || now.Before(f.generatedAt) {
+		f.entries = f.generate(now)
+		f.generatedAt = now
+	}
+	return f.entries
+}
+
+func (f *Feed) generate(now time.Time) []Entry {
+	today := utils.NewJTime(now).Date()
+	from := today.AddDays(-f.cfg.Days + 1)
+
+	entries := []Entry{}
+	for d := from; !d.After(today); d = d.AddDays(1) {
+		published := d.Time().Add(f.cfg.DailyAt)
+		if published.After(now) || !f.calendar.IsTradingDay(d) {
+			continue
+		}
+		if message, ok := report.Daily(f.store, d); ok {
+			lines := messageLines(message)
+			entries = append(entries, newEntry("daily:"+d.Format("yyyy-MM-dd"), KindDaily, "", lines[0], message, published))
+		}
+	}
+
+	for _, spike := range f.moves(from.Time(), now) {
+		lines := messageLines(spike.Message())
+		title := lines[0]
+		if len(lines) > 1 {
+			title += " — " + lines[1]
+		}
+		id := "move:" + spike.Asset.Key + ":" + spike.Time.UTC().Format("20060102T150405Z")
+		entries = append(entries, newEntry(id, KindMove, spike.Asset.Key, title, spike.Message(), spike.Time))
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Published.After(entries[j].Published) })
+	return entries
+}
+
+// moves replays the history of every asset through a detector, starting a
+// day early so its windows are warm, and returns the spikes in [from, to].
+func (f *Feed) moves(from, to time.Time) []anomaly.Spike {
+	detector := anomaly.New(f.cfg.Anomaly)
+	var spikes []anomaly.Spike
+	for _, asset := range price.Assets {
+		for _, p := range f.store.Range(asset.Key, from.Add(-24*time.Hour), to.Add(time.Nanosecond)) {
+			if spike, ok := detector.Replay(asset, p.Time, p.Value); ok && !spike.Time.Before(from) {
+				spikes = append(spikes, spike)
+			}
+		}
+	}
+	return spikes
+}
+
+func newEntry(id, kind, asset, title, message string, published time.Time) Entry {
+	jtime := utils.NewJTime(published)
+	jalali := jtime.Format("yyyy/MM/dd HH:mm")
+	gregorian := jtime.Time().Format("2006-01-02 15:04")
+	lines := append(messageLines(message), "📆 "+jalali+" | "+gregorian)
+
+	return Entry{
+		ID:        id,
+		Kind:      kind,
+		Asset:     asset,
+		Title:     plain(title),
+		HTML:      strings.Join(lines, "<br>\n"),
+		Text:      plain(strings.Join(lines, "\n")),
+		Published: jtime.Time(),
+		Jalali:    jalali,
+		Gregorian: gregorian,
+	}
+}
+
+// messageLines splits a Telegram message into its non-empty lines, without
+// the "ا" each line starts with to keep Telegram right-to-left.
+func messageLines(message string) []string {
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "ا"))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+var tag = regexp.MustCompile(`<[^>]*>`)
+
+// plain strips the HTML of a message.
+func plain(s string) string {
+	return html.UnescapeString(tag.ReplaceAllString(s, ""))
+}
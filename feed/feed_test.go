@@ -0,0 +1,125 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/anomaly"
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/market"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/utils"
+)
+
+// testFeed records two days of usd history, 1404/02/15 and 1404/02/16, with
+// a 5% jump on the second day.
+func testFeed(t *testing.T) (*Feed, time.Time) {
+	store, _ := history.Open("")
+	day := time.Date(2025, 5, 5, 9, 0, 0, 0, utils.Tehran)
+	values := []struct {
+		at    time.Time
+		value string
+	}{
+		{day, "80000"},
+		{day.Add(time.Hour), "80400"},
+		{day.Add(24 * time.Hour), "80400"},
+		{day.Add(24*time.Hour + 5*time.Minute), "84500"},
+		{day.Add(25 * time.Hour), "84000"},
+	}
+	for _, v := range values {
+		if err := store.Record(v.at, map[string]price.Decimal{"usd": price.MustParseDecimal(v.value)}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	f := New(store, market.Default(), Config{
+		Title:   "prices",
+		Days:    30,
+		DailyAt: 19*time.Hour + 30*time.Minute,
+		Anomaly: anomaly.DefaultConfig(),
+		TTL:     time.Minute,
+	})
+	return f, day.Add(24*time.Hour + 3*time.Hour)
+}
+
+func TestFeed_Entries(t *testing.T) {
+	f, now := testFeed(t)
+
+	entries := f.Entries(now)
+	if len(entries) != 2 {
+		t.Fatalf("Expected a move and yesterday's summary, got %+v", entries)
+	}
+
+	move, daily := entries[0], entries[1]
+	if move.Kind != KindMove || move.Asset != "usd" || move.ID != "move:usd:20250506T053500Z" {
+		t.Errorf("Expected usd move first, got %+v", move)
+	}
+	if !strings.HasPrefix(move.Title, "🚨 جهش قیمت — 🇺🇸 دلار امریکا (5.10%🟢)") {
+		t.Errorf("Expected spike title, got %q", move.Title)
+	}
+	if move.Jalali != "1404/02/16 09:05" || move.Gregorian != "2025-05-06 09:05" {
+		t.Errorf("Expected both dates, got %q and %q", move.Jalali, move.Gregorian)
+	}
+
+	if daily.Kind != KindDaily || daily.ID != "daily:1404-02-15" || daily.Title != "📊 خلاصه بازار دوشنبه 15 اردیبهشت 1404" {
+		t.Errorf("Expected summary of 1404/02/15, got %+v", daily)
+	}
+	if !strings.Contains(daily.HTML, "<b>80,400</b>") || strings.Contains(daily.HTML, "\nا") {
+		t.Errorf("Expected HTML without line prefixes, got %q", daily.HTML)
+	}
+	if !strings.Contains(daily.Text, "پایانی 80,400") || !strings.HasSuffix(daily.Text, "📆 1404/02/15 19:30 | 2025-05-05 19:30") {
+		t.Errorf("Expected plain text with dates, got %q", daily.Text)
+	}
+
+	if later := f.Entries(now.Add(17 * time.Hour)); len(later) != 3 || later[0].ID != "daily:1404-02-16" {
+		t.Errorf("Expected today's summary after the TTL, got %+v", later)
+	}
+}
+
+func TestFeed_Handlers(t *testing.T) {
+	f, now := testFeed(t)
+	f.Entries(now)
+	f.generatedAt = time.Now() // serve the entries generated at now
+
+	rec := httptest.NewRecorder()
+	f.AtomHandler().ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/feed.atom", nil))
+	var atom atomFeed
+	if err := xml.Unmarshal(rec.Body.Bytes(), &atom); err != nil {
+		t.Fatalf("Expected valid Atom, got %v", err)
+	}
+	if atom.ID != "tag:pricebot,2025:/feed.atom" || atom.Links[0].Href != "http://example.com/feed.atom" || len(atom.Entries) != 2 || atom.Updated != "2025-05-06T09:05:00+03:30" {
+		t.Errorf("Expected Atom feed with 2 entries, got %+v", atom)
+	}
+	if e := atom.Entries[1]; e.ID != "tag:pricebot,2025:/feed/daily:1404-02-15" || e.Content.Type != "html" || e.Category.Term != KindDaily {
+		t.Errorf("Expected daily Atom entry, got %+v", e)
+	}
+
+	rec = httptest.NewRecorder()
+	f.JSONHandler().ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/feed.json", nil))
+	var feed jsonFeed
+	if err := json.Unmarshal(rec.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("Expected valid JSON Feed, got %v", err)
+	}
+	if feed.Version != "https://jsonfeed.org/version/1.1" || len(feed.Items) != 2 {
+		t.Errorf("Expected JSON Feed with 2 items, got %+v", feed)
+	}
+	if ext := feed.Items[0].Pricebot; ext.Kind != KindMove || ext.Asset != "usd" || ext.Jalali != "1404/02/16 09:05" {
+		t.Errorf("Expected move extension, got %+v", ext)
+	}
+
+	// with a base URL, IDs and links ignore the host and proxy headers
+	f.cfg.BaseURL = "https://prices.example.org"
+	req := httptest.NewRequest("GET", "http://10.0.0.5:8080/feed.json", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec = httptest.NewRecorder()
+	f.JSONHandler().ServeHTTP(rec, req)
+	feed = jsonFeed{}
+	json.Unmarshal(rec.Body.Bytes(), &feed)
+	if feed.FeedURL != "https://prices.example.org/feed.json" || feed.Items[1].ID != "https://prices.example.org/feed/daily:1404-02-15" {
+		t.Errorf("Expected IDs and links under the base URL, got %s and %s", feed.FeedURL, feed.Items[1].ID)
+	}
+}
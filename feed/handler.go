@@ -0,0 +1,182 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"time"
+)
+
+const (
+	atomPath = "/feed.atom"
+	jsonPath = "/feed.json"
+)
+
+// Register serves the Atom feed at /feed.atom and the JSON Feed at
+// /feed.json on mux.
+func (f *Feed) Register(mux *http.ServeMux) {
+	mux.Handle(atomPath, f.AtomHandler())
+	mux.Handle(jsonPath, f.JSONHandler())
+}
+
+// tagPrefix starts the IDs of feeds without a BaseURL.
+const tagPrefix = "tag:pricebot,2025:"
+
+// id returns the ID of the feed or entry at path. IDs must never change, so
+// they don't follow the Host and X-Forwarded-Proto of the request, which
+// differ with the way the feed is reached.
+func (f *Feed) id(path string) string {
+	if f.cfg.BaseURL != "" {
+		return f.cfg.BaseURL + path
+	}
+	return tagPrefix + path
+}
+
+// baseURL is the base URL links point to: BaseURL, or else the scheme and
+// host the feed was requested at.
+func (f *Feed) baseURL(r *http.Request) string {
+	if f.cfg.BaseURL != "" {
+		return f.cfg.BaseURL
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang    string      `xml:"xml:lang,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Published string       `xml:"published"`
+	Updated   string       `xml:"updated"`
+	Category  atomCategory `xml:"category"`
+	Summary   string       `xml:"summary"`
+	Content   atomContent  `xml:"content"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// AtomHandler serves the entries as an Atom feed.
+func (f *Feed) AtomHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		entries := f.Entries(now)
+		base := f.baseURL(r)
+
+		feed := atomFeed{
+			Lang:    "fa",
+			ID:      f.id(atomPath),
+			Title:   f.cfg.Title,
+			Updated: updated(entries, now).Format(time.RFC3339),
+			Links:   []atomLink{{Rel: "self", Href: base + atomPath}, {Rel: "alternate", Href: base + jsonPath}},
+			Author:  atomAuthor{Name: f.cfg.Title},
+		}
+		for _, e := range entries {
+			feed.Entries = append(feed.Entries, atomEntry{
+				ID:        f.id("/feed/" + e.ID),
+				Title:     e.Title,
+				Published: e.Published.Format(time.RFC3339),
+				Updated:   e.Published.Format(time.RFC3339),
+				Category:  atomCategory{Term: e.Kind},
+				Summary:   e.Jalali + " | " + e.Gregorian,
+				Content:   atomContent{Type: "html", Body: e.HTML},
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		w.Write([]byte(xml.Header))
+		xml.NewEncoder(w).Encode(feed)
+	})
+}
+
+type jsonFeed struct {
+	Version  string     `json:"version"`
+	Title    string     `json:"title"`
+	FeedURL  string     `json:"feed_url"`
+	Language string     `json:"language"`
+	Items    []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	ID            string   `json:"id"`
+	Title         string   `json:"title"`
+	ContentHTML   string   `json:"content_html"`
+	ContentText   string   `json:"content_text"`
+	DatePublished string   `json:"date_published"`
+	Tags          []string `json:"tags"`
+	Pricebot      jsonExt  `json:"_pricebot"`
+}
+
+// jsonExt is the feed's JSON Feed extension.
+type jsonExt struct {
+	Kind      string `json:"kind"`
+	Asset     string `json:"asset,omitempty"`
+	Jalali    string `json:"jalali"`
+	Gregorian string `json:"gregorian"`
+}
+
+// JSONHandler serves the entries as a JSON Feed 1.1.
+func (f *Feed) JSONHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entries := f.Entries(time.Now())
+		base := f.baseURL(r)
+
+		feed := jsonFeed{
+			Version:  "https://jsonfeed.org/version/1.1",
+			Title:    f.cfg.Title,
+			FeedURL:  base + jsonPath,
+			Language: "fa",
+			Items:    []jsonItem{},
+		}
+		for _, e := range entries {
+			feed.Items = append(feed.Items, jsonItem{
+				ID:            f.id("/feed/" + e.ID),
+				Title:         e.Title,
+				ContentHTML:   e.HTML,
+				ContentText:   e.Text,
+				DatePublished: e.Published.Format(time.RFC3339),
+				Tags:          []string{e.Kind},
+				Pricebot:      jsonExt{Kind: e.Kind, Asset: e.Asset, Jalali: e.Jalali, Gregorian: e.Gregorian},
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+		json.NewEncoder(w).Encode(feed)
+	})
+}
+
+// updated is when the newest entry was published, or now for an empty feed.
+func updated(entries []Entry, now time.Time) time.Time {
+	if len(entries) == 0 {
+		return now
+	}
+	return entries[0].Published
+}
@@ -0,0 +1,8 @@
+// Package filelock keeps two processes from writing the same file at once,
+// through an exclusive lock on a file beside it.
+package filelock
+
+import "errors"
+
+// ErrLocked is returned by Lock while another process holds the lock.
+var ErrLocked = errors.New("file is locked by another process")
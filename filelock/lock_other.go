@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package filelock
+
+import "os"
+
+// Lock only creates path where there is no file locking; writers are
+// not kept apart there.
+func Lock(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+}
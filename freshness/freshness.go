@@ -0,0 +1,163 @@
+package freshness
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/onionj/pricebot/market"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/utils"
+)
+
+// Level is how current an asset's price is.
+type Level string
+
+const (
+	Fresh   Level = "fresh"
+	Delayed Level = "delayed"
+	Frozen  Level = "frozen"
+)
+
+// Cadence is how much trading time may pass without an update before an
+// asset counts as delayed or frozen.
+type Cadence struct {
+	Delayed time.Duration
+	Frozen  time.Duration
+}
+
+// DefaultCadences are the expected update cadences per asset group.
+var DefaultCadences = map[price.Group]Cadence{
+	price.GroupCurrency: {Delayed: 15 * time.Minute, Frozen: time.Hour},
+	price.GroupCoin:     {Delayed: 30 * time.Minute, Frozen: 2 * time.Hour},
+	price.GroupGold:     {Delayed: 30 * time.Minute, Frozen: 2 * time.Hour},
+	price.GroupCrypto:   {Delayed: 10 * time.Minute, Frozen: 30 * time.Minute},
+}
+
+// MarketOf returns the market whose hours an asset group follows.
+func MarketOf(g price.Group) market.Market {
+	switch g {
+	case price.GroupCrypto:
+		return market.Crypto
+	case price.GroupCoin, price.GroupGold:
+		return market.Gold
+	}
+	return market.Currency
+}
+
+// Status is the freshness of one asset.
+type Status struct {
+	Asset   price.Asset
+	Updated time.Time // when tgju last changed the price
+	// Age is the wall time since Updated, TradingAge only counts the time
+	// the asset's market was open
+	Age        time.Duration
+	TradingAge time.Duration
+	Level      Level
+	MarketOpen bool
+}
+
+// Mark is the sign the live message shows next to the asset: ⏳ when
+// delayed, ⚠️ when frozen, and 🔒 when the market is closed and the price
+// has not moved for a while.
+func (s Status) Mark() string {
+	switch {
+	case s.Level == Frozen:
+		return "⚠️"
+	case s.Level == Delayed:
+		return "⏳"
+	case !s.MarketOpen && s.Age > time.Hour:
+		return "🔒"
+	}
+	return ""
+}
+
+// Model judges asset freshness from tgju's update timestamps, the expected
+// cadences and the market calendar.
+type Model struct {
+	calendar *market.Calendar
+	// Cadences override DefaultCadences per asset key
+	Cadences map[string]Cadence
+}
+
+// New returns a model using calendar and the default cadences.
+func New(calendar *market.Calendar) *Model {
+	return &Model{calendar: calendar, Cadences: make(map[string]Cadence)}
+}
+
+func (m *Model) cadence(asset price.Asset) Cadence {
+	if c, ok := m.Cadences[asset.Key]; ok {
+		return c
+	}
+	return DefaultCadences[asset.Group]
+}
+
+// Check returns the freshness of asset at now. ok is false when the entry
+// carries no usable timestamp.
+func (m *Model) Check(asset price.Asset, d price.Detail, now time.Time) (Status, bool) {
+	updated, err := d.Updated()
+	if err != nil {
+		return Status{}, false
+	}
+
+	mkt := MarketOf(asset.Group)
+	status := Status{
+		Asset:      asset,
+		Updated:    updated,
+		Age:        max(now.Sub(updated), 0),
+		TradingAge: m.calendar.OpenDuration(mkt, updated, now),
+		Level:      Fresh,
+		MarketOpen: m.calendar.IsOpen(mkt, now),
+	}
+
+	cadence := m.cadence(asset)
+	switch {
+	case status.TradingAge >= cadence.Frozen:
+		status.Level = Frozen
+	case status.TradingAge >= cadence.Delayed:
+		status.Level = Delayed
+	}
+	return status, true
+}
+
+// CheckAll returns the freshness of every asset in c with a timestamp.
+func (m *Model) CheckAll(c *price.CurrentData, now time.Time) []Status {
+	var result []Status
+	for _, asset := range price.Assets {
+		if status, ok := m.Check(asset, asset.Detail(c), now); ok {
+			result = append(result, status)
+		}
+	}
+	return result
+}
+
+// Marks returns the live message marks of statuses by asset key.
+func Marks(statuses []Status) map[string]string {
+	marks := make(map[string]string, len(statuses))
+	for _, s := range statuses {
+		marks[s.Asset.Key] = s.Mark()
+	}
+	return marks
+}
+
+// Stalled reports whether no asset at all was updated within after. Crypto
+// trades around the clock, so this points to a broken provider rather than a
+// closed market.
+func Stalled(statuses []Status, after time.Duration) (time.Time, bool) {
+	if len(statuses) == 0 {
+		return time.Time{}, false
+	}
+
+	var latest Status
+	for _, s := range statuses {
+		if s.Updated.After(latest.Updated) {
+			latest = s
+		}
+	}
+	return latest.Updated, latest.Age >= after
+}
+
+// StalledMessage renders the bot-wide alert for prices last updated at since.
+func StalledMessage(since time.Time) string {
+	return fmt.Sprintf("ا⚠️ <b>قیمت‌ها از %s بروزرسانی نشده‌اند</b>\nا   منبع قیمت در دسترس نیست؛ قیمت‌های نمایش داده شده ممکن است قدیمی باشند.",
+		utils.NewJTime(since).Format("EEEE HH:mm"))
+}
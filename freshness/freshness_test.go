@@ -0,0 +1,107 @@
+package freshness
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/market"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/utils"
+)
+
+func tehran(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, utils.Tehran)
+}
+
+func detail(updated time.Time) price.Detail {
+	return price.Detail{Price: "800000", DateTime: updated.Format("2006-01-02 15:04:05")}
+}
+
+func TestModel_Check(t *testing.T) {
+	m := New(market.Default())
+	usd, _ := price.FindAsset("usd")
+	btc, _ := price.FindAsset("btc")
+	tuesday := tehran(2025, 5, 6, 11, 0)
+	friday := tehran(2025, 5, 9, 12, 0)
+
+	testCases := []struct {
+		name    string
+		asset   price.Asset
+		updated time.Time
+		now     time.Time
+		level   Level
+		mark    string
+	}{
+		{"recent", usd, tuesday.Add(-5 * time.Minute), tuesday, Fresh, ""},
+		{"delayed", usd, tuesday.Add(-20 * time.Minute), tuesday, Delayed, "⏳"},
+		{"frozen", usd, tuesday.Add(-90 * time.Minute), tuesday, Frozen, "⚠️"},
+		{"before the open", usd, tehran(2025, 5, 5, 17, 55), tehran(2025, 5, 6, 9, 5), Fresh, ""},
+		{"market closed", usd, tehran(2025, 5, 8, 12, 55), friday, Fresh, "🔒"},
+		{"crypto on friday", btc, friday.Add(-40 * time.Minute), friday, Frozen, "⚠️"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, ok := m.Check(tc.asset, detail(tc.updated), tc.now)
+			if !ok {
+				t.Fatal("Expected a status")
+			}
+			if status.Level != tc.level {
+				t.Errorf("Level = %s; want %s (trading age %s)", status.Level, tc.level, status.TradingAge)
+			}
+			if status.Mark() != tc.mark {
+				t.Errorf("Mark() = %q; want %q", status.Mark(), tc.mark)
+			}
+		})
+	}
+
+	if _, ok := m.Check(usd, price.Detail{Price: "800000"}, tuesday); ok {
+		t.Error("Expected no status without a timestamp")
+	}
+
+	m.Cadences["usd"] = Cadence{Delayed: time.Hour, Frozen: 2 * time.Hour}
+	if status, _ := m.Check(usd, detail(tuesday.Add(-20*time.Minute)), tuesday); status.Level != Fresh {
+		t.Errorf("Expected the usd cadence override to apply, got %s", status.Level)
+	}
+}
+
+func TestStalled(t *testing.T) {
+	m := New(market.Default())
+	now := tehran(2025, 5, 9, 12, 0)
+
+	c := &price.CurrentData{
+		Dollar:  detail(tehran(2025, 5, 8, 12, 55)),
+		BitCoin: detail(now.Add(-10 * time.Minute)),
+	}
+	if _, stalled := Stalled(m.CheckAll(c, now), time.Hour); stalled {
+		t.Error("Expected no stall while crypto keeps updating")
+	}
+
+	c.BitCoin = detail(now.Add(-2 * time.Hour))
+	since, stalled := Stalled(m.CheckAll(c, now), time.Hour)
+	if !stalled || !since.Equal(now.Add(-2*time.Hour)) {
+		t.Errorf("Stalled = %s, %v; want stall since the last crypto update", since, stalled)
+	}
+	if message := StalledMessage(since); !strings.Contains(message, "جمعه 10:00") {
+		t.Errorf("Unexpected stall message %s", message)
+	}
+
+	if _, stalled := Stalled(nil, time.Hour); stalled {
+		t.Error("Expected no stall without any status")
+	}
+}
+
+func TestMarks(t *testing.T) {
+	m := New(market.Default())
+	now := tehran(2025, 5, 6, 11, 0)
+	c := &price.CurrentData{
+		Dollar: detail(now.Add(-time.Minute)),
+		Eur:    detail(now.Add(-30 * time.Minute)),
+	}
+
+	marks := Marks(m.CheckAll(c, now))
+	if len(marks) != 2 || marks["usd"] != "" || marks["eur"] != "⏳" {
+		t.Errorf("Unexpected marks %v", marks)
+	}
+}
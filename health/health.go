@@ -0,0 +1,228 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onionj/pricebot/utils"
+)
+
+// recentErrorsLimit is how many errors the status page keeps.
+const recentErrorsLimit = 20
+
+type ErrorEntry struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+	Error  string    `json:"error"`
+}
+
+// Monitor collects the bot's runtime signals and serves them as
+// liveness, readiness and status endpoints.
+type Monitor struct {
+	mu sync.Mutex
+
+	started         time.Time
+	lastRefresh     time.Time
+	editFailures    int
+	messages        map[string]int
+	errors          []ErrorEntry
+	maxRefreshAge   time.Duration
+	maxEditFailures int
+
+	now func() time.Time
+}
+
+// NewMonitor creates a Monitor that reports not ready when the last
+// successful refresh is older than maxRefreshAge or when maxEditFailures
+// edits in a row have failed.
+func NewMonitor(maxRefreshAge time.Duration, maxEditFailures int) *Monitor {
+	return &Monitor{
+		started:         time.Now(),
+		messages:        make(map[string]int),
+		maxRefreshAge:   maxRefreshAge,
+		maxEditFailures: maxEditFailures,
+		now:             time.Now,
+	}
+}
+
+func (m *Monitor) RefreshSucceeded(at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastRefresh = at
+}
+
+func (m *Monitor) RefreshFailed(err error) {
+	m.recordError("refresh", err)
+}
+
+// MessageSucceeded records a successful send or edit of messageID in chatID.
+func (m *Monitor) MessageSucceeded(chatID string, messageID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.editFailures = 0
+	m.messages[chatID] = messageID
+}
+
+// MessageFailed records a failed send or edit in chatID.
+func (m *Monitor) MessageFailed(chatID string, err error) {
+	m.mu.Lock()
+	m.editFailures++
+	m.mu.Unlock()
+	m.recordError("telegram:"+chatID, err)
+}
+
+// PublishFailed records a failed send or edit by a publisher other than the
+// Telegram channel. It does not affect readiness.
+func (m *Monitor) PublishFailed(publisher string, err error) {
+	m.recordError("publish:"+publisher, err)
+}
+
+// JobFailed records a failed run of a scheduled job.
+func (m *Monitor) JobFailed(job string, err error) {
+	m.recordError("job:"+job, err)
+}
+
+func (m *Monitor) recordError(source string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors = append(m.errors, ErrorEntry{Time: m.now(), Source: source, Error: err.Error()})
+	if len(m.errors) > recentErrorsLimit {
+		m.errors = m.errors[len(m.errors)-recentErrorsLimit:]
+	}
+}
+
+// Ready returns nil when the bot is serving fresh prices, otherwise the reason it is not.
+func (m *Monitor) Ready() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lastRefresh.IsZero() {
+		return fmt.Errorf("no successful price refresh yet")
+	}
+	if age := m.now().Sub(m.lastRefresh); age > m.maxRefreshAge {
+		return fmt.Errorf("last price refresh is %s old", age.Round(time.Second))
+	}
+	if m.maxEditFailures > 0 && m.editFailures >= m.maxEditFailures {
+		return fmt.Errorf("%d telegram requests failed in a row", m.editFailures)
+	}
+	return nil
+}
+
+type Status struct {
+	Ready              bool           `json:"ready"`
+	Reason             string         `json:"reason,omitempty"`
+	Uptime             string         `json:"uptime"`
+	LastRefresh        time.Time      `json:"last_refresh"`
+	JLastRefresh       string         `json:"jalali_last_refresh"`
+	ConsecutiveFailure int            `json:"consecutive_telegram_failures"`
+	Messages           map[string]int `json:"messages"`
+	RecentErrors       []ErrorEntry   `json:"recent_errors"`
+}
+
+// Status returns a snapshot of the collected signals.
+func (m *Monitor) Status() Status {
+	readyErr := m.Ready()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := Status{
+		Ready:              readyErr == nil,
+		Uptime:             m.now().Sub(m.started).Round(time.Second).String(),
+		LastRefresh:        m.lastRefresh,
+		ConsecutiveFailure: m.editFailures,
+		Messages:           make(map[string]int, len(m.messages)),
+		RecentErrors:       make([]ErrorEntry, len(m.errors)),
+	}
+	if readyErr != nil {
+		s.Reason = readyErr.Error()
+	}
+	if !m.lastRefresh.IsZero() {
+		s.JLastRefresh = fmt.Sprintf("%s %s",
+			utils.GregorianToJalali(m.lastRefresh.Year(), int(m.lastRefresh.Month()), m.lastRefresh.Day()),
+			m.lastRefresh.Format("15:04:05"))
+	}
+	for chat, id := range m.messages {
+		s.Messages[chat] = id
+	}
+	// newest first
+	for i, e := range m.errors {
+		s.RecentErrors[len(m.errors)-1-i] = e
+	}
+	return s
+}
+
+// Handler serves /healthz, /readyz and /status.
+func (m *Monitor) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", m.handleHealthz)
+	mux.HandleFunc("/readyz", m.handleReadyz)
+	mux.HandleFunc("/status", m.handleStatus)
+	return mux
+}
+
+func (m *Monitor) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+func (m *Monitor) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if err := m.Ready(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func (m *Monitor) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := m.Status()
+
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+		return
+	}
+
+	chats := make([]string, 0, len(status.Messages))
+	for chat := range status.Messages {
+		chats = append(chats, chat)
+	}
+	sort.Strings(chats)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	statusTemplate.Execute(w, struct {
+		Status
+		Chats []string
+	}{status, chats})
+}
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>pricebot status</title></head>
+<body>
+<h1>pricebot {{if .Ready}}ready{{else}}not ready{{end}}</h1>
+{{if .Reason}}<p>{{.Reason}}</p>{{end}}
+<table>
+<tr><th>Uptime</th><td>{{.Uptime}}</td></tr>
+<tr><th>Last refresh</th><td>{{if .LastRefresh.IsZero}}never{{else}}{{.LastRefresh.Format "2006-01-02 15:04:05 MST"}}{{end}}</td></tr>
+<tr><th>Last refresh (Jalali)</th><td>{{.JLastRefresh}}</td></tr>
+<tr><th>Telegram failures in a row</th><td>{{.ConsecutiveFailure}}</td></tr>
+</table>
+<h2>Messages</h2>
+<table>
+<tr><th>Chat</th><th>Message ID</th></tr>
+{{range .Chats}}<tr><td>{{.}}</td><td>{{index $.Messages .}}</td></tr>
+{{end}}</table>
+<h2>Recent errors</h2>
+<table>
+<tr><th>Time</th><th>Source</th><th>Error</th></tr>
+{{range .RecentErrors}}<tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.Source}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,117 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMonitor_Ready(t *testing.T) {
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+	m := NewMonitor(5*time.Minute, 3)
+	m.now = func() time.Time { return now }
+
+	if err := m.Ready(); err == nil {
+		t.Error("Expected not ready before the first refresh")
+	}
+
+	m.RefreshSucceeded(now.Add(-time.Minute))
+	if err := m.Ready(); err != nil {
+		t.Errorf("Expected ready after a fresh refresh, got %v", err)
+	}
+
+	m.RefreshSucceeded(now.Add(-6 * time.Minute))
+	if err := m.Ready(); err == nil {
+		t.Error("Expected not ready with a stale refresh")
+	}
+
+	m.RefreshSucceeded(now)
+	for i := 0; i < 3; i++ {
+		m.MessageFailed("chat", errors.New("edit failed"))
+	}
+	if err := m.Ready(); err == nil {
+		t.Error("Expected not ready after 3 failed edits")
+	}
+
+	m.MessageSucceeded("chat", 42)
+	if err := m.Ready(); err != nil {
+		t.Errorf("Expected ready after a successful edit, got %v", err)
+	}
+}
+
+func TestMonitor_Handler(t *testing.T) {
+	m := NewMonitor(5*time.Minute, 3)
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+
+	testCases := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/healthz", http.StatusOK},
+		{"/readyz", http.StatusServiceUnavailable},
+		{"/status", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		resp, err := http.Get(server.URL + tc.path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", tc.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.wantStatus {
+			t.Errorf("GET %s = %d; want %d", tc.path, resp.StatusCode, tc.wantStatus)
+		}
+	}
+
+	m.RefreshSucceeded(time.Now())
+	resp, err := http.Get(server.URL + "/readyz")
+	if err != nil {
+		t.Fatalf("GET /readyz failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /readyz after refresh = %d; want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestMonitor_Status(t *testing.T) {
+	m := NewMonitor(5*time.Minute, 3)
+	m.RefreshSucceeded(time.Date(2025, 3, 20, 12, 30, 0, 0, time.UTC))
+	m.MessageSucceeded("@channel", 123)
+	for i := 0; i < recentErrorsLimit+5; i++ {
+		m.RefreshFailed(errors.New("provider down"))
+	}
+	m.MessageFailed("@channel", errors.New("latest"))
+
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/status?format=json")
+	if err != nil {
+		t.Fatalf("GET /status failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var status Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+
+	if status.JLastRefresh != "1403/12/30 12:30:00" {
+		t.Errorf("Expected Jalali refresh '1403/12/30 12:30:00', got '%s'", status.JLastRefresh)
+	}
+	if status.Messages["@channel"] != 123 {
+		t.Errorf("Expected message 123 for @channel, got %d", status.Messages["@channel"])
+	}
+	if len(status.RecentErrors) != recentErrorsLimit {
+		t.Errorf("Expected %d recent errors, got %d", recentErrorsLimit, len(status.RecentErrors))
+	}
+	if status.RecentErrors[0].Error != "latest" || !strings.HasPrefix(status.RecentErrors[0].Source, "telegram") {
+		t.Errorf("Expected newest error first, got %+v", status.RecentErrors[0])
+	}
+}
@@ -0,0 +1,326 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/onionj/pricebot/price"
+)
+
+// Point is one recorded value of an asset.
+type Point struct {
+	Time  time.Time
+	Value price.Decimal
+}
+
+// record is one line of the history file.
+type record struct {
+	Time  int64         `json:"t"`
+	Asset string        `json:"a"`
+	Value price.Decimal `json:"v"`
+}
+
+// Store keeps per-asset price series in memory, backed by an append-only
+// JSON lines file. Only changes are recorded: a value equal to the asset's
+// previous value is skipped.
+type Store struct {
+	mu     sync.RWMutex
+	path   string
+	series map[string][]Point
+	lock   *os.File // held from OpenExclusive until Close
+}
+
+// Open loads the history file at path, creating it on first write. An empty
+// path keeps history in memory only. A last line torn by a crash mid-append
+// is left out; a bad line anywhere else fails Open.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, series: make(map[string][]Point)}
+	if path == "" {
+		return s, nil
+	}
+	if err := s.load(false); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the history file. With repair it truncates a torn last line
+// and ends a last line missing its newline, so appends start on a new line.
+func (s *Store) load(repair bool) error {
+	flag := os.O_RDONLY
+	if repair {
+		flag = os.O_RDWR
+	}
+	f, err := os.OpenFile(s.path, flag, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var good int64
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(data) == 0 {
+			return nil
+		}
+
+		var rec record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			if _, peekErr := r.Peek(1); peekErr != io.EOF {
+				return fmt.Errorf("history line %d: %w", line, err)
+			}
+			// only the last line can be torn by a crash mid-append
+			if !repair {
+				return nil
+			}
+			if err := f.Truncate(good); err != nil {
+				return err
+			}
+			return f.Sync()
+		}
+		s.insert(rec.Asset, Point{Time: time.Unix(rec.Time, 0), Value: rec.Value})
+		good += int64(len(data))
+
+		if repair && data[len(data)-1] != '\n' {
+			if _, err := f.WriteAt([]byte{'\n'}, good); err != nil {
+				return err
+			}
+			return f.Sync()
+		}
+	}
+}
+
+// insert adds p keeping the series sorted by time.
+func (s *Store) insert(asset string, p Point) {
+	points := s.series[asset]
+	if n := len(points); n == 0 || !p.Time.Before(points[n-1].Time) {
+		s.series[asset] = append(points, p)
+		return
+	}
+	i := sort.Search(len(points), func(i int) bool { return points[i].Time.After(p.Time) })
+	points = append(points, Point{})
+	copy(points[i+1:], points[i:])
+	points[i] = p
+	s.series[asset] = points
+}
+
+// Record stores the values observed at t.
+func (s *Store) Record(t time.Time, values map[string]price.Decimal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changed []record
+	for asset, value := range values {
+		if last, ok := s.lastLocked(asset); ok && last.Value.Equal(value) {
+			continue
+		}
+		s.insert(asset, Point{Time: t, Value: value})
+		changed = append(changed, record{Time: t.Unix(), Asset: asset, Value: value})
+	}
+
+	if s.path == "" || len(changed) == 0 {
+		return nil
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Asset < changed[j].Asset })
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, r := range changed {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+func (s *Store) lastLocked(asset string) (Point, bool) {
+	points := s.series[asset]
+	if len(points) == 0 {
+		return Point{}, false
+	}
+	return points[len(points)-1], true
+}
+
+// Import merges points of asset recorded elsewhere, such as by a backfill,
+// and rewrites the history file. Points at a time the series already has,
+// and points equal to the value in effect before them, are skipped. It
+// returns how many points were added.
+func (s *Store) Import(asset string, points []Point) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added := 0
+	for _, p := range points {
+		series := s.series[asset]
+		i := sort.Search(len(series), func(i int) bool { return series[i].Time.After(p.Time) })
+		if i > 0 && (series[i-1].Time.Equal(p.Time) || series[i-1].Value.Equal(p.Value)) {
+			continue
+		}
+		s.insert(asset, p)
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	return added, s.rewriteLocked()
+}
+
+// Assets returns the keys of every asset with recorded history.
+func (s *Store) Assets() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.series))
+	for key := range s.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Range returns the points of asset with from <= Time < to.
+func (s *Store) Range(asset string, from, to time.Time) []Point {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	points := s.series[asset]
+	start := sort.Search(len(points), func(i int) bool { return !points[i].Time.Before(from) })
+	end := sort.Search(len(points), func(i int) bool { return !points[i].Time.Before(to) })
+	if start >= end {
+		return nil
+	}
+	return append([]Point(nil), points[start:end]...)
+}
+
+// At returns the value of asset in effect at t: the last point at or before t.
+func (s *Store) At(asset string, t time.Time) (Point, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	points := s.series[asset]
+	i := sort.Search(len(points), func(i int) bool { return points[i].Time.After(t) })
+	if i == 0 {
+		return Point{}, false
+	}
+	return points[i-1], true
+}
+
+// OHLC summarizes an asset over a period.
+type OHLC struct {
+	Open, High, Low, Close price.Decimal
+	// PrevClose is the value in effect just before the period, zero if unknown
+	PrevClose price.Decimal
+}
+
+// Change is the percent change of Close versus PrevClose, or versus Open
+// when the previous close is unknown.
+func (o OHLC) Change() float64 {
+	base := o.PrevClose
+	if base.IsZero() {
+		base = o.Open
+	}
+	return o.Close.PercentChange(base)
+}
+
+// OHLC returns open, high, low and close of asset for from <= t < to. A value
+// carried over from before the period counts as the open. ok is false when
+// the asset has no value at all by the end of the period.
+func (s *Store) OHLC(asset string, from, to time.Time) (OHLC, bool) {
+	var result OHLC
+	points := s.Range(asset, from, to)
+
+	if prev, ok := s.At(asset, from.Add(-time.Nanosecond)); ok {
+		result.PrevClose = prev.Value
+		points = append([]Point{{Time: from, Value: prev.Value}}, points...)
+	}
+	if len(points) == 0 {
+		return OHLC{}, false
+	}
+
+	result.Open, result.High, result.Low = points[0].Value, points[0].Value, points[0].Value
+	for _, p := range points {
+		if p.Value.Cmp(result.High) > 0 {
+			result.High = p.Value
+		}
+		if p.Value.Cmp(result.Low) < 0 {
+			result.Low = p.Value
+		}
+	}
+	result.Close = points[len(points)-1].Value
+	return result, true
+}
+
+// Prune drops points older than before, keeping the last one so the value in
+// effect at before stays known, and rewrites the history file.
+func (s *Store) Prune(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for asset, points := range s.series {
+		i := sort.Search(len(points), func(i int) bool { return !points[i].Time.Before(before) })
+		if i > 1 {
+			s.series[asset] = append([]Point(nil), points[i-1:]...)
+		}
+	}
+	return s.rewriteLocked()
+}
+
+// rewriteLocked replaces the history file with the in-memory series.
+func (s *Store) rewriteLocked() error {
+	if s.path == "" {
+		return nil
+	}
+
+	var records []record
+	for asset, points := range s.series {
+		for _, p := range points {
+			records = append(records, record{Time: p.Time.Unix(), Asset: asset, Value: p.Value})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Time != records[j].Time {
+			return records[i].Time < records[j].Time
+		}
+		return records[i].Asset < records[j].Asset
+	})
+
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,220 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/price"
+)
+
+// decimals converts test values to the map Record takes.
+func decimals(values map[string]float64) map[string]price.Decimal {
+	result := make(map[string]price.Decimal, len(values))
+	for key, value := range values {
+		result[key] = price.DecimalFromFloat(value)
+	}
+	return result
+}
+
+func TestStore_RecordAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	start := time.Date(2025, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	steps := []map[string]float64{
+		{"usd": 80000, "eur": 90000},
+		{"usd": 80000, "eur": 91000}, // unchanged usd is skipped
+		{"usd": 82000},
+	}
+	for i, values := range steps {
+		if err := s.Record(start.Add(time.Duration(i)*time.Minute), decimals(values)); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	reloaded, err := Open(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+
+	usd := reloaded.Range("usd", start, start.Add(time.Hour))
+	if len(usd) != 2 || usd[0].Value.String() != "80000" || usd[1].Value.String() != "82000" {
+		t.Errorf("Unexpected usd series after reload: %v", usd)
+	}
+	if got := reloaded.Assets(); len(got) != 2 || got[0] != "eur" || got[1] != "usd" {
+		t.Errorf("Assets() = %v; want [eur usd]", got)
+	}
+
+	p, ok := reloaded.At("usd", start.Add(90*time.Second))
+	if !ok || p.Value.String() != "80000" {
+		t.Errorf("At(+90s) = %v, %v; want 80000", p, ok)
+	}
+	if _, ok := reloaded.At("usd", start.Add(-time.Second)); ok {
+		t.Error("Expected no value before the first point")
+	}
+}
+
+func TestStore_OHLC(t *testing.T) {
+	s, _ := Open("")
+	day := time.Date(2025, 5, 6, 0, 0, 0, 0, time.UTC)
+
+	s.Record(day.Add(-time.Hour), decimals(map[string]float64{"usd": 100}))
+	s.Record(day.Add(9*time.Hour), decimals(map[string]float64{"usd": 104}))
+	s.Record(day.Add(10*time.Hour), decimals(map[string]float64{"usd": 98}))
+	s.Record(day.Add(11*time.Hour), decimals(map[string]float64{"usd": 102}))
+	s.Record(day.Add(25*time.Hour), decimals(map[string]float64{"usd": 200}))
+
+	ohlc, ok := s.OHLC("usd", day, day.Add(24*time.Hour))
+	if !ok {
+		t.Fatal("Expected OHLC for usd")
+	}
+	got := []price.Decimal{ohlc.Open, ohlc.High, ohlc.Low, ohlc.Close, ohlc.PrevClose}
+	for i, expected := range []string{"100", "104", "98", "102", "100"} {
+		if got[i].String() != expected {
+			t.Errorf("OHLC = %v; want open 100, high 104, low 98, close 102, prev 100", got)
+			break
+		}
+	}
+	if ohlc.Change() != 2 {
+		t.Errorf("Change() = %v; want 2", ohlc.Change())
+	}
+
+	if _, ok := s.OHLC("eur", day, day.Add(24*time.Hour)); ok {
+		t.Error("Expected no OHLC for an unknown asset")
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, _ := Open(path)
+	start := time.Date(2025, 5, 6, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		s.Record(start.Add(time.Duration(i)*time.Hour), decimals(map[string]float64{"usd": float64(100 + i)}))
+	}
+
+	if err := s.Prune(start.Add(150 * time.Minute)); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+
+	// the point at 2h stays so the value at the cutoff is known
+	points := s.Range("usd", start, start.Add(24*time.Hour))
+	if len(points) != 3 || points[0].Value.String() != "102" {
+		t.Errorf("Unexpected points after prune: %v", points)
+	}
+
+	reloaded, err := Open(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if got := reloaded.Range("usd", start, start.Add(24*time.Hour)); len(got) != 3 {
+		t.Errorf("Expected 3 points after reload, got %v", got)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("Expected temporary file to be renamed away")
+	}
+}
+
+func TestStore_Import(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	start := time.Date(2025, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	s, _ := Open(path)
+	s.Record(start, decimals(map[string]float64{"usd": 82000}))
+
+	day := -24 * time.Hour
+	added, err := s.Import("usd", []Point{
+		{Time: start.Add(2 * day), Value: price.DecimalFromFloat(80000)},
+		{Time: start.Add(2*day + time.Hour), Value: price.DecimalFromFloat(80000)}, // unchanged
+		{Time: start.Add(day), Value: price.DecimalFromFloat(81000)},
+		{Time: start, Value: price.DecimalFromFloat(70000)}, // already recorded
+	})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if added != 2 {
+		t.Errorf("Expected 2 points added, got %d", added)
+	}
+
+	reloaded, err := Open(path)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	points := reloaded.Range("usd", start.Add(3*day), start.Add(time.Hour))
+	if len(points) != 3 || points[0].Value.String() != "80000" || points[1].Value.String() != "81000" || points[2].Value.String() != "82000" {
+		t.Errorf("Expected 80000, 81000, 82000 in order, got %+v", points)
+	}
+}
+
+func TestOpenExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := OpenExclusive(path)
+	if err != nil {
+		t.Fatalf("OpenExclusive failed: %v", err)
+	}
+	if _, err := OpenExclusive(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked while the file is held, got %v", err)
+	}
+	if _, err := Open(path); err != nil {
+		t.Errorf("Expected readers to open a held file, got %v", err)
+	}
+
+	s.Close()
+	s, err = OpenExclusive(path)
+	if err != nil {
+		t.Fatalf("Expected the lock released by Close, got %v", err)
+	}
+	s.Close()
+}
+
+func TestOpen_TornLine(t *testing.T) {
+	complete := `{"t":1746520200,"a":"usd","v":80000}` + "\n" + `{"t":1746520260,"a":"usd","v":80100}` + "\n"
+	testCases := []struct {
+		name, data string
+		err        bool
+		points     int
+		repaired   string
+	}{
+		{"torn last line", complete + `{"t":17465`, false, 2, complete},
+		{"missing newline", strings.TrimSuffix(complete, "\n"), false, 2, complete},
+		{"bad line in the middle", `{"t":17465` + "\n" + complete, true, 0, ""},
+	}
+	for _, tc := range testCases {
+		path := filepath.Join(t.TempDir(), "history.jsonl")
+		os.WriteFile(path, []byte(tc.data), 0644)
+
+		s, err := Open(path)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected Open to fail", tc.name)
+			}
+			if _, err := OpenExclusive(path); err == nil {
+				t.Errorf("%s: expected OpenExclusive to fail", tc.name)
+			}
+			continue
+		}
+		if err != nil || len(s.Range("usd", time.Unix(0, 0), time.Now())) != tc.points {
+			t.Errorf("%s: expected %d points, got %v", tc.name, tc.points, err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != tc.data {
+			t.Errorf("%s: expected readers to leave the file alone, got %q", tc.name, data)
+		}
+
+		s, err = OpenExclusive(path)
+		if err != nil {
+			t.Fatalf("%s: OpenExclusive failed: %v", tc.name, err)
+		}
+		s.Record(time.Unix(1746520320, 0), decimals(map[string]float64{"usd": 80200}))
+		s.Close()
+		if data, _ := os.ReadFile(path); string(data) != tc.repaired+`{"t":1746520320,"a":"usd","v":80200}`+"\n" {
+			t.Errorf("%s: expected the file repaired before the append, got %q", tc.name, data)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/onionj/pricebot/filelock"
+)
+
+// ErrLocked is returned by OpenExclusive while another process, such as the
+// bot or a backfill, has the history file open for writing.
+var ErrLocked = errors.New("history file is in use by another process")
+
+// OpenExclusive opens the history file at path like Open, cutting off a torn
+// last line, and holds a lock on it until Close so that no two processes
+// write it at once. It fails with ErrLocked rather than wait for the lock.
+// Readers, which never rewrite the file, use Open and take no lock.
+func OpenExclusive(path string) (*Store, error) {
+	if path == "" {
+		return Open("")
+	}
+	lock, err := filelock.Lock(path + ".lock")
+	if errors.Is(err, filelock.ErrLocked) {
+		err = ErrLocked
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	// unlike a reader, the writer can cut off a torn last line, since no one
+	// else is appending to the file
+	s := &Store{path: path, series: make(map[string][]Point)}
+	if err := s.load(true); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	s.lock = lock
+	return s, nil
+}
+
+// Close releases the lock taken by OpenExclusive.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lock == nil {
+		return nil
+	}
+	err := s.lock.Close()
+	s.lock = nil
+	return err
+}
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// ParseLevel maps a level name (debug, info, warn, error) to a slog.Level.
+// An empty name means info.
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if name == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
+
+// New builds a logger writing to w. format is "json" or "text" (the default),
+// and every occurrence of the given secrets is replaced in messages and
+// attribute values, so tokens embedded in URLs or errors never reach the log.
+func New(w io.Writer, format string, level slog.Level, secrets ...string) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactor(secrets),
+	}
+
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+// Discard returns a logger that drops every record.
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func redactor(secrets []string) func([]string, slog.Attr) slog.Attr {
+	var pairs []string
+	for _, s := range secrets {
+		if s != "" {
+			pairs = append(pairs, s, redacted)
+		}
+	}
+	if len(pairs) == 0 {
+		return nil
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	return func(groups []string, a slog.Attr) slog.Attr {
+		switch a.Value.Kind() {
+		case slog.KindString:
+			a.Value = slog.StringValue(replacer.Replace(a.Value.String()))
+		case slog.KindAny:
+			switch v := a.Value.Any().(type) {
+			case error:
+				a.Value = slog.StringValue(replacer.Replace(v.Error()))
+			case fmt.Stringer:
+				a.Value = slog.StringValue(replacer.Replace(v.String()))
+			}
+		}
+		return a
+	}
+}
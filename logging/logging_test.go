@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew_RedactsSecrets(t *testing.T) {
+	token := "123456:ABC-DEF"
+
+	for _, format := range []string{"text", "json"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := New(&buf, format, slog.LevelDebug, token)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			logger.Info("calling https://api.telegram.org/bot"+token+"/sendMessage",
+				"url", "https://api.telegram.org/bot"+token+"/editMessageText",
+				"error", errors.New(`Post "https://api.telegram.org/bot`+token+`/sendMessage": timeout`),
+			)
+
+			out := buf.String()
+			if strings.Contains(out, token) {
+				t.Errorf("Expected token to be redacted, got %s", out)
+			}
+			if strings.Count(out, redacted) != 3 {
+				t.Errorf("Expected 3 redactions, got %s", out)
+			}
+		})
+	}
+}
+
+func TestNew_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "text", slog.LevelWarn)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	logger.Info("hidden")
+	logger.Warn("shown")
+
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Errorf("Unexpected output for warn level: %s", buf.String())
+	}
+}
+
+func TestNew_UnknownFormat(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", slog.LevelInfo); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected slog.Level
+		wantErr  bool
+	}{
+		{"", slog.LevelInfo, false},
+		{"debug", slog.LevelDebug, false},
+		{"WARN", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", slog.LevelInfo, true},
+	}
+
+	for _, tc := range testCases {
+		level, err := ParseLevel(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseLevel(%q) error = %v; wantErr %v", tc.input, err, tc.wantErr)
+		}
+		if level != tc.expected {
+			t.Errorf("ParseLevel(%q) = %v; want %v", tc.input, level, tc.expected)
+		}
+	}
+}
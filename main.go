@@ -1,25 +1,92 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/onionj/pricebot/anomaly"
+	"github.com/onionj/pricebot/digest"
+	"github.com/onionj/pricebot/feed"
+	"github.com/onionj/pricebot/freshness"
+	"github.com/onionj/pricebot/health"
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/logging"
+	"github.com/onionj/pricebot/market"
+	"github.com/onionj/pricebot/portfolio"
 	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/publish"
+	"github.com/onionj/pricebot/report"
+	"github.com/onionj/pricebot/scheduler"
+	"github.com/onionj/pricebot/state"
+	"github.com/onionj/pricebot/subscription"
 	"github.com/onionj/pricebot/telegram"
+	"github.com/onionj/pricebot/utils"
+	"github.com/onionj/pricebot/webhook"
 )
 
 const (
 	NEW_MESSAGE_PERIOD    = 60 * 60 * 12
 	UPDATE_MESSAGE_PERIOD = 4
 	UPDATE_PRICE_PERIOD   = 60
+
+	// slower cadence while the currency and gold markets are closed
+	CLOSED_UPDATE_MESSAGE_PERIOD = 30
+	CLOSED_UPDATE_PRICE_PERIOD   = 60 * 10
+
+	// readiness thresholds for the /readyz probe
+	READY_MAX_REFRESH_AGE   = 5 * time.Minute
+	READY_MAX_EDIT_FAILURES = 5
+
+	// the daily summary is posted at this Tehran time on trading days
+	DAILY_REPORT_HOUR   = 19
+	DAILY_REPORT_MINUTE = 30
+
+	// the Atom and JSON feeds reach back this many days and are regenerated at most once per FEED_TTL
+	FEED_DAYS = 30
+	FEED_TTL  = time.Minute
+
+	// weekly and monthly reports are posted at this Tehran hour on the period's last day
+	PERIOD_REPORT_HOUR = 20
+
+	// default files, in DATA_DIR
+	DEFAULT_HISTORY_FILE = "price_history.jsonl"
+	// webhook deliveries that failed every attempt are appended here
+	DEFAULT_WEBHOOK_DEAD_LETTER_FILE = "webhook_dead_letters.jsonl"
+	// state written by earlier versions in their working directory, imported
+	// into the state store once
+	LEGACY_STATE_FILE = "telegram_state.json"
+	// private boards edited per second across all subscribers
+	BOARD_EDITS_PER_SECOND = 20
+	// assets whose changes over CHANGE_WINDOWS the live message shows
+	DEFAULT_CHANGE_ASSETS = "usd"
+	// history older than this is dropped by the cleanup job
+	HISTORY_RETENTION = 400 * 24 * time.Hour
+
+	// when no asset has changed for this long the provider is considered broken
+	PROVIDER_STALL_AFTER = time.Hour
+
+	// how long message edits pause after Telegram rejects one
+	ERROR_BACKOFF = time.Minute
+
+	// how long shutdown may take to close the live message and stop servers
+	SHUTDOWN_TIMEOUT = 10 * time.Second
 )
 
 func main() {
 	if err := godotenv.Load(".env"); err != nil {
-		fmt.Println("Error loading .env file:", err)
+		slog.Error("error loading .env file", "error", err)
 		return
 	}
 
@@ -27,66 +94,463 @@ func main() {
 	CHAT_ID := os.Getenv("CHAT_ID")
 	CHANEL_NAME := os.Getenv("CHANEL_NAME")
 	PROXY_LINK := os.Getenv("PROXY_LINK")
+	HEALTH_ADDR := os.Getenv("HEALTH_ADDR")
+	MARKET_CALENDAR := os.Getenv("MARKET_CALENDAR")
+	DATA_DIR := os.Getenv("DATA_DIR")
+	if DATA_DIR == "" {
+		DATA_DIR = defaultDataDir()
+	}
+	HISTORY_FILE := os.Getenv("HISTORY_FILE")
+	if HISTORY_FILE == "" {
+		HISTORY_FILE = filepath.Join(DATA_DIR, DEFAULT_HISTORY_FILE)
+	}
+	CHANGE_WINDOWS := os.Getenv("CHANGE_WINDOWS")
+	if CHANGE_WINDOWS == "" {
+		CHANGE_WINDOWS = report.DefaultWindows
+	}
+	STATE_BACKEND := os.Getenv("STATE_BACKEND")
+	STATE_FILE := os.Getenv("STATE_FILE")
+	if STATE_FILE == "" {
+		STATE_FILE = filepath.Join(DATA_DIR, defaultStateFile(STATE_BACKEND))
+	}
+	ANOMALY_RULES := os.Getenv("ANOMALY_RULES")
+	WEBHOOKS_FILE := os.Getenv("WEBHOOKS_FILE")
+	WEBHOOK_DEAD_LETTER_FILE := os.Getenv("WEBHOOK_DEAD_LETTER_FILE")
+	if WEBHOOK_DEAD_LETTER_FILE == "" {
+		WEBHOOK_DEAD_LETTER_FILE = filepath.Join(DATA_DIR, DEFAULT_WEBHOOK_DEAD_LETTER_FILE)
+	}
+	DISCORD_WEBHOOK_URL := os.Getenv("DISCORD_WEBHOOK_URL")
+	SLACK_BOT_TOKEN := os.Getenv("SLACK_BOT_TOKEN")
+	SLACK_CHANNEL := os.Getenv("SLACK_CHANNEL")
+	MATRIX_HOMESERVER := os.Getenv("MATRIX_HOMESERVER")
+	MATRIX_ACCESS_TOKEN := os.Getenv("MATRIX_ACCESS_TOKEN")
+	MATRIX_ROOM_ID := os.Getenv("MATRIX_ROOM_ID")
+	BALE_BOT_TOKEN := os.Getenv("BALE_BOT_TOKEN")
+	EITAA_BOT_TOKEN := os.Getenv("EITAA_BOT_TOKEN")
+	DIGEST_FILE := os.Getenv("DIGEST_FILE")
+	SMTP_PORT := os.Getenv("SMTP_PORT")
+	SMTP_PASSWORD := os.Getenv("SMTP_PASSWORD")
+	CHANGE_ASSETS, hasChangeAssets := os.LookupEnv("CHANGE_ASSETS")
+	if !hasChangeAssets {
+		CHANGE_ASSETS = DEFAULT_CHANGE_ASSETS
+	}
 
-	if BOT_TOKEN == "" || CHAT_ID == "" {
-		fmt.Println("Missing BOT_TOKEN or CHAT_ID in environment variables")
+	logLevel, err := logging.ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		slog.Error("invalid LOG_LEVEL", "error", err)
+		return
+	}
+	logger, err := logging.New(os.Stderr, os.Getenv("LOG_FORMAT"), logLevel,
+		BOT_TOKEN, DISCORD_WEBHOOK_URL, SLACK_BOT_TOKEN, MATRIX_ACCESS_TOKEN, BALE_BOT_TOKEN, EITAA_BOT_TOKEN, SMTP_PASSWORD)
+	if err != nil {
+		slog.Error("invalid LOG_FORMAT", "error", err)
 		return
 	}
+	slog.SetDefault(logger)
 
-	price := price.NewPrice()
-	tel := telegram.NewTelegram(BOT_TOKEN, CHAT_ID)
+	if err := os.MkdirAll(DATA_DIR, 0700); err != nil {
+		logger.Error("error creating data directory", "dir", DATA_DIR, "error", err)
+		return
+	}
 
-	for ; ; time.Sleep(time.Second * UPDATE_MESSAGE_PERIOD) {
-		if (time.Now().Unix() - price.LastRefresh.Unix()) >= UPDATE_PRICE_PERIOD {
-			err := price.Refresh()
-			fmt.Println(price.String())
-			if err != nil {
-				fmt.Println("refresh price error:", err.Error())
-				time.Sleep(time.Minute)
-				continue
-			}
+	calendar := market.Default()
+	if MARKET_CALENDAR != "" {
+		if calendar, err = market.LoadFile(MARKET_CALENDAR); err != nil {
+			logger.Error("error loading market calendar", "file", MARKET_CALENDAR, "error", err)
+			return
 		}
+	}
+	calendar.WarnStale(logger, time.Now())
 
-		nextUpdateSecond := int64(
-			math.Min(
-				float64(UPDATE_PRICE_PERIOD-(time.Now().Unix()-price.LastRefresh.Unix())),
-				UPDATE_PRICE_PERIOD))
+	windows, err := report.ParseWindows(CHANGE_WINDOWS)
+	if err != nil {
+		logger.Error("invalid CHANGE_WINDOWS", "error", err)
+		return
+	}
+	var changeAssets []price.Asset
+	for _, key := range strings.Split(CHANGE_ASSETS, ",") {
+		if strings.TrimSpace(key) == "" {
+			continue
+		}
+		asset, ok := price.FindAsset(key)
+		if !ok {
+			logger.Error("invalid CHANGE_ASSETS", "asset", key)
+			return
+		}
+		changeAssets = append(changeAssets, asset)
+	}
+
+	provider, err := newProvider(os.Getenv, logger)
+	if err != nil {
+		logger.Error("error setting up the price provider", "error", err)
+		return
+	}
 
-		message := createTelegramMessage(price.String(), nextUpdateSecond, CHANEL_NAME, false, PROXY_LINK)
+	command, args := "run", []string(nil)
+	if len(os.Args) > 1 {
+		command, args = os.Args[1], os.Args[2:]
+	}
+	dryRun := command == "dry-run"
+
+	// every command but run and dry-run does one thing and exits
+	if command != "run" && !dryRun {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err = runCommand(ctx, command, args, commandEnv{
+			logger:       logger,
+			provider:     provider,
+			calendar:     calendar,
+			historyFile:  HISTORY_FILE,
+			stateBackend: STATE_BACKEND,
+			stateFile:    STATE_FILE,
+			botToken:     BOT_TOKEN,
+			chatID:       CHAT_ID,
+			chanelName:   CHANEL_NAME,
+			proxyLink:    PROXY_LINK,
+			windows:      windows,
+			changeAssets: changeAssets,
+		})
+		stop()
+		if err != nil {
+			logger.Error(command+" error", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if BOT_TOKEN == "" || CHAT_ID == "" {
+		logger.Error("missing BOT_TOKEN or CHAT_ID in environment variables")
+		return
+	}
+
+	// a dry run keeps state, history, portfolios and subscriptions in memory
+	// and leaves out the outlets it can't fake, so it can run beside the bot
+	if dryRun {
+		STATE_BACKEND, STATE_FILE, HISTORY_FILE = "file", "", ""
+		WEBHOOKS_FILE, DIGEST_FILE = "", ""
+		DISCORD_WEBHOOK_URL, SLACK_BOT_TOKEN, SLACK_CHANNEL, MATRIX_HOMESERVER, MATRIX_ROOM_ID = "", "", "", "", ""
+		logger.Info("dry run: Telegram, Bale and Eitaa requests are logged instead of sent; commands, webhooks, digests, Discord, Slack and Matrix are off")
+	} else if name := provider.Name(); name == "replay" || name == "random" {
+		// made up prices must never reach the channel or the history
+		logger.Error("simulated prices only run with dry-run", "provider", name)
+		return
+	}
+
+	anomalyConfig := anomaly.DefaultConfig()
+	rules, err := anomaly.ParseRules(ANOMALY_RULES, anomalyConfig.Default)
+	if err != nil {
+		logger.Error("invalid ANOMALY_RULES", "error", err)
+		return
+	}
+	for asset, rule := range rules {
+		anomalyConfig.Rules[asset] = rule
+	}
+
+	price := price.NewPriceFrom(provider)
+	price.SetLogger(logger)
+
+	stateStore, err := state.Open(STATE_BACKEND, STATE_FILE)
+	if err != nil {
+		logger.Error("error opening state store", "backend", STATE_BACKEND, "file", STATE_FILE, "error", err)
+		return
+	}
+	defer stateStore.Close()
+	if err := state.Migrate(stateStore, state.Schema(LEGACY_STATE_FILE, CHAT_ID)); err != nil {
+		logger.Error("error migrating state store", "file", STATE_FILE, "error", err)
+		return
+	}
+
+	tel := telegram.NewTelegramWithStore(BOT_TOKEN, CHAT_ID, stateStore)
+	tel.SetLogger(logger)
+	tel.SetDryRun(dryRun)
+
+	// the lock keeps a backfill from rewriting the file under the bot
+	store, err := history.OpenExclusive(HISTORY_FILE)
+	if err != nil {
+		logger.Error("error loading price history", "file", HISTORY_FILE, "error", err)
+		return
+	}
+	defer store.Close()
+
+	portfolios, err := portfolio.Open(stateStore)
+	if err != nil {
+		logger.Error("error loading portfolios", "file", STATE_FILE, "error", err)
+		return
+	}
 
-		if tel.LastMessageTime > 0 && tel.LastMessageId > 0 && (time.Now().Unix()-tel.LastMessageTime) <= NEW_MESSAGE_PERIOD {
-			if err := tel.UpdateMessage(message, tel.LastMessageId); err != nil {
-				fmt.Println("update telegram error:", err.Error())
-				time.Sleep(time.Minute)
-				continue
+	subscriptions, err := subscription.Open(stateStore)
+	if err != nil {
+		logger.Error("error loading subscriptions", "file", STATE_FILE, "error", err)
+		return
+	}
+
+	var endpoints []webhook.Endpoint
+	if WEBHOOKS_FILE != "" {
+		endpoints, err = webhook.LoadEndpoints(WEBHOOKS_FILE)
+		if err != nil {
+			logger.Error("error loading webhooks", "file", WEBHOOKS_FILE, "error", err)
+			return
+		}
+		logger.Info("webhooks loaded", "endpoints", len(endpoints))
+	}
+	webhookConfig := webhook.DefaultConfig()
+	webhookConfig.DeadLetter = WEBHOOK_DEAD_LETTER_FILE
+	webhookConfig.Provider = price.Provider()
+	webhooks := webhook.New(endpoints, webhookConfig, logger)
+
+	var digests []digest.Recipient
+	var mailer *digest.Mailer
+	if DIGEST_FILE != "" {
+		if digests, err = digest.LoadRecipients(DIGEST_FILE); err != nil {
+			logger.Error("error loading digests", "file", DIGEST_FILE, "error", err)
+			return
+		}
+		var port int
+		if SMTP_PORT != "" {
+			if port, err = strconv.Atoi(SMTP_PORT); err != nil {
+				logger.Error("invalid SMTP_PORT", "error", err)
+				return
 			}
-		} else {
-			tel.UpdateMessage(createTelegramMessage(price.String(), nextUpdateSecond, CHANEL_NAME, true, PROXY_LINK), tel.LastMessageId)
+		}
+		mailer, err = digest.NewMailer(digest.SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: SMTP_PASSWORD,
+			From:     os.Getenv("SMTP_FROM"),
+			Security: os.Getenv("SMTP_SECURITY"),
+		})
+		if err != nil {
+			logger.Error("invalid SMTP configuration", "error", err)
+			return
+		}
+		logger.Info("digests loaded", "digests", len(digests))
+	}
 
-			if err := tel.SendMessage(message); err != nil {
-				fmt.Println("send telegram error:", err.Error())
-				time.Sleep(time.Minute)
-				continue
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	monitor := health.NewMonitor(READY_MAX_REFRESH_AGE, READY_MAX_EDIT_FAILURES)
+	var healthServer *http.Server
+	if HEALTH_ADDR != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/", monitor.Handler())
+		mux.Handle("/api/changes", report.ChangesHandler(store, windows))
+		feed.New(store, calendar, feed.Config{
+			Title:   feedTitle(CHANEL_NAME),
+			Days:    FEED_DAYS,
+			DailyAt: DAILY_REPORT_HOUR*time.Hour + DAILY_REPORT_MINUTE*time.Minute,
+			Anomaly: anomalyConfig,
+			TTL:     FEED_TTL,
+			BaseURL: os.Getenv("FEED_BASE_URL"),
+		}).Register(mux)
+		healthServer = &http.Server{Addr: HEALTH_ADDR, Handler: mux}
+		go func() {
+			logger.Info("health server listening", "addr", HEALTH_ADDR)
+			if err := healthServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("health server error", "error", err)
 			}
+		}()
+	}
+
+	b := &bot{
+		logger:     logger,
+		price:      price,
+		tel:        tel,
+		store:      store,
+		calendar:   calendar,
+		monitor:    monitor,
+		detector:   anomaly.New(anomalyConfig),
+		fresh:      freshness.New(calendar),
+		chatID:     CHAT_ID,
+		chanelName: CHANEL_NAME,
+		proxyLink:  PROXY_LINK,
+		state:      stateStore,
+
+		portfolios:    portfolios,
+		subscriptions: subscriptions,
+		pacer:         subscription.NewPacer(BOARD_EDITS_PER_SECOND),
+		boardTexts:    make(map[int64]string),
+		webhooks:      webhooks,
+		digests:       digests,
+		mailer:        mailer,
+
+		windows:      windows,
+		changeAssets: changeAssets,
+	}
+
+	publishers := []publish.Publisher{publish.NewTelegram(tel)}
+	if DISCORD_WEBHOOK_URL != "" {
+		discord, err := publish.NewDiscord(DISCORD_WEBHOOK_URL, stateStore)
+		if err != nil {
+			logger.Error("invalid discord configuration", "error", err)
+			return
+		}
+		publishers = append(publishers, discord)
+	}
+	if SLACK_BOT_TOKEN != "" || SLACK_CHANNEL != "" {
+		slack, err := publish.NewSlack(SLACK_BOT_TOKEN, SLACK_CHANNEL, stateStore)
+		if err != nil {
+			logger.Error("invalid slack configuration", "error", err)
+			return
+		}
+		publishers = append(publishers, slack)
+	}
+	if MATRIX_HOMESERVER != "" || MATRIX_ROOM_ID != "" {
+		matrix, err := publish.NewMatrix(MATRIX_HOMESERVER, MATRIX_ACCESS_TOKEN, MATRIX_ROOM_ID, stateStore)
+		if err != nil {
+			logger.Error("invalid matrix configuration", "error", err)
+			return
+		}
+		publishers = append(publishers, matrix)
+	}
+	for _, mirror := range mirrors() {
+		if mirror.token == "" {
+			continue
+		}
+		client, err := mirrorClient(mirror.platform, mirror.token, stateStore)
+		if err != nil {
+			logger.Error("invalid "+mirror.platform.Name+" configuration", "error", err)
+			return
+		}
+		client.SetLogger(logger)
+		client.SetDryRun(dryRun)
+		publishers = append(publishers, publish.NewTelegram(client))
+	}
+	b.setPublishers(publishers...)
+
+	if err := b.loadAlerts(); err != nil {
+		logger.Warn("could not load alerts", "error", err)
+	}
+
+	jobs := scheduler.New(utils.Tehran, logger)
+	jobs.OnError = b.onJobError
+	for _, job := range b.jobs() {
+		if err := jobs.Add(job); err != nil {
+			logger.Error("invalid job schedule", "error", err)
+			return
 		}
 	}
+
+	webhooks.Start(ctx)
+
+	// a dry run leaves the updates to the running bot
+	var polling sync.WaitGroup
+	if !dryRun {
+		polling.Add(1)
+		go func() {
+			defer polling.Done()
+			b.pollCommands(ctx)
+		}()
+	}
+
+	jobs.Run(ctx)
+	polling.Wait()
+	webhooks.Wait()
+
+	logger.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+
+	b.shutdown(shutdownCtx)
+	if healthServer != nil {
+		if err := healthServer.Shutdown(shutdownCtx); err != nil {
+			logger.Error("health server shutdown error", "error", err)
+		}
+	}
+	logger.Info("stopped")
 }
 
-func createTelegramMessage(priceData string, nextUpdateSecond int64, chanelName string, ending bool, proxyLink string) string {
-	proxy := ""
-	if proxyLink != "" {
-		proxy = fmt.Sprintf(`<a href="%s">ا🗝 پروکسی</a>`, proxyLink)
+// mirror is a platform the live message is mirrored to, with its bot token.
+type mirror struct {
+	platform telegram.Platform
+	token    string
+}
+
+// mirrors returns the mirror platforms with their tokens, empty for those
+// not configured.
+func mirrors() []mirror {
+	return []mirror{
+		{telegram.Bale, os.Getenv("BALE_BOT_TOKEN")},
+		{telegram.Eitaa, os.Getenv("EITAA_BOT_TOKEN")},
+	}
+}
+
+// mirrorClient returns a bot client for the channel on platform named by
+// <PLATFORM>_CHAT_ID. <PLATFORM>_BASE_URL and <PLATFORM>_PARSE_MODE override
+// the platform's defaults.
+func mirrorClient(platform telegram.Platform, token string, store state.Store) (*telegram.Telegram, error) {
+	prefix := strings.ToUpper(platform.Name) + "_"
+	chatID := os.Getenv(prefix + "CHAT_ID")
+	if chatID == "" {
+		return nil, fmt.Errorf("missing %sCHAT_ID", prefix)
+	}
+	if baseURL := os.Getenv(prefix + "BASE_URL"); baseURL != "" {
+		platform.BaseURL = baseURL
+	}
+	if parseMode, ok := os.LookupEnv(prefix + "PARSE_MODE"); ok {
+		platform.ParseMode = parseMode
 	}
+	return telegram.NewTelegramOn(platform, token, chatID, store), nil
+}
+
+// defaultDataDir returns where the history, state and dead letter files are
+// kept unless DATA_DIR is set: the directory of the executable, so they don't
+// move with the working directory, or the working directory under "go run",
+// whose executable is a temporary build.
+func defaultDataDir() string {
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	tmp, _ := filepath.EvalSymlinks(os.TempDir())
+	if err != nil || strings.HasPrefix(exe, tmp+string(filepath.Separator)) {
+		if wd, err := os.Getwd(); err == nil {
+			return wd
+		}
+		return "."
+	}
+	return filepath.Dir(exe)
+}
+
+// defaultStateFile returns where backend keeps the state unless STATE_FILE is set.
+func defaultStateFile(backend string) string {
+	switch backend {
+	case "kv":
+		return "state.kv"
+	case "sqlite":
+		return "state.db"
+	}
+	return "state.json"
+}
+
+// feedTitle names the feeds after the channel they mirror.
+func feedTitle(chanelName string) string {
+	if chanelName == "" {
+		return "قیمت لحظه‌ای ارز و طلا"
+	}
+	return "قیمت لحظه‌ای ارز و طلا | " + chanelName
+}
+
+// marketsClosed reports whether both the currency and gold markets are closed
+// at t, returning the currency market status for the notice.
+func marketsClosed(calendar *market.Calendar, t time.Time) (market.Status, bool) {
+	currency := calendar.Status(market.Currency, t)
+	gold := calendar.Status(market.Gold, t)
+	return currency, !currency.Open && !gold.Open
+}
 
-	if ending {
-		return fmt.Sprintf("<blockquote expandable>%s\n\n%s</blockquote>", priceData, chanelName)
+func marketClosedNotice(status market.Status) string {
+	reason := status.Reason
+	switch reason {
+	case "weekend":
+		reason = "تعطیل آخر هفته"
+	case "after hours":
+		reason = "خارج از ساعت کاری"
 	}
 
-	if nextUpdateSecond >= 7 {
-		return fmt.Sprintf("ا⏰ تا بروزرسانی بعدی قیمت ها: <b>%02d</b> ثانیه\n%s\n\n%s\n%s", nextUpdateSecond, priceData, proxy, chanelName)
-	} else if nextUpdateSecond >= 3 {
-		return fmt.Sprintf("ا🔄 درحال بروزرسانی قیمت ها \n%s\n\n%s\n%s", priceData, proxy, chanelName)
-	} else {
-		return fmt.Sprintf("ا🔄 درحال بروزرسانی قیمت ها\n%s\n\n%s\n%s", priceData, proxy, chanelName)
+	if status.NextOpen.IsZero() {
+		return fmt.Sprintf("🔒 بازار بسته است (%s)", reason)
 	}
+	return fmt.Sprintf("🔒 بازار بسته است (%s)، بازگشایی: %s",
+		reason, utils.NewJTime(status.NextOpen).Format("EEEE HH:mm"))
 }
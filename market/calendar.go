@@ -0,0 +1,333 @@
+package market
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onionj/pricebot/utils"
+)
+
+type Market string
+
+const (
+	Currency Market = "currency" // free currency market
+	Gold     Market = "gold"     // gold and coin bazaar
+	Crypto   Market = "crypto"   // trades around the clock
+)
+
+//go:embed calendar.json
+var defaultCalendar []byte
+
+// how far ahead NextOpen searches before giving up
+const maxLookaheadDays = 60
+
+// Session is a trading window as minutes from midnight, Tehran time.
+type Session struct {
+	Open  int
+	Close int
+}
+
+func (s Session) contains(minute int) bool {
+	return minute >= s.Open && minute < s.Close
+}
+
+type marketSpec struct {
+	name       string
+	alwaysOpen bool
+	sessions   map[time.Weekday][]Session
+}
+
+// Calendar knows weekends, holidays and per-market session hours.
+type Calendar struct {
+	weekend map[time.Weekday]bool
+	fixed   map[[2]int]string // [month, day] recurring every Jalali year
+	lunar   map[utils.JDate]string
+	markets map[Market]marketSpec
+}
+
+// Status describes whether a market is trading at a given time.
+type Status struct {
+	Market   Market
+	Name     string
+	Open     bool
+	Reason   string    // why the market is closed: a holiday name, "weekend" or "after hours"
+	NextOpen time.Time // zero when the market is open or no session was found
+}
+
+type calendarFile struct {
+	Weekend       []string         `json:"weekend"`
+	FixedHolidays []holidayEntry   `json:"fixed_holidays"`
+	LunarHolidays []holidayEntry   `json:"lunar_holidays"`
+	Markets       map[string]entry `json:"markets"`
+}
+
+type holidayEntry struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+type entry struct {
+	Name       string `json:"name"`
+	AlwaysOpen bool   `json:"always_open"`
+	Sessions   []struct {
+		Days  []string `json:"days"`
+		Open  string   `json:"open"`
+		Close string   `json:"close"`
+	} `json:"sessions"`
+}
+
+// Default returns the calendar embedded in the binary.
+func Default() *Calendar {
+	c, err := Parse(bytes.NewReader(defaultCalendar))
+	if err != nil {
+		panic(fmt.Sprintf("market: invalid embedded calendar: %v", err))
+	}
+	return c
+}
+
+// LoadFile reads a calendar data file in the same format as calendar.json.
+func LoadFile(path string) (*Calendar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+func Parse(r io.Reader) (*Calendar, error) {
+	var file calendarFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("error decoding calendar: %w", err)
+	}
+
+	c := &Calendar{
+		weekend: make(map[time.Weekday]bool),
+		fixed:   make(map[[2]int]string),
+		lunar:   make(map[utils.JDate]string),
+		markets: make(map[Market]marketSpec),
+	}
+
+	for _, name := range file.Weekend {
+		day, err := parseWeekday(name)
+		if err != nil {
+			return nil, err
+		}
+		c.weekend[day] = true
+	}
+
+	for _, h := range file.FixedHolidays {
+		var month, day int
+		if _, err := fmt.Sscanf(h.Date, "%d/%d", &month, &day); err != nil || month < 1 || month > 12 || day < 1 || day > 31 {
+			return nil, fmt.Errorf("invalid fixed holiday date %q", h.Date)
+		}
+		c.fixed[[2]int{month, day}] = h.Name
+	}
+
+	for _, h := range file.LunarHolidays {
+		date, err := utils.ParseJDate(h.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lunar holiday: %w", err)
+		}
+		c.lunar[date] = h.Name
+	}
+
+	for key, e := range file.Markets {
+		spec := marketSpec{name: e.Name, alwaysOpen: e.AlwaysOpen, sessions: make(map[time.Weekday][]Session)}
+		for _, s := range e.Sessions {
+			open, err := parseClock(s.Open)
+			if err != nil {
+				return nil, err
+			}
+			closing, err := parseClock(s.Close)
+			if err != nil {
+				return nil, err
+			}
+			if closing <= open {
+				return nil, fmt.Errorf("market %s: session closes at %s before it opens at %s", key, s.Close, s.Open)
+			}
+			for _, name := range s.Days {
+				day, err := parseWeekday(name)
+				if err != nil {
+					return nil, err
+				}
+				spec.sessions[day] = append(spec.sessions[day], Session{Open: open, Close: closing})
+			}
+		}
+		c.markets[Market(key)] = spec
+	}
+
+	return c, nil
+}
+
+// Holiday returns the name of the holiday on d, if any. Weekends are not holidays.
+func (c *Calendar) Holiday(d utils.JDate) (string, bool) {
+	if name, ok := c.lunar[d]; ok {
+		return name, true
+	}
+	name, ok := c.fixed[[2]int{d.Month, d.Day}]
+	return name, ok
+}
+
+// WarnStale logs a warning, and reports true, when the calendar has no lunar
+// holidays in the Jalali year of t. Lunar holidays move every year, so until
+// the year's dates are added its holidays count as trading days.
+func (c *Calendar) WarnStale(logger *slog.Logger, t time.Time) bool {
+	year := utils.ToJalali(t).Year
+	for d := range c.lunar {
+		if d.Year == year {
+			return false
+		}
+	}
+	logger.Warn("market calendar has no lunar holidays this year, so they count as trading days; add them to the calendar file", "year", year)
+	return true
+}
+
+func (c *Calendar) IsWeekend(d utils.JDate) bool {
+	return c.weekend[d.Weekday()]
+}
+
+// IsTradingDay reports whether the bazaar markets trade at all on d.
+func (c *Calendar) IsTradingDay(d utils.JDate) bool {
+	_, holiday := c.Holiday(d)
+	return !holiday && !c.IsWeekend(d)
+}
+
+// IsOpen reports whether m is trading at t.
+func (c *Calendar) IsOpen(m Market, t time.Time) bool {
+	return c.Status(m, t).Open
+}
+
+// Status returns whether m is trading at t and, if not, why and when it reopens.
+func (c *Calendar) Status(m Market, t time.Time) Status {
+	spec, ok := c.markets[m]
+	status := Status{Market: m, Name: spec.name}
+	if !ok {
+		status.Reason = "unknown market"
+		return status
+	}
+
+	t = t.In(utils.Tehran)
+	if spec.alwaysOpen {
+		status.Open = true
+		return status
+	}
+
+	date := utils.ToJalali(t)
+	minute := t.Hour()*60 + t.Minute()
+
+	if name, ok := c.Holiday(date); ok {
+		status.Reason = name
+	} else if c.IsWeekend(date) {
+		status.Reason = "weekend"
+	} else {
+		status.Reason = "after hours"
+		for _, s := range spec.sessions[t.Weekday()] {
+			if s.contains(minute) {
+				return Status{Market: m, Name: spec.name, Open: true}
+			}
+		}
+	}
+
+	status.NextOpen = c.nextOpen(spec, t)
+	return status
+}
+
+// NextOpen returns the next time at or after t when m opens. It returns t
+// itself when m is already open and the zero time when no session is found.
+func (c *Calendar) NextOpen(m Market, t time.Time) time.Time {
+	status := c.Status(m, t)
+	if status.Open {
+		return t
+	}
+	return status.NextOpen
+}
+
+// OpenDuration returns how long m traded between from and to.
+func (c *Calendar) OpenDuration(m Market, from, to time.Time) time.Duration {
+	spec, ok := c.markets[m]
+	if !ok || !to.After(from) {
+		return 0
+	}
+	if spec.alwaysOpen {
+		return to.Sub(from)
+	}
+
+	from, to = from.In(utils.Tehran), to.In(utils.Tehran)
+	var total time.Duration
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, utils.Tehran); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !c.IsTradingDay(utils.ToJalali(day)) {
+			continue
+		}
+		for _, s := range spec.sessions[day.Weekday()] {
+			open := day.Add(time.Duration(s.Open) * time.Minute)
+			closing := day.Add(time.Duration(s.Close) * time.Minute)
+			if open.Before(from) {
+				open = from
+			}
+			if closing.After(to) {
+				closing = to
+			}
+			if closing.After(open) {
+				total += closing.Sub(open)
+			}
+		}
+	}
+	return total
+}
+
+func (c *Calendar) nextOpen(spec marketSpec, t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, utils.Tehran)
+	minute := t.Hour()*60 + t.Minute()
+
+	for i := 0; i <= maxLookaheadDays; i++ {
+		current := day.AddDate(0, 0, i)
+		if !c.IsTradingDay(utils.ToJalali(current)) {
+			continue
+		}
+
+		best := -1
+		for _, s := range spec.sessions[current.Weekday()] {
+			if i == 0 && s.Open <= minute {
+				continue
+			}
+			if best == -1 || s.Open < best {
+				best = s.Open
+			}
+		}
+		if best >= 0 {
+			return current.Add(time.Duration(best) * time.Minute)
+		}
+	}
+	return time.Time{}
+}
+
+func parseClock(s string) (int, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid clock %q", s)
+	}
+	hour, err1 := strconv.Atoi(parts[0])
+	minute, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || hour < 0 || hour > 24 || minute < 0 || minute > 59 || hour*60+minute > 24*60 {
+		return 0, fmt.Errorf("invalid clock %q", s)
+	}
+	return hour*60 + minute, nil
+}
+
+func parseWeekday(name string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", name)
+}
@@ -0,0 +1,130 @@
+package market
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/utils"
+)
+
+func tehran(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, utils.Tehran)
+}
+
+func TestCalendar_Holiday(t *testing.T) {
+	c := Default()
+
+	testCases := []struct {
+		date    utils.JDate
+		holiday bool
+		trading bool
+	}{
+		{utils.JDate{Year: 1404, Month: 1, Day: 1}, true, false},   // Nowruz, fixed
+		{utils.JDate{Year: 1405, Month: 1, Day: 2}, true, false},   // Nowruz recurs every year
+		{utils.JDate{Year: 1404, Month: 4, Day: 15}, true, false},  // Ashura, lunar
+		{utils.JDate{Year: 1405, Month: 4, Day: 5}, true, false},   // Ashura a lunar year later
+		{utils.JDate{Year: 1405, Month: 4, Day: 15}, false, true},  // lunar dates don't recur
+		{utils.JDate{Year: 1404, Month: 2, Day: 16}, false, true},  // ordinary Tuesday
+		{utils.JDate{Year: 1404, Month: 2, Day: 19}, false, false}, // Friday
+	}
+
+	for _, tc := range testCases {
+		_, holiday := c.Holiday(tc.date)
+		if holiday != tc.holiday {
+			t.Errorf("Holiday(%v) = %v; want %v", tc.date, holiday, tc.holiday)
+		}
+		if c.IsTradingDay(tc.date) != tc.trading {
+			t.Errorf("IsTradingDay(%v) = %v; want %v", tc.date, c.IsTradingDay(tc.date), tc.trading)
+		}
+	}
+}
+
+func TestCalendar_WarnStale(t *testing.T) {
+	c := Default()
+	var logs strings.Builder
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	if c.WarnStale(logger, tehran(2026, 6, 1, 12, 0)) || logs.Len() != 0 {
+		t.Errorf("Expected no warning for 1405, got %q", logs.String())
+	}
+	if !c.WarnStale(logger, tehran(2027, 6, 1, 12, 0)) || !strings.Contains(logs.String(), "year=1406") {
+		t.Errorf("Expected a warning for 1406, got %q", logs.String())
+	}
+}
+
+func TestCalendar_Status(t *testing.T) {
+	c := Default()
+
+	testCases := []struct {
+		name         string
+		market       Market
+		at           time.Time
+		open         bool
+		reason       string
+		wantNextOpen time.Time
+	}{
+		{"currency open", Currency, tehran(2025, 5, 6, 10, 0), true, "", time.Time{}},
+		{"currency before open", Currency, tehran(2025, 5, 6, 8, 30), false, "after hours", tehran(2025, 5, 6, 9, 0)},
+		{"thursday afternoon", Currency, tehran(2025, 5, 8, 14, 0), false, "after hours", tehran(2025, 5, 10, 9, 0)},
+		{"friday", Gold, tehran(2025, 5, 9, 12, 0), false, "weekend", tehran(2025, 5, 10, 10, 0)},
+		{"holiday", Gold, tehran(2025, 6, 4, 12, 0), false, "رحلت امام خمینی", tehran(2025, 6, 7, 10, 0)},
+		{"crypto on friday", Crypto, tehran(2025, 5, 9, 3, 0), true, "", time.Time{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status := c.Status(tc.market, tc.at)
+			if status.Open != tc.open {
+				t.Errorf("Open = %v; want %v", status.Open, tc.open)
+			}
+			if status.Reason != tc.reason {
+				t.Errorf("Reason = %q; want %q", status.Reason, tc.reason)
+			}
+			if !status.NextOpen.Equal(tc.wantNextOpen) {
+				t.Errorf("NextOpen = %s; want %s", status.NextOpen, tc.wantNextOpen)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	testCases := []string{
+		`{"weekend": ["someday"]}`,
+		`{"fixed_holidays": [{"date": "13/01"}]}`,
+		`{"lunar_holidays": [{"date": "1404/12/30"}]}`,
+		`{"markets": {"x": {"sessions": [{"days": ["monday"], "open": "18:00", "close": "09:00"}]}}}`,
+		`{"markets": {"x": {"sessions": [{"days": ["monday"], "open": "9", "close": "10:00"}]}}}`,
+	}
+
+	for _, input := range testCases {
+		if _, err := Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("Parse(%s) expected error", input)
+		}
+	}
+}
+
+func TestCalendar_OpenDuration(t *testing.T) {
+	c := Default()
+
+	testCases := []struct {
+		name     string
+		market   Market
+		from, to time.Time
+		expected time.Duration
+	}{
+		{"within a session", Currency, tehran(2025, 5, 6, 10, 0), tehran(2025, 5, 6, 11, 30), 90 * time.Minute},
+		{"across the close", Currency, tehran(2025, 5, 6, 17, 0), tehran(2025, 5, 7, 10, 0), 2 * time.Hour},
+		{"over the weekend", Currency, tehran(2025, 5, 8, 12, 0), tehran(2025, 5, 10, 9, 30), 90 * time.Minute},
+		{"closed all along", Gold, tehran(2025, 5, 9, 8, 0), tehran(2025, 5, 9, 20, 0), 0},
+		{"crypto", Crypto, tehran(2025, 5, 9, 8, 0), tehran(2025, 5, 9, 20, 0), 12 * time.Hour},
+		{"reversed", Currency, tehran(2025, 5, 6, 11, 0), tehran(2025, 5, 6, 10, 0), 0},
+	}
+
+	for _, tc := range testCases {
+		if got := c.OpenDuration(tc.market, tc.from, tc.to); got != tc.expected {
+			t.Errorf("%s: OpenDuration = %s; want %s", tc.name, got, tc.expected)
+		}
+	}
+}
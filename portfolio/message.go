@@ -0,0 +1,43 @@
+package portfolio
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/report"
+)
+
+// Message renders a valuation as a Telegram HTML reply.
+func Message(v Valuation) string {
+	if len(v.Lines) == 0 {
+		return "ا💼 سبد شما خالی است.\nا   برای افزودن دارایی: <code>/hold 2 sekee</code>"
+	}
+
+	var b strings.Builder
+	b.WriteString("ا💼 <b>سبد دارایی شما</b>\n")
+	for _, line := range v.Lines {
+		fmt.Fprintf(&b, "\nا%s %s × %s\n", line.Asset.Emoji, line.Asset.Name, line.Amount.Format(8))
+		if !line.Priced {
+			b.WriteString("ا   قیمت فعلی در دسترس نیست\n")
+			continue
+		}
+		fmt.Fprintf(&b, "ا   ارزش <b>%s</b> تومان | سود/زیان %s تومان %s\n",
+			line.Value.Format(0), signed(line.PnL), report.FormatChange(line.PnLPercent))
+	}
+
+	fmt.Fprintf(&b, "\nا💰 ارزش کل: <b>%s</b> تومان\n", v.Total.Format(0))
+	fmt.Fprintf(&b, "ا📊 سود/زیان کل: %s تومان %s", signed(v.PnL), report.FormatChange(v.PnLPercent))
+	if v.HasChange24h {
+		fmt.Fprintf(&b, "\nا🕐 تغییر 24 ساعت: %s", report.FormatChange(v.Change24h))
+	}
+	return b.String()
+}
+
+// signed formats a toman amount with an explicit plus sign for gains.
+func signed(d price.Decimal) string {
+	if d.Sign() > 0 {
+		return "+" + d.Format(0)
+	}
+	return d.Format(0)
+}
@@ -0,0 +1,190 @@
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/price"
+)
+
+var (
+	ErrNotHeld  = errors.New("asset not held")
+	ErrOversold = errors.New("selling more than held")
+)
+
+// Holding is an amount of one asset and the average toman price it was bought at.
+type Holding struct {
+	Asset      string        `json:"asset"`
+	Amount     price.Decimal `json:"amount"`
+	EntryPrice price.Decimal `json:"entry_price"`
+	Added      time.Time     `json:"added"`
+	Updated    time.Time     `json:"updated"`
+}
+
+// Portfolio is the holdings of one user.
+type Portfolio struct {
+	UserID   int64     `json:"user_id"`
+	Holdings []Holding `json:"holdings"`
+}
+
+// Add records amount more of asset at entryPrice toman per unit, averaging
+// the entry price with what is already held. A negative amount sells from
+// the holding at its entry price and removes it once nothing is left.
+func (p *Portfolio) Add(asset string, amount, entryPrice price.Decimal, at time.Time) error {
+	if amount.IsZero() {
+		return fmt.Errorf("amount must not be zero")
+	}
+
+	for i := range p.Holdings {
+		h := &p.Holdings[i]
+		if h.Asset != asset {
+			continue
+		}
+
+		total := h.Amount.Add(amount)
+		switch total.Sign() {
+		case -1:
+			return fmt.Errorf("%w: only %s %s held", ErrOversold, h.Amount.Normalize(), asset)
+		case 0:
+			p.Holdings = append(p.Holdings[:i], p.Holdings[i+1:]...)
+			return nil
+		}
+		if amount.Sign() > 0 {
+			cost := h.Amount.Mul(h.EntryPrice).Add(amount.Mul(entryPrice))
+			h.EntryPrice, _ = cost.Div(total, 2, price.RoundHalfEven)
+		}
+		h.Amount = total.Normalize()
+		h.Updated = at
+		return nil
+	}
+
+	if amount.Sign() < 0 {
+		return fmt.Errorf("%w: %s", ErrNotHeld, asset)
+	}
+	p.Holdings = append(p.Holdings, Holding{Asset: asset, Amount: amount.Normalize(), EntryPrice: entryPrice, Added: at, Updated: at})
+	return nil
+}
+
+// Pricer prices assets in toman: now from the live quotes, falling back to
+// the latest recorded value, and in the past from history.
+type Pricer struct {
+	Current *price.CurrentData
+	Store   *history.Store
+}
+
+// Now returns the current toman price of one unit of asset.
+func (p Pricer) Now(asset price.Asset) (price.Decimal, bool) {
+	return p.toman(asset, func(a price.Asset) (price.Decimal, bool) {
+		if p.Current != nil {
+			if v, err := a.Value(p.Current); err == nil && v.Sign() > 0 {
+				return v, true
+			}
+		}
+		if p.Store == nil {
+			return price.Decimal{}, false
+		}
+		point, ok := p.Store.At(a.Key, time.Now())
+		return point.Value, ok
+	})
+}
+
+// At returns the toman price of one unit of asset in effect at t.
+func (p Pricer) At(asset price.Asset, t time.Time) (price.Decimal, bool) {
+	if p.Store == nil {
+		return price.Decimal{}, false
+	}
+	return p.toman(asset, func(a price.Asset) (price.Decimal, bool) {
+		point, ok := p.Store.At(a.Key, t)
+		return point.Value, ok
+	})
+}
+
+// toman converts the value of asset returned by lookup to toman, looking the
+// dollar rate up the same way for dollar priced assets.
+func (p Pricer) toman(asset price.Asset, lookup func(price.Asset) (price.Decimal, bool)) (price.Decimal, bool) {
+	value, ok := lookup(asset)
+	if !ok {
+		return price.Decimal{}, false
+	}
+
+	var usdToman price.Decimal
+	if asset.Unit == price.USD {
+		usd, _ := price.FindAsset("usd")
+		if usdToman, ok = lookup(usd); !ok {
+			return price.Decimal{}, false
+		}
+	}
+	money, err := price.Money{Amount: value, Unit: asset.Unit}.In(price.Toman, usdToman)
+	if err != nil {
+		return price.Decimal{}, false
+	}
+	return money.Amount, true
+}
+
+// Line is the valuation of one holding.
+type Line struct {
+	Holding
+	Asset      price.Asset
+	Price      price.Decimal // current toman price of one unit, zero if unknown
+	Value      price.Decimal
+	Cost       price.Decimal
+	PnL        price.Decimal
+	PnLPercent float64
+	Priced     bool
+}
+
+// Valuation is a portfolio valued at current prices.
+type Valuation struct {
+	Lines      []Line
+	Total      price.Decimal
+	Cost       price.Decimal // cost of the priced holdings
+	PnL        price.Decimal
+	PnLPercent float64
+	// Change24h is the percent change of the priced holdings' value over
+	// the last day, known when HasChange24h is set
+	Change24h    float64
+	HasChange24h bool
+}
+
+// Value prices every holding of p at now.
+func Value(p Portfolio, pricer Pricer, now time.Time) Valuation {
+	var v Valuation
+	var dayAgo price.Decimal
+	dayAgoKnown := true
+
+	for _, h := range p.Holdings {
+		asset, ok := price.FindAsset(h.Asset)
+		if !ok {
+			continue
+		}
+		line := Line{Holding: h, Asset: asset, Cost: h.Amount.Mul(h.EntryPrice)}
+
+		if current, ok := pricer.Now(asset); ok {
+			line.Priced = true
+			line.Price = current
+			line.Value = h.Amount.Mul(current)
+			line.PnL = line.Value.Sub(line.Cost)
+			line.PnLPercent = line.Value.PercentChange(line.Cost)
+
+			v.Total = v.Total.Add(line.Value)
+			v.Cost = v.Cost.Add(line.Cost)
+
+			if old, ok := pricer.At(asset, now.Add(-24*time.Hour)); ok {
+				dayAgo = dayAgo.Add(h.Amount.Mul(old))
+			} else {
+				dayAgoKnown = false
+			}
+		}
+		v.Lines = append(v.Lines, line)
+	}
+
+	v.PnL = v.Total.Sub(v.Cost)
+	v.PnLPercent = v.Total.PercentChange(v.Cost)
+	if dayAgoKnown && !dayAgo.IsZero() {
+		v.Change24h = v.Total.PercentChange(dayAgo)
+		v.HasChange24h = true
+	}
+	return v
+}
@@ -0,0 +1,146 @@
+package portfolio
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/state"
+)
+
+func TestPortfolio_Add(t *testing.T) {
+	now := time.Date(2025, 5, 6, 12, 0, 0, 0, time.UTC)
+	var p Portfolio
+
+	steps := []struct {
+		amount, entry string
+		wantErr       error
+	}{
+		{"2", "80000000", nil},
+		{"1", "83000000", nil},
+		{"-1", "90000000", nil},
+		{"-5", "90000000", ErrOversold},
+		{"-2", "90000000", nil},
+		{"-1", "90000000", ErrNotHeld},
+	}
+	expected := []struct {
+		amount, entry string
+	}{
+		{"2", "80000000"},
+		{"3", "81000000.00"},
+		{"2", "81000000.00"},
+		{"2", "81000000.00"},
+		{"", ""},
+		{"", ""},
+	}
+
+	for i, step := range steps {
+		err := p.Add("sekee", price.MustParseDecimal(step.amount), price.MustParseDecimal(step.entry), now)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("Step %d: error = %v; want %v", i, err, step.wantErr)
+		}
+		if expected[i].amount == "" {
+			if len(p.Holdings) != 0 {
+				t.Errorf("Step %d: expected the holding to be removed, got %+v", i, p.Holdings)
+			}
+			continue
+		}
+		h := p.Holdings[0]
+		if h.Amount.String() != expected[i].amount || h.EntryPrice.String() != expected[i].entry {
+			t.Errorf("Step %d: holding = %s @ %s; want %s @ %s", i, h.Amount, h.EntryPrice, expected[i].amount, expected[i].entry)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	now := time.Now()
+	store, _ := history.Open("")
+	store.Record(now.Add(-30*time.Hour), map[string]price.Decimal{
+		"usd":   price.MustParseDecimal("80000"),
+		"sekee": price.MustParseDecimal("70000000"),
+		"btc":   price.MustParseDecimal("60000"),
+	})
+
+	current := &price.CurrentData{
+		Dollar:  price.Detail{Price: "1,000,000"},
+		SekeE:   price.Detail{Price: "770,000,000"},
+		BitCoin: price.Detail{Price: "65,432.10"},
+	}
+	pricer := Pricer{Current: current, Store: store}
+
+	p := Portfolio{Holdings: []Holding{
+		{Asset: "sekee", Amount: price.MustParseDecimal("2"), EntryPrice: price.MustParseDecimal("70000000")},
+		{Asset: "btc", Amount: price.MustParseDecimal("0.05"), EntryPrice: price.MustParseDecimal("4800000000")},
+		{Asset: "usd", Amount: price.MustParseDecimal("1500"), EntryPrice: price.MustParseDecimal("80000")},
+	}}
+
+	v := Value(p, pricer, now)
+	lines := map[string]string{}
+	for _, line := range v.Lines {
+		lines[line.Holding.Asset] = line.Value.Normalize().String()
+	}
+	// btc is priced in dollars: 0.05 * 65432.10 * 100000
+	if lines["sekee"] != "154000000" || lines["btc"] != "327160500" || lines["usd"] != "150000000" {
+		t.Errorf("Unexpected line values %v", lines)
+	}
+	if v.Total.Normalize().String() != "631160500" {
+		t.Errorf("Total = %s; want 631160500", v.Total)
+	}
+	if v.PnL.Normalize().String() != "131160500" {
+		t.Errorf("PnL = %s; want 131160500", v.PnL)
+	}
+
+	// a day ago: 2*70M + 0.05*60000*80000 + 1500*80000 = 500M
+	if !v.HasChange24h || v.Change24h < 26.23 || v.Change24h > 26.24 {
+		t.Errorf("Change24h = %v, %v; want 26.23", v.Change24h, v.HasChange24h)
+	}
+
+	message := Message(v)
+	for _, expected := range []string{"سبد دارایی شما", "سکه امامی × 2", "ارزش کل: <b>631,160,500</b>", "سود/زیان کل: +131,160,500 تومان"} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("Expected message to contain %q, got %s", expected, message)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	st, _ := state.OpenFile(path)
+	s, err := Open(st)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	p := s.Get(42)
+	p.Add("usd", price.MustParseDecimal("1500"), price.MustParseDecimal("80000"), time.Now())
+	if err := s.Put(p); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	other := s.Get(7)
+	other.Add("btc", price.MustParseDecimal("0.05"), price.MustParseDecimal("5000000000"), time.Now())
+	s.Put(other)
+
+	st.Close()
+	st, _ = state.OpenFile(path)
+	reloaded, err := Open(st)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if got := reloaded.Get(42); len(got.Holdings) != 1 || got.Holdings[0].Amount.String() != "1500" {
+		t.Errorf("Unexpected portfolio after reload: %+v", got)
+	}
+
+	if err := reloaded.Delete(42); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	st.Close()
+	st, _ = state.OpenFile(path)
+	reloaded, _ = Open(st)
+	if len(reloaded.Get(42).Holdings) != 0 || len(reloaded.Get(7).Holdings) != 1 {
+		t.Error("Expected only user 42 to be deleted")
+	}
+}
@@ -0,0 +1,77 @@
+package portfolio
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/onionj/pricebot/state"
+)
+
+// Store keeps every user's portfolio in memory, written through to the
+// Portfolios bucket of a state store on each change.
+type Store struct {
+	mu    sync.Mutex
+	state state.Store
+	users map[int64]Portfolio
+}
+
+// Open loads the portfolios kept in st.
+func Open(st state.Store) (*Store, error) {
+	s := &Store{state: st, users: make(map[int64]Portfolio)}
+	keys, err := st.Keys(state.Portfolios)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		var p Portfolio
+		if _, err := st.Get(state.Portfolios, key, &p); err != nil {
+			return nil, err
+		}
+		s.users[p.UserID] = p
+	}
+	return s, nil
+}
+
+// Get returns the portfolio of userID, empty if the user holds nothing.
+func (s *Store) Get(userID int64) Portfolio {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.users[userID]
+	if !ok {
+		return Portfolio{UserID: userID}
+	}
+	p.Holdings = append([]Holding(nil), p.Holdings...)
+	return p
+}
+
+// Put saves p, dropping it entirely once it holds nothing.
+func (s *Store) Put(p Portfolio) error {
+	if len(p.Holdings) == 0 {
+		return s.Delete(p.UserID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.state.Put(state.Portfolios, key(p.UserID), p); err != nil {
+		return err
+	}
+	s.users[p.UserID] = p
+	return nil
+}
+
+// Delete forgets the portfolio of userID.
+func (s *Store) Delete(userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.state.Delete(state.Portfolios, key(userID)); err != nil {
+		return err
+	}
+	delete(s.users, userID)
+	return nil
+}
+
+func key(userID int64) string {
+	return strconv.FormatInt(userID, 10)
+}
@@ -0,0 +1,127 @@
+package price
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Group string
+
+const (
+	GroupCurrency Group = "currency"
+	GroupCrypto   Group = "crypto"
+	GroupCoin     Group = "coin"
+	GroupGold     Group = "gold"
+)
+
+type Unit string
+
+const (
+	Rial  Unit = "rial"
+	Toman Unit = "toman"
+	USD   Unit = "usd"
+)
+
+// Label is the Persian unit name used in messages.
+func (u Unit) Label() string {
+	switch u {
+	case Rial:
+		return "ریال"
+	case Toman:
+		return "تومان"
+	case USD:
+		return "دلار"
+	}
+	return string(u)
+}
+
+// Asset describes one instrument of CurrentData.
+type Asset struct {
+	Key   string // short identifier used in history, commands and APIs
+	Name  string // Persian display name
+	Emoji string
+	Group Group
+	Unit  Unit // unit prices are shown in; tgju reports toman assets in rial
+	field func(*CurrentData) *Detail
+}
+
+// Assets lists every instrument in the order the live message shows them.
+var Assets = []Asset{
+	{"usd", "دلار امریکا", "🇺🇸", GroupCurrency, Toman, func(c *CurrentData) *Detail { return &c.Dollar }},
+	{"eur", "یورو اروپا", "🇪🇺", GroupCurrency, Toman, func(c *CurrentData) *Detail { return &c.Eur }},
+	{"gbp", "پوند انگلیس", "🇬🇧", GroupCurrency, Toman, func(c *CurrentData) *Detail { return &c.GBP }},
+	{"cad", "دلار کانادا", "🇨🇦", GroupCurrency, Toman, func(c *CurrentData) *Detail { return &c.CAD }},
+	{"aud", "دلار استرالیا", "🇦🇺", GroupCurrency, Toman, func(c *CurrentData) *Detail { return &c.AUD }},
+	{"aed", "درهم امارات", "🇦🇪", GroupCurrency, Toman, func(c *CurrentData) *Detail { return &c.AED }},
+	{"try", "لیر ترکیه", "🇹🇷", GroupCurrency, Toman, func(c *CurrentData) *Detail { return &c.TRY }},
+	{"sek", "کرون سوئد", "🇸🇪", GroupCurrency, Toman, func(c *CurrentData) *Detail { return &c.SEK }},
+	{"cny", "یوان چین", "🇨🇳", GroupCurrency, Toman, func(c *CurrentData) *Detail { return &c.CNY }},
+	{"sar", "ریال عربستان", "🇸🇦", GroupCurrency, Toman, func(c *CurrentData) *Detail { return &c.SAR }},
+	{"iqd", "دینار عراق", "🇮🇶", GroupCurrency, Rial, func(c *CurrentData) *Detail { return &c.IQD }},
+
+	{"btc", "بیتکوین", "👑", GroupCrypto, USD, func(c *CurrentData) *Detail { return &c.BitCoin }},
+	{"usdt", "تتر", "🇺🇸", GroupCrypto, Toman, func(c *CurrentData) *Detail { return &c.Tether }},
+	{"eth", "اتریوم", "💠", GroupCrypto, USD, func(c *CurrentData) *Detail { return &c.Ethereum }},
+
+	{"sekeb", "سکه بهار آزادی", "🪙", GroupCoin, Toman, func(c *CurrentData) *Detail { return &c.SekeB }},
+	{"sekee", "سکه امامی", "🪙", GroupCoin, Toman, func(c *CurrentData) *Detail { return &c.SekeE }},
+	{"nim", "نیم سکه", "🪙", GroupCoin, Toman, func(c *CurrentData) *Detail { return &c.Nim }},
+	{"rob", "ربع سکه", "🪙", GroupCoin, Toman, func(c *CurrentData) *Detail { return &c.Rob }},
+	{"rob_down", "ربع سکه قبل ۸۶", "🪙", GroupCoin, Toman, func(c *CurrentData) *Detail { return &c.RobDown }},
+
+	{"geram18", "طلا گرمی", "💰", GroupGold, Toman, func(c *CurrentData) *Detail { return &c.Geram18 }},
+	{"mesghal", "مثقال طلا", "💰", GroupGold, Toman, func(c *CurrentData) *Detail { return &c.Mesghal }},
+	{"ons", "انس طلا", "💰", GroupGold, USD, func(c *CurrentData) *Detail { return &c.Ons }},
+}
+
+// FindAsset looks an asset up by its key, case-insensitively.
+func FindAsset(key string) (Asset, bool) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	for _, a := range Assets {
+		if a.Key == key {
+			return a, true
+		}
+	}
+	return Asset{}, false
+}
+
+// Detail returns the asset's entry in c.
+func (a Asset) Detail(c *CurrentData) Detail {
+	return *a.field(c)
+}
+
+// SetDetail replaces the asset's entry in c, for example to put back the last
+// good value over a quarantined one.
+func (a Asset) SetDetail(c *CurrentData, d Detail) {
+	*a.field(c) = d
+}
+
+// Value parses the asset's price in c and converts it to the asset's unit.
+func (a Asset) Value(c *CurrentData) (Decimal, error) {
+	return a.Parse(a.field(c).Price)
+}
+
+// Parse parses a price of the asset as tgju reports it, in rial for toman
+// assets, and converts it to the asset's unit.
+func (a Asset) Parse(raw string) (Decimal, error) {
+	value, err := ParseDecimal(raw)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("invalid %s price %q: %w", a.Key, raw, err)
+	}
+	if a.Unit == Toman {
+		toman, _ := Money{Amount: value, Unit: Rial}.In(Toman, Decimal{})
+		return toman.Amount.Normalize(), nil
+	}
+	return value, nil
+}
+
+// Values returns the parsed value of every asset with a valid price.
+func (c *CurrentData) Values() map[string]Decimal {
+	values := make(map[string]Decimal, len(Assets))
+	for _, a := range Assets {
+		if v, err := a.Value(c); err == nil {
+			values[a.Key] = v
+		}
+	}
+	return values
+}
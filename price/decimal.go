@@ -0,0 +1,374 @@
+package price
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// RoundingMode selects how Decimal drops digits.
+type RoundingMode int
+
+const (
+	RoundHalfUp   RoundingMode = iota // ties away from zero
+	RoundHalfEven                     // ties to the even neighbour, banker's rounding
+	RoundDown                         // toward zero, truncation
+	RoundUp                           // away from zero
+	RoundFloor                        // toward negative infinity
+	RoundCeiling                      // toward positive infinity
+)
+
+// Decimal is an arbitrary-precision decimal number, coef × 10^-scale. The
+// zero value is 0. Decimals are immutable; every operation returns a new value.
+type Decimal struct {
+	coef  *big.Int
+	scale int32
+}
+
+var bigTen = big.NewInt(10)
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// NewDecimal returns value × 10^-scale.
+func NewDecimal(value int64, scale int32) Decimal {
+	return Decimal{coef: big.NewInt(value), scale: scale}
+}
+
+func (d Decimal) int() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// ParseDecimal parses the number formats tgju uses: "500000", "1,000,000",
+// "65,432.10" and "2,650.5", with ASCII, Persian or Arabic-Indic digits and
+// separators.
+func ParseDecimal(s string) (Decimal, error) {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case r == ',' || r == '٬' || r == '_' || r == ' ':
+			// thousands separators
+		case r == '٫':
+			b.WriteByte('.')
+		case r >= '۰' && r <= '۹':
+			b.WriteRune('0' + (r - '۰'))
+		case r >= '٠' && r <= '٩':
+			b.WriteRune('0' + (r - '٠'))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	clean := b.String()
+
+	digits := strings.TrimLeft(clean, "+-")
+	if len(clean)-len(digits) > 1 {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" && fraction == "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	for _, part := range []string{whole, fraction} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+			}
+		}
+	}
+
+	coef, ok := new(big.Int).SetString(whole+fraction, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if strings.HasPrefix(clean, "-") {
+		coef.Neg(coef)
+	}
+	return Decimal{coef: coef, scale: int32(len(fraction))}, nil
+}
+
+// MustParseDecimal is ParseDecimal for constants; it panics on invalid input.
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// DecimalFromFloat converts f keeping up to 8 fractional digits.
+func DecimalFromFloat(f float64) Decimal {
+	d, err := ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+	if err != nil {
+		return Decimal{}
+	}
+	return d.Round(8, RoundHalfEven).Normalize()
+}
+
+// rescale returns the coefficients of a and b at a common scale.
+func rescale(a, b Decimal) (*big.Int, *big.Int, int32) {
+	switch {
+	case a.scale == b.scale:
+		return a.int(), b.int(), a.scale
+	case a.scale > b.scale:
+		return a.int(), new(big.Int).Mul(b.int(), pow10(a.scale-b.scale)), a.scale
+	default:
+		return new(big.Int).Mul(a.int(), pow10(b.scale-a.scale)), b.int(), b.scale
+	}
+}
+
+func (d Decimal) Add(other Decimal) Decimal {
+	a, b, scale := rescale(d, other)
+	return Decimal{coef: new(big.Int).Add(a, b), scale: scale}
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	a, b, scale := rescale(d, other)
+	return Decimal{coef: new(big.Int).Sub(a, b), scale: scale}
+}
+
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{coef: new(big.Int).Mul(d.int(), other.int()), scale: d.scale + other.scale}
+}
+
+// Div returns d / other rounded to scale fractional digits.
+func (d Decimal) Div(other Decimal, scale int32, mode RoundingMode) (Decimal, error) {
+	if other.IsZero() {
+		return Decimal{}, fmt.Errorf("division by zero")
+	}
+
+	num := d.int()
+	den := other.int()
+	if shift := scale + other.scale - d.scale; shift >= 0 {
+		num = new(big.Int).Mul(num, pow10(shift))
+	} else {
+		den = new(big.Int).Mul(den, pow10(-shift))
+	}
+	return Decimal{coef: roundQuotient(num, den, mode), scale: scale}, nil
+}
+
+// Round returns d with at most scale fractional digits.
+func (d Decimal) Round(scale int32, mode RoundingMode) Decimal {
+	if d.scale <= scale {
+		return d
+	}
+	return Decimal{coef: roundQuotient(d.int(), pow10(d.scale-scale), mode), scale: scale}
+}
+
+// roundQuotient returns num / den rounded to an integer with mode.
+func roundQuotient(num, den *big.Int, mode RoundingMode) *big.Int {
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() == 0 {
+		return quo
+	}
+
+	negative := num.Sign()*den.Sign() < 0
+	// compare 2×|rem| with |den| to find which side of the half we are on
+	twice := new(big.Int).Abs(rem)
+	twice.Lsh(twice, 1)
+	cmp := twice.Cmp(new(big.Int).Abs(den))
+
+	away := false
+	switch mode {
+	case RoundHalfUp:
+		away = cmp >= 0
+	case RoundHalfEven:
+		away = cmp > 0 || (cmp == 0 && quo.Bit(0) == 1)
+	case RoundDown:
+		away = false
+	case RoundUp:
+		away = true
+	case RoundFloor:
+		away = negative
+	case RoundCeiling:
+		away = !negative
+	}
+
+	if away {
+		if negative {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	return quo
+}
+
+// Normalize drops trailing fractional zeros.
+func (d Decimal) Normalize() Decimal {
+	coef := new(big.Int).Set(d.int())
+	scale := d.scale
+	rem := new(big.Int)
+	for scale > 0 {
+		quo, r := new(big.Int).QuoRem(coef, bigTen, rem)
+		if r.Sign() != 0 {
+			break
+		}
+		coef = quo
+		scale--
+	}
+	return Decimal{coef: coef, scale: scale}
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: new(big.Int).Neg(d.int()), scale: d.scale}
+}
+
+func (d Decimal) Abs() Decimal {
+	return Decimal{coef: new(big.Int).Abs(d.int()), scale: d.scale}
+}
+
+// Cmp returns -1, 0 or +1 depending on whether d is less than, equal to or greater than other.
+func (d Decimal) Cmp(other Decimal) int {
+	a, b, _ := rescale(d, other)
+	return a.Cmp(b)
+}
+
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Scale returns the number of fractional digits.
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Float64 returns the nearest float64, for statistics and charts.
+func (d Decimal) Float64() float64 {
+	f, _ := new(big.Rat).SetFrac(d.int(), pow10(d.scale)).Float64()
+	return f
+}
+
+// PercentChange returns (d - base) / base × 100, or 0 when base is zero.
+func (d Decimal) PercentChange(base Decimal) float64 {
+	if base.IsZero() {
+		return 0
+	}
+	change, _ := d.Sub(base).Mul(NewDecimal(100, 0)).Div(base, 8, RoundHalfEven)
+	return change.Float64()
+}
+
+func (d Decimal) String() string {
+	s := new(big.Int).Abs(d.int()).String()
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+	}
+	if d.scale <= 0 {
+		return sign + s + strings.Repeat("0", int(-d.scale))
+	}
+	if len(s) <= int(d.scale) {
+		s = strings.Repeat("0", int(d.scale)-len(s)+1) + s
+	}
+	point := len(s) - int(d.scale)
+	return sign + s[:point] + "." + s[point:]
+}
+
+// Format rounds d half up to places fractional digits, drops trailing zeros
+// and groups the integer part with commas, so 65432.104 becomes "65,432.1".
+func (d Decimal) Format(places int32) string {
+	s := d.Round(places, RoundHalfUp).Normalize().String()
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, fraction, hasFraction := strings.Cut(s, ".")
+
+	var b strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	if hasFraction {
+		b.WriteString("." + fraction)
+	}
+	if b.String() == "0" {
+		return "0"
+	}
+	return sign + b.String()
+}
+
+// MarshalJSON writes d as a JSON number.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a string in any ParseDecimal format.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	var s string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	} else {
+		s = string(data)
+	}
+	parsed, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Money is an amount in a unit.
+type Money struct {
+	Amount Decimal
+	Unit   Unit
+}
+
+var rialsPerToman = NewDecimal(10, 0)
+
+// In converts m to unit. Converting between USD and the rial units needs
+// usdToman, the price of one dollar in toman.
+func (m Money) In(unit Unit, usdToman Decimal) (Money, error) {
+	if m.Unit == unit {
+		return m, nil
+	}
+
+	toman := m.Amount
+	switch m.Unit {
+	case Rial:
+		// toman amounts keep one more fractional digit than rials
+		toman, _ = m.Amount.Div(rialsPerToman, m.Amount.Scale()+1, RoundHalfEven)
+	case USD:
+		if usdToman.IsZero() {
+			return Money{}, fmt.Errorf("no dollar rate to convert from usd")
+		}
+		toman = m.Amount.Mul(usdToman)
+	}
+
+	switch unit {
+	case Toman:
+		return Money{Amount: toman, Unit: Toman}, nil
+	case Rial:
+		return Money{Amount: toman.Mul(rialsPerToman), Unit: Rial}, nil
+	case USD:
+		if usdToman.IsZero() {
+			return Money{}, fmt.Errorf("no dollar rate to convert to usd")
+		}
+		amount, err := toman.Div(usdToman, 8, RoundHalfEven)
+		return Money{Amount: amount.Normalize(), Unit: USD}, err
+	}
+	return Money{}, fmt.Errorf("unknown unit %q", unit)
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%s %s", m.Amount.Format(2), m.Unit.Label())
+}
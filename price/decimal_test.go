@@ -0,0 +1,205 @@
+package price
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"500000", "500000", false},
+		{"1,000,000", "1000000", false},
+		{"65,432.10", "65432.10", false},
+		{"2,650.5", "2650.5", false},
+		{"-0.75", "-0.75", false},
+		{".5", "0.5", false},
+		{"۱۲٬۳۴۵٫۶", "12345.6", false},
+		{"", "", true},
+		{"-", "", true},
+		{"1.2.3", "", true},
+		{"--1", "", true},
+		{"abc", "", true},
+		{"1e5", "", true},
+	}
+
+	for _, tc := range testCases {
+		got, err := ParseDecimal(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseDecimal(%q) error = %v; wantErr %v", tc.input, err, tc.wantErr)
+			continue
+		}
+		if err == nil && got.String() != tc.expected {
+			t.Errorf("ParseDecimal(%q) = %s; want %s", tc.input, got, tc.expected)
+		}
+	}
+}
+
+func TestDecimal_Arithmetic(t *testing.T) {
+	a := MustParseDecimal("65,432.10")
+	b := MustParseDecimal("0.05")
+
+	testCases := []struct {
+		name     string
+		got      Decimal
+		expected string
+	}{
+		{"Add", a.Add(b), "65432.15"},
+		{"Sub", b.Sub(a), "-65432.05"},
+		{"Mul", a.Mul(b), "3271.6050"},
+		{"Neg", a.Neg(), "-65432.10"},
+		{"Normalize", a.Normalize(), "65432.1"},
+		{"Float exact", MustParseDecimal("0.1").Add(MustParseDecimal("0.2")), "0.3"},
+	}
+
+	for _, tc := range testCases {
+		if tc.got.String() != tc.expected {
+			t.Errorf("%s = %s; want %s", tc.name, tc.got, tc.expected)
+		}
+	}
+
+	if a.Cmp(b) != 1 || b.Cmp(a) != -1 || !MustParseDecimal("1.50").Equal(MustParseDecimal("1.5")) {
+		t.Error("Unexpected comparison result")
+	}
+	if (Decimal{}).String() != "0" || !(Decimal{}).IsZero() {
+		t.Error("Expected the zero value to be 0")
+	}
+}
+
+func TestDecimal_Div(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		scale    int32
+		mode     RoundingMode
+		expected string
+	}{
+		{"10", "3", 4, RoundHalfUp, "3.3333"},
+		{"20", "3", 2, RoundHalfUp, "6.67"},
+		{"20", "3", 2, RoundDown, "6.66"},
+		{"-20", "3", 2, RoundHalfUp, "-6.67"},
+		{"-20", "3", 2, RoundFloor, "-6.67"},
+		{"-20", "3", 2, RoundCeiling, "-6.66"},
+		{"1", "8", 2, RoundHalfEven, "0.12"},
+		{"3", "8", 2, RoundHalfEven, "0.38"},
+		{"1", "8", 2, RoundHalfUp, "0.13"},
+		{"500000", "10", 0, RoundHalfUp, "50000"},
+		{"1.5", "0.5", 0, RoundHalfUp, "3"},
+	}
+
+	for _, tc := range testCases {
+		got, err := MustParseDecimal(tc.a).Div(MustParseDecimal(tc.b), tc.scale, tc.mode)
+		if err != nil {
+			t.Errorf("%s / %s failed: %v", tc.a, tc.b, err)
+			continue
+		}
+		if got.String() != tc.expected {
+			t.Errorf("%s / %s (scale %d, mode %d) = %s; want %s", tc.a, tc.b, tc.scale, tc.mode, got, tc.expected)
+		}
+	}
+
+	if _, err := MustParseDecimal("1").Div(Decimal{}, 2, RoundHalfUp); err == nil {
+		t.Error("Expected division by zero error")
+	}
+}
+
+func TestDecimal_Round(t *testing.T) {
+	testCases := []struct {
+		input    string
+		mode     RoundingMode
+		expected string
+	}{
+		{"2.5", RoundHalfUp, "3"},
+		{"2.5", RoundHalfEven, "2"},
+		{"3.5", RoundHalfEven, "4"},
+		{"-2.5", RoundHalfUp, "-3"},
+		{"-2.5", RoundHalfEven, "-2"},
+		{"2.1", RoundUp, "3"},
+		{"2.9", RoundDown, "2"},
+		{"-2.1", RoundFloor, "-3"},
+		{"-2.9", RoundCeiling, "-2"},
+		{"2", RoundUp, "2"},
+	}
+
+	for _, tc := range testCases {
+		if got := MustParseDecimal(tc.input).Round(0, tc.mode); got.String() != tc.expected {
+			t.Errorf("Round(%s, mode %d) = %s; want %s", tc.input, tc.mode, got, tc.expected)
+		}
+	}
+}
+
+func TestDecimal_Format(t *testing.T) {
+	testCases := []struct {
+		input    string
+		places   int32
+		expected string
+	}{
+		{"1234567", 0, "1,234,567"},
+		{"65432.104", 2, "65,432.1"},
+		{"2650.555", 2, "2,650.56"},
+		{"-1500", 2, "-1,500"},
+		{"-0.001", 2, "0"},
+		{"999.5", 0, "1,000"},
+	}
+
+	for _, tc := range testCases {
+		if got := MustParseDecimal(tc.input).Format(tc.places); got != tc.expected {
+			t.Errorf("Format(%s, %d) = %s; want %s", tc.input, tc.places, got, tc.expected)
+		}
+	}
+}
+
+func TestDecimal_JSON(t *testing.T) {
+	var values struct {
+		A Decimal `json:"a"`
+		B Decimal `json:"b"`
+	}
+	if err := json.Unmarshal([]byte(`{"a": 65432.10, "b": "1,000"}`), &values); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if values.A.String() != "65432.10" || values.B.String() != "1000" {
+		t.Errorf("Unexpected values %s %s", values.A, values.B)
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `{"a":65432.10,"b":1000}` {
+		t.Errorf("Unexpected JSON %s", data)
+	}
+}
+
+func TestMoney_In(t *testing.T) {
+	usdToman := MustParseDecimal("80000")
+
+	testCases := []struct {
+		name     string
+		money    Money
+		unit     Unit
+		expected string
+	}{
+		{"rial to toman", Money{MustParseDecimal("500005"), Rial}, Toman, "50000.5"},
+		{"toman to rial", Money{MustParseDecimal("50000.5"), Toman}, Rial, "500005.0"},
+		{"usd to toman", Money{MustParseDecimal("0.05"), USD}, Toman, "4000.00"},
+		{"toman to usd", Money{MustParseDecimal("100000"), Toman}, USD, "1.25"},
+		{"rial to usd", Money{MustParseDecimal("8000000"), Rial}, USD, "10"},
+	}
+
+	for _, tc := range testCases {
+		got, err := tc.money.In(tc.unit, usdToman)
+		if err != nil {
+			t.Errorf("%s failed: %v", tc.name, err)
+			continue
+		}
+		if got.Unit != tc.unit || got.Amount.String() != tc.expected {
+			t.Errorf("%s = %s %s; want %s %s", tc.name, got.Amount, got.Unit, tc.expected, tc.unit)
+		}
+	}
+
+	if _, err := (Money{MustParseDecimal("1"), USD}).In(Toman, Decimal{}); err == nil {
+		t.Error("Expected error converting usd without a rate")
+	}
+}
@@ -0,0 +1,98 @@
+package price
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Frame is one provider response captured at Time, a line of a fixture file.
+type Frame struct {
+	Time    time.Time   `json:"time"`
+	Current CurrentData `json:"current"`
+}
+
+// LoadFixture reads the frames of a fixture file, JSON Lines as written by
+// Recorder, in time order.
+func LoadFixture(path string) ([]Frame, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var frames []Frame
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var frame Frame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		if n := len(frames); n > 0 && frame.Time.Before(frames[n-1].Time) {
+			return nil, fmt.Errorf("%s line %d: frame at %s is before the one above it", path, line, frame.Time)
+		}
+		frames = append(frames, frame)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("%s: no frames", path)
+	}
+	return frames, nil
+}
+
+// Recorder is a Provider that appends every response of another to a
+// fixture file, for replaying later.
+type Recorder struct {
+	Provider
+	path string
+	now  func() time.Time
+}
+
+// NewRecorder records the responses of provider to the fixture file at path.
+func NewRecorder(provider Provider, path string) *Recorder {
+	return &Recorder{Provider: provider, path: path, now: time.Now}
+}
+
+// SetLogger passes the logger on to the recorded provider.
+func (r *Recorder) SetLogger(logger *slog.Logger) {
+	if l, ok := r.Provider.(interface{ SetLogger(*slog.Logger) }); ok {
+		l.SetLogger(logger)
+	}
+}
+
+// Latest returns the recorded provider's prices after appending them to
+// the fixture. A failed write fails the call, so a recording has no
+// silent holes.
+func (r *Recorder) Latest(ctx context.Context) (CurrentData, error) {
+	current, err := r.Provider.Latest(ctx)
+	if err != nil {
+		return current, err
+	}
+
+	line, err := json.Marshal(Frame{Time: r.now(), Current: current})
+	if err != nil {
+		return current, err
+	}
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return current, fmt.Errorf("record fixture: %w", err)
+	}
+	_, err = f.Write(append(line, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return current, fmt.Errorf("record fixture: %w", err)
+	}
+	return current, nil
+}
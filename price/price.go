@@ -1,11 +1,11 @@
 package price
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
-	"strconv"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,8 +14,9 @@ import (
 
 // Make these package variables so they can be modified in tests
 var (
-	httpClient = &http.Client{}
-	baseURL    = "https://call3.tgju.org/ajax.json"
+	httpClient     = &http.Client{}
+	baseURL        = "https://call3.tgju.org/ajax.json"
+	requestTimeout = 15 * time.Second
 )
 
 type Detail struct {
@@ -26,28 +27,38 @@ type Detail struct {
 	ChangeDirection  string  `json:"dt"` // low, high
 }
 
-func (d Detail) FormatChange() string {
+// Amount parses the raw price as reported by tgju.
+func (d Detail) Amount() (Decimal, error) {
+	return ParseDecimal(d.Price)
+}
 
-	lockEmoji := ""
+// Updated parses the time tgju last changed the price, in Tehran time.
+func (d Detail) Updated() (time.Time, error) {
+	return time.ParseInLocation("2006-01-02 15:04:05", d.DateTime, utils.Tehran)
+}
 
-	loc, _ := time.LoadLocation("Asia/Tehran")
-	dt, err := time.ParseInLocation("2006-01-02 15:04:05", d.DateTime, loc)
-	if err == nil {
-		if time.Since(dt) > time.Hour {
-			lockEmoji = "🔒"
-		}
+// FormatChange renders tgju's change figure, with 🔒 when the price has not
+// changed for over an hour.
+func (d Detail) FormatChange() string {
+	mark := ""
+	if updated, err := d.Updated(); err == nil && time.Since(updated) > time.Hour {
+		mark = "🔒"
 	}
+	return d.formatChange(mark)
+}
 
+// formatChange renders the change figure with a staleness mark in front.
+func (d Detail) formatChange(mark string) string {
 	switch d.ChangeDirection {
 	case "high":
-		return fmt.Sprintf("(%s%s%s)", lockEmoji, fmt.Sprintf("%.2f%%", d.ChangePercentage), "🟢")
+		return fmt.Sprintf("(%s%.2f%%🟢)", mark, d.ChangePercentage)
 	case "low":
-		return fmt.Sprintf("(%s%s%s)", lockEmoji, fmt.Sprintf("%.2f%%", d.ChangePercentage), "🔴")
+		return fmt.Sprintf("(%s%.2f%%🔴)", mark, d.ChangePercentage)
 	default:
-		if lockEmoji == "" {
+		if mark == "" {
 			return "⬅️"
 		}
-		return lockEmoji
+		return mark
 	}
 }
 
@@ -83,136 +94,130 @@ type Price struct {
 	Current      CurrentData `json:"current"`
 	LastRefresh  time.Time
 	JLastRefresh utils.JDate
+	// Marks, when set, holds a staleness mark per asset key that replaces the
+	// hourly 🔒 of FormatChange in String
+	Marks    map[string]string `json:"-"`
+	provider Provider
+	logger   *slog.Logger
 }
 
+// NewPrice returns prices refreshed from tgju.
 func NewPrice() *Price {
-	return &Price{}
+	return NewPriceFrom(&TGJU{})
 }
 
-func (p *Price) Refresh() error {
-	loc, _ := time.LoadLocation("Asia/Tehran")
-	ltime := time.Now().In(loc)
-
-	// ‍‍`what` just for deactivate cache!
-	url := fmt.Sprintf("%s?what=%d", baseURL, ltime.Unix())
+// NewPriceFrom returns prices refreshed from provider.
+func NewPriceFrom(provider Provider) *Price {
+	p := &Price{provider: provider}
+	p.SetLogger(slog.Default())
+	return p
+}
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+// SetLogger replaces the logger used for refresh diagnostics.
+func (p *Price) SetLogger(logger *slog.Logger) {
+	p.logger = logger.With("provider", p.source().Name())
+	if l, ok := p.provider.(interface{ SetLogger(*slog.Logger) }); ok {
+		l.SetLogger(p.logger)
 	}
+}
 
-	req.Header.Set("Accept-Language", "fa-IR")
+// Provider returns the name of the provider prices come from.
+func (p Price) Provider() string {
+	return p.source().Name()
+}
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("error making request: %w", err)
+func (p Price) source() Provider {
+	if p.provider == nil {
+		return &TGJU{}
 	}
-	defer resp.Body.Close()
+	return p.provider
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
+// Refresh fetches the latest prices from the provider. The request is
+// bounded by ctx and by requestTimeout.
+func (p *Price) Refresh(ctx context.Context) error {
+	ltime := time.Now().In(utils.Tehran)
 
-	body, err := io.ReadAll(resp.Body)
+	current, err := p.source().Latest(ctx)
 	if err != nil {
-		return fmt.Errorf("error reading response: %w", err)
-	}
-
-	if err := json.Unmarshal(body, p); err != nil {
-		return fmt.Errorf("error unmarshaling response: %w", err)
+		return err
 	}
 
+	p.Current = current
 	p.LastRefresh = ltime
 	p.JLastRefresh = utils.GregorianToJalali(p.LastRefresh.Year(), int(p.LastRefresh.Month()), p.LastRefresh.Day())
 	return nil
 }
 
-func (p Price) prettyNumber(i int) string {
-	s := strconv.Itoa(i)
-	r1 := ""
-	idx := 0
-
-	// Reverse and interleave the separator.
-	for i = len(s) - 1; i >= 0; i-- {
-		idx++
-		if idx == 4 {
-			idx = 1
-			r1 = r1 + ","
-		}
-		r1 = r1 + string(s[i])
+func (p Price) toToman(rilaString string) string {
+	rial, err := ParseDecimal(rilaString)
+	if err != nil {
+		p.log().Warn("invalid rial price", "price", rilaString, "error", err)
+		return "0"
 	}
+	toman, _ := Money{Amount: rial, Unit: Rial}.In(Toman, Decimal{})
+	// a rial short of a whole toman is dropped, not rounded up
+	return toman.Amount.Round(0, RoundDown).Format(0)
+}
 
-	// Reverse back and return.
-	r2 := ""
-	for i = len(r1) - 1; i >= 0; i-- {
-		r2 = r2 + string(r1[i])
+func (p Price) log() *slog.Logger {
+	if p.logger == nil {
+		return slog.Default()
 	}
-	return r2
+	return p.logger
 }
 
-func (p Price) toToman(rilaString string) string {
-	rilaInt, err := strconv.Atoi(strings.Replace(rilaString, ",", "", 10))
+func (p Price) String() string {
+	return p.Render(nil)
+}
 
-	if err != nil {
-		return "0"
-	}
-	return p.prettyNumber(rilaInt / 10)
+// Row is one asset of a price board, formatted for display.
+type Row struct {
+	Asset  Asset
+	Value  string // in the asset's display unit, like "82,150"
+	Change string // change figure with its staleness mark, like "(0.50%🟢)"
 }
 
-func (p Price) String() string {
+// Rows returns the assets with the given keys, in Assets order, or every
+// asset when keys is empty. Assets without a price are left out.
+func (p Price) Rows(keys []string) []Row {
+	var rows []Row
+	for _, a := range Assets {
+		if len(keys) > 0 && !slices.Contains(keys, a.Key) {
+			continue
+		}
 
-	return fmt.Sprintf(`ا📆 اخرین بروزرسانی: %02d:%02d:%02d %s
-
-ا🇺🇸 دلار امریکا %s <b>%s</b> تومان
-ا🇪🇺 یورو اروپا %s <b>%s</b> تومان
-ا🇬🇧 پوند انگلیس %s <b>%s</b> تومان
-ا🇨🇦 دلار کانادا %s <b>%s</b> تومان
-ا🇦🇺 دلار استرالیا %s <b>%s</b> تومان
-ا🇦🇪 درهم امارات %s <b>%s</b> تومان
-ا🇹🇷 لیر ترکیه %s <b>%s</b> تومان
-ا🇸🇪 کرون سوئد %s <b>%s</b> تومان
-ا🇨🇳 یوان چین %s <b>%s</b> تومان
-ا🇸🇦 ریال عربستان %s <b>%s</b> تومان
-ا🇮🇶 دینار عراق %s <b>%s</b> ریال
-
-ا👑 بیتکوین %s <b>%s</b> دلار
-ا🇺🇸 تتر %s <b>%s</b> تومان
-ا💠 اتریوم %s <b>%s</b> دلار
-
-ا🪙 سکه بهار آزادی %s <b>%s</b> تومان
-ا🪙 سکه امامی %s <b>%s</b> تومان
-ا🪙 نیم سکه %s <b>%s</b> تومان
-ا🪙 ربع سکه %s <b>%s</b> تومان
-ا🪙 ربع سکه قبل ۸۶ %s <b>%s</b> تومان
-
-ا💰 طلا گرمی %s <b>%s</b> تومان
-ا💰 مثقال طلا %s <b>%s</b> تومان
-ا💰 انس طلا %s <b>%s</b> دلار`,
-		p.LastRefresh.Hour(), p.LastRefresh.Minute(), p.LastRefresh.Second(), p.JLastRefresh.String(),
-		p.Current.Dollar.FormatChange(), p.toToman(p.Current.Dollar.Price),
-		p.Current.Eur.FormatChange(), p.toToman(p.Current.Eur.Price),
-		p.Current.GBP.FormatChange(), p.toToman(p.Current.GBP.Price),
-		p.Current.CAD.FormatChange(), p.toToman(p.Current.CAD.Price),
-		p.Current.AUD.FormatChange(), p.toToman(p.Current.AUD.Price),
-		p.Current.AED.FormatChange(), p.toToman(p.Current.AED.Price),
-		p.Current.TRY.FormatChange(), p.toToman(p.Current.TRY.Price),
-		p.Current.SEK.FormatChange(), p.toToman(p.Current.SEK.Price),
-		p.Current.CNY.FormatChange(), p.toToman(p.Current.CNY.Price),
-		p.Current.SAR.FormatChange(), p.toToman(p.Current.SAR.Price),
-		p.Current.IQD.FormatChange(), p.Current.IQD.Price,
-
-		p.Current.BitCoin.FormatChange(), p.Current.BitCoin.Price,
-		p.Current.Tether.FormatChange(), p.toToman(p.Current.Tether.Price),
-		p.Current.Ethereum.FormatChange(), p.Current.Ethereum.Price,
-
-		p.Current.SekeB.FormatChange(), p.toToman(p.Current.SekeB.Price),
-		p.Current.SekeE.FormatChange(), p.toToman(p.Current.SekeE.Price),
-		p.Current.Nim.FormatChange(), p.toToman(p.Current.Nim.Price),
-		p.Current.Rob.FormatChange(), p.toToman(p.Current.Rob.Price),
-		p.Current.RobDown.FormatChange(), p.toToman(p.Current.RobDown.Price),
-
-		p.Current.Geram18.FormatChange(), p.toToman(p.Current.Geram18.Price),
-		p.Current.Mesghal.FormatChange(), p.toToman(p.Current.Mesghal.Price),
-		p.Current.Ons.FormatChange(), p.Current.Ons.Price,
-	)
+		detail := a.Detail(&p.Current)
+		if detail.Price == "" {
+			continue
+		}
+		change := detail.FormatChange()
+		if p.Marks != nil {
+			change = detail.formatChange(p.Marks[a.Key])
+		}
+		value := detail.Price
+		if a.Unit == Toman {
+			value = p.toToman(detail.Price)
+		}
+		rows = append(rows, Row{Asset: a, Value: value, Change: change})
+	}
+	return rows
+}
+
+// Render renders the assets with the given keys, in Assets order, or every
+// asset when keys is empty.
+func (p Price) Render(keys []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ا📆 اخرین بروزرسانی: %02d:%02d:%02d %s\n",
+		p.LastRefresh.Hour(), p.LastRefresh.Minute(), p.LastRefresh.Second(), p.JLastRefresh.String())
+
+	var group Group
+	for _, row := range p.Rows(keys) {
+		if row.Asset.Group != group {
+			group = row.Asset.Group
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "ا%s %s %s <b>%s</b> %s\n", row.Asset.Emoji, row.Asset.Name, row.Change, row.Value, row.Asset.Unit.Label())
+	}
+	return strings.TrimSuffix(b.String(), "\n")
 }
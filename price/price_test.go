@@ -1,6 +1,7 @@
 package price
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -11,34 +12,7 @@ import (
 func TestPrice_Refresh(t *testing.T) {
 	// Create mock server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-
-		mockResponse := `{
-			"current": {
-				"price_dollar_rl": {"p": "500000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 2.45, "dt": "high"},
-				"price_eur": {"p": "550000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 1.23, "dt": "low"},
-				"price_gbp": {"p": "600000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 0, "dt": ""},
-				"price_cad": {"p": "400000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 1.5, "dt": "high"},
-				"price_aud": {"p": "350000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 0.5, "dt": "low"},
-				"price_aed": {"p": "140000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 0.75, "dt": "high"},
-				"price_try": {"p": "20000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 2.1, "dt": "low"},
-				"price_sek": {"p": "50000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 0, "dt": ""},
-				"price_cny": {"p": "80000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 1.1, "dt": "high"},
-				"price_sar": {"p": "130000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 0.8, "dt": "low"},
-				"price_iqd": {"p": "400", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 0.3, "dt": "high"},
-				"crypto-tether-irr": {"p": "510000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 0.1, "dt": "high"},
-				"crypto-bitcoin": {"p": "65000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 5.2, "dt": "high"},
-				"crypto-ethereum": {"p": "3500", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 3.1, "dt": "low"},
-				"sekeb": {"p": "30000000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 1.8, "dt": "high"},
-				"sekee": {"p": "31000000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 2.0, "dt": "high"},
-				"nim": {"p": "15000000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 0.9, "dt": "low"},
-				"rob": {"p": "9000000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 1.5, "dt": "low"},
-				"rob_down": {"p": "6000000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 1.2, "dt": "low"},
-				"geram18": {"p": "3000000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 0.7, "dt": "high"},
-				"mesghal": {"p": "13000000", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 0.5, "dt": "high"},
-				"ons": {"p": "2200", "ts": "2024-03-20 12:00:00", "t": "12:00", "dp": 0.4, "dt": "low"}
-			}
-		}`
-		w.Write([]byte(mockResponse))
+		http.ServeFile(w, r, "testdata/tgju_latest.json")
 	}))
 	defer server.Close()
 
@@ -52,7 +26,7 @@ func TestPrice_Refresh(t *testing.T) {
 	baseURL = server.URL
 
 	// Test Refresh
-	err := p.Refresh()
+	err := p.Refresh(context.Background())
 	if err != nil {
 		t.Errorf("Refresh failed: %v", err)
 	}
@@ -106,6 +80,40 @@ func TestPrice_Refresh(t *testing.T) {
 	}
 }
 
+func TestPrice_RefreshTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	originalTimeout := requestTimeout
+	defer func() { requestTimeout = originalTimeout }()
+
+	httpClient = server.Client()
+	baseURL = server.URL
+	requestTimeout = 50 * time.Millisecond
+
+	p := NewPrice()
+	start := time.Now()
+	if err := p.Refresh(context.Background()); err == nil {
+		t.Error("Expected Refresh to fail on a hung server")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Refresh took %s, expected it to honor the request timeout", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Refresh(ctx); err == nil {
+		t.Error("Expected Refresh to fail with a canceled context")
+	}
+}
+
 func TestPrice_String(t *testing.T) {
 
 	// Get Tehran location once for all test cases
@@ -138,27 +146,44 @@ func TestPrice_String(t *testing.T) {
 	}
 }
 
-func TestPrice_PrettyNumber(t *testing.T) {
-	p := NewPrice()
-	testCases := []struct {
-		input    int
-		expected string
-	}{
-		{1234, "1,234"},
-		{1234567, "1,234,567"},
-		{1000000, "1,000,000"},
-		{123, "123"},
-		{0, "0"},
+func TestPrice_StringMarks(t *testing.T) {
+	p := &Price{
+		Current: CurrentData{
+			Dollar: Detail{Price: "500000", DateTime: "2020-03-19 12:00:00", ChangePercentage: 2.45, ChangeDirection: "high"},
+			Eur:    Detail{Price: "550000", DateTime: "2020-03-19 12:00:00"},
+		},
+		Marks: map[string]string{"usd": "⏳"},
 	}
 
-	for _, tc := range testCases {
-		result := p.prettyNumber(tc.input)
-		if result != tc.expected {
-			t.Errorf("prettyNumber(%d) = %s; want %s", tc.input, result, tc.expected)
+	result := p.String()
+	for _, expected := range []string{
+		"دلار امریکا (⏳2.45%🟢) <b>50,000</b> تومان",
+		"یورو اروپا ⬅️ <b>55,000</b> تومان",
+	} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("Expected string to contain '%s', but it didn't %s", expected, result)
 		}
 	}
 }
 
+func TestPrice_Render(t *testing.T) {
+	p := &Price{
+		Current: CurrentData{
+			Dollar:  Detail{Price: "500000"},
+			Eur:     Detail{Price: "550000"},
+			BitCoin: Detail{Price: "65000"},
+		},
+	}
+
+	result := p.Render([]string{"btc", "usd"})
+	if !strings.Contains(result, "دلار امریکا ⬅️ <b>50,000</b> تومان\n\nا👑 بیتکوین ⬅️ <b>65000</b> دلار") {
+		t.Errorf("Expected usd and btc in Assets order, got %s", result)
+	}
+	if strings.Contains(result, "یورو") {
+		t.Errorf("Expected unselected assets to be left out, got %s", result)
+	}
+}
+
 func TestPrice_ToToman(t *testing.T) {
 	p := NewPrice()
 	testCases := []struct {
@@ -167,6 +192,7 @@ func TestPrice_ToToman(t *testing.T) {
 	}{
 		{"500000", "50,000"},
 		{"1,000,000", "100,000"},
+		{"123,459", "12,345"},
 		{"invalid", "0"},
 		{"", "0"},
 	}
@@ -178,3 +204,34 @@ func TestPrice_ToToman(t *testing.T) {
 		}
 	}
 }
+
+func TestCurrentData_Values(t *testing.T) {
+	c := CurrentData{
+		Dollar:  Detail{Price: "1,000,000"},
+		IQD:     Detail{Price: "400"},
+		BitCoin: Detail{Price: "65,432.10"},
+		Eur:     Detail{Price: "invalid"},
+	}
+
+	values := c.Values()
+
+	expected := map[string]string{"usd": "100000", "iqd": "400", "btc": "65432.10"}
+	for key, want := range expected {
+		if values[key].String() != want {
+			t.Errorf("Values()[%s] = %v; want %v", key, values[key], want)
+		}
+	}
+	if _, ok := values["eur"]; ok {
+		t.Error("Expected invalid eur price to be skipped")
+	}
+	if _, ok := values["gbp"]; ok {
+		t.Error("Expected missing gbp price to be skipped")
+	}
+
+	if a, ok := FindAsset(" USD "); !ok || a.Name != "دلار امریکا" {
+		t.Errorf("FindAsset(USD) = %v, %v", a, ok)
+	}
+	if _, ok := FindAsset("xyz"); ok {
+		t.Error("Expected unknown asset lookup to fail")
+	}
+}
@@ -0,0 +1,194 @@
+package price
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/onionj/pricebot/utils"
+)
+
+// historyURL is tgju's per-instrument daily history, formatted with the
+// instrument key. Make it a package variable so it can be modified in tests.
+var historyURL = "https://api.tgju.org/v1/market/indicator/summary-table-data/%s"
+
+// historyPageSize is how many days a history request asks for.
+var historyPageSize = 500
+
+// Provider is a source of prices.
+type Provider interface {
+	Name() string
+	// Latest returns the current price of every asset.
+	Latest(ctx context.Context) (CurrentData, error)
+	// History returns the daily bars of asset with from <= Date < to,
+	// oldest first.
+	History(ctx context.Context, asset Asset, from, to time.Time) ([]Bar, error)
+}
+
+// Bar is an asset's prices over one day, in the asset's unit.
+type Bar struct {
+	Date                   utils.JDate
+	Open, High, Low, Close Decimal
+}
+
+// TGJU fetches prices from tgju.org.
+type TGJU struct {
+	logger *slog.Logger
+}
+
+func (t *TGJU) Name() string {
+	return "tgju"
+}
+
+// SetLogger sets the logger used for request diagnostics.
+func (t *TGJU) SetLogger(logger *slog.Logger) {
+	t.logger = logger
+}
+
+func (t *TGJU) log() *slog.Logger {
+	if t.logger == nil {
+		return slog.Default()
+	}
+	return t.logger
+}
+
+// get fetches url into v. The request is bounded by ctx and by requestTimeout.
+func (t *TGJU) get(ctx context.Context, url string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Accept-Language", "fa-IR")
+
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	t.log().Debug("price response received",
+		"status", resp.StatusCode, "latency", time.Since(start))
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("error unmarshaling response: %w", err)
+	}
+	return nil
+}
+
+func (t *TGJU) Latest(ctx context.Context) (CurrentData, error) {
+	// ‍‍`what` just for deactivate cache!
+	url := fmt.Sprintf("%s?what=%d", baseURL, time.Now().Unix())
+
+	var response struct {
+		Current CurrentData `json:"current"`
+	}
+	if err := t.get(ctx, url, &response); err != nil {
+		return CurrentData{}, err
+	}
+	return response.Current, nil
+}
+
+// historyResponse is a page of tgju history. Each row holds open, low,
+// high and close in rial, the change and its percent as HTML, and the
+// Gregorian and Jalali dates.
+type historyResponse struct {
+	RecordsTotal int        `json:"recordsTotal"`
+	Data         [][]string `json:"data"`
+}
+
+// History pages through the asset's tgju history, newest first, until it
+// passes from.
+func (t *TGJU) History(ctx context.Context, asset Asset, from, to time.Time) ([]Bar, error) {
+	key := tgjuKey(asset)
+	var bars []Bar
+	for start := 0; ; start += historyPageSize {
+		query := url.Values{
+			"lang":      {"fa"},
+			"order_dir": {"desc"},
+			"start":     {fmt.Sprint(start)},
+			"length":    {fmt.Sprint(historyPageSize)},
+		}
+		var page historyResponse
+		if err := t.get(ctx, fmt.Sprintf(historyURL, key)+"?"+query.Encode(), &page); err != nil {
+			return nil, fmt.Errorf("%s history: %w", asset.Key, err)
+		}
+
+		passed := false
+		for _, row := range page.Data {
+			bar, err := parseHistoryRow(asset, row)
+			if err != nil {
+				return nil, fmt.Errorf("%s history: %w", asset.Key, err)
+			}
+			date := bar.Date.Time()
+			if date.Before(from) {
+				passed = true
+				break
+			}
+			if date.Before(to) {
+				bars = append(bars, bar)
+			}
+		}
+		if passed || len(page.Data) < historyPageSize || start+len(page.Data) >= page.RecordsTotal {
+			break
+		}
+	}
+
+	// oldest first
+	for i, j := 0, len(bars)-1; i < j; i, j = i+1, j-1 {
+		bars[i], bars[j] = bars[j], bars[i]
+	}
+	return bars, nil
+}
+
+func parseHistoryRow(asset Asset, row []string) (Bar, error) {
+	if len(row) < 8 {
+		return Bar{}, fmt.Errorf("history row has %d columns, want 8", len(row))
+	}
+	date, err := utils.ParseJDate(row[7])
+	if err != nil {
+		return Bar{}, err
+	}
+	bar := Bar{Date: date}
+	for i, field := range []*Decimal{&bar.Open, &bar.Low, &bar.High, &bar.Close} {
+		if *field, err = asset.Parse(row[i]); err != nil {
+			return Bar{}, fmt.Errorf("%s: %w", date, err)
+		}
+	}
+	return bar, nil
+}
+
+// tgjuKey returns the tgju instrument key of asset, the JSON name of its
+// CurrentData field, like "price_dollar_rl".
+func tgjuKey(asset Asset) string {
+	var c CurrentData
+	field := asset.field(&c)
+	v := reflect.ValueOf(&c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).Addr().Interface() == field {
+			name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+			return name
+		}
+	}
+	return asset.Key
+}
@@ -0,0 +1,67 @@
+package price
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/onionj/pricebot/utils"
+)
+
+func TestTGJUKey(t *testing.T) {
+	tests := map[string]string{"usd": "price_dollar_rl", "sekee": "sekee", "btc": "crypto-bitcoin", "usdt": "crypto-tether-irr"}
+	for key, expected := range tests {
+		asset, _ := FindAsset(key)
+		if got := tgjuKey(asset); got != expected {
+			t.Errorf("%s: expected %s, got %s", key, expected, got)
+		}
+	}
+}
+
+func TestTGJU_History(t *testing.T) {
+	// five days of history, newest first, served two rows per page
+	rows := [][]string{
+		{"825,000", "820,000", "830,000", "828,000", "<span>3,000</span>", "<span>0.36%</span>", "2025/05/07", "1404/02/17"},
+		{"821,000", "818,000", "826,000", "825,000", "", "", "2025/05/06", "1404/02/16"},
+		{"815,000", "812,000", "822,000", "821,000", "", "", "2025/05/05", "1404/02/15"},
+		{"810,000", "808,000", "816,000", "815,000", "", "", "2025/05/04", "1404/02/14"},
+		{"805,000", "800,000", "811,000", "810,000", "", "", "2025/05/03", "1404/02/13"},
+	}
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path+"?"+r.URL.RawQuery)
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		end := min(start+2, len(rows))
+		json.NewEncoder(w).Encode(historyResponse{RecordsTotal: len(rows), Data: rows[start:end]})
+	}))
+	defer server.Close()
+
+	originalURL, originalSize := historyURL, historyPageSize
+	defer func() { historyURL, historyPageSize = originalURL, originalSize }()
+	httpClient = server.Client()
+	historyURL = server.URL + "/history/%s"
+	historyPageSize = 2
+
+	usd, _ := FindAsset("usd")
+	from := utils.JDate{Year: 1404, Month: 2, Day: 14}.Time()
+	to := utils.JDate{Year: 1404, Month: 2, Day: 17}.Time()
+	bars, err := (&TGJU{}).History(context.Background(), usd, from, to)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(bars) != 3 || bars[0].Date.String() != "1404/02/14" || bars[2].Date.String() != "1404/02/16" {
+		t.Fatalf("Expected 1404/02/14 to 1404/02/16 oldest first, got %+v", bars)
+	}
+	if bar := bars[2]; bar.Open.String() != "82100" || bar.Low.String() != "81800" || bar.High.String() != "82600" || bar.Close.String() != "82500" {
+		t.Errorf("Expected the bar in toman, got %+v", bar)
+	}
+	// the third page starts before from, so no fourth is requested
+	if len(requests) != 3 || requests[0] != "/history/price_dollar_rl?lang=fa&length=2&order_dir=desc&start=0" {
+		t.Errorf("Expected 3 paged requests, got %v", requests)
+	}
+
+}
@@ -0,0 +1,233 @@
+package price
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/onionj/pricebot/utils"
+)
+
+// tgju's layouts of Detail.DateTime and Detail.Time
+const (
+	detailDateTime = "2006-01-02 15:04:05"
+	detailTime     = "15:04:05"
+)
+
+// Replay is a Provider that plays back the frames of a fixture. Update
+// times in the frames are moved forward with the playback, so prices are
+// as fresh or stale as they were when recorded.
+type Replay struct {
+	frames []Frame
+	speed  float64
+	loop   bool
+	now    func() time.Time
+
+	start time.Time // when the first frame was played
+	next  int       // the frame played next when stepping
+}
+
+// NewReplay plays frames back speed times faster than they were recorded,
+// 1 for real time, or one frame per Latest call when speed is 0. With loop
+// it starts over after the last frame, otherwise it keeps returning it.
+func NewReplay(frames []Frame, speed float64, loop bool) (*Replay, error) {
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no frames to replay")
+	}
+	if speed < 0 {
+		return nil, fmt.Errorf("invalid replay speed %v", speed)
+	}
+	return &Replay{frames: frames, speed: speed, loop: loop, now: time.Now}, nil
+}
+
+func (r *Replay) Name() string {
+	return "replay"
+}
+
+func (r *Replay) Latest(ctx context.Context) (CurrentData, error) {
+	now := r.now()
+	frame := r.frames[r.index(now)]
+	return retime(frame.Current, now.Sub(frame.Time)), nil
+}
+
+// index returns the frame due at now.
+func (r *Replay) index(now time.Time) int {
+	last := len(r.frames) - 1
+	if r.speed == 0 {
+		i := r.next
+		r.next++
+		if r.loop {
+			return i % len(r.frames)
+		}
+		return min(i, last)
+	}
+
+	if r.start.IsZero() {
+		r.start = now
+	}
+	offset := time.Duration(float64(now.Sub(r.start)) * r.speed)
+	if span := r.frames[last].Time.Sub(r.frames[0].Time); r.loop && span > 0 {
+		offset %= span
+	}
+	at := r.frames[0].Time.Add(offset)
+	i := 0
+	for i < last && !r.frames[i+1].Time.After(at) {
+		i++
+	}
+	return i
+}
+
+// History returns the daily bars of the frames, each day opening with its
+// first frame and closing with its last.
+func (r *Replay) History(ctx context.Context, asset Asset, from, to time.Time) ([]Bar, error) {
+	var bars []Bar
+	for _, frame := range r.frames {
+		if frame.Time.Before(from) || !frame.Time.Before(to) {
+			continue
+		}
+		value, err := asset.Value(&frame.Current)
+		if err != nil {
+			continue
+		}
+		date := utils.ToJalali(frame.Time)
+		if n := len(bars); n > 0 && bars[n-1].Date == date {
+			bar := &bars[n-1]
+			bar.Close = value
+			if value.Cmp(bar.High) > 0 {
+				bar.High = value
+			}
+			if value.Cmp(bar.Low) < 0 {
+				bar.Low = value
+			}
+			continue
+		}
+		bars = append(bars, Bar{Date: date, Open: value, High: value, Low: value, Close: value})
+	}
+	return bars, nil
+}
+
+// retime moves the update times of every asset in c forward by shift.
+func retime(c CurrentData, shift time.Duration) CurrentData {
+	for _, a := range Assets {
+		detail := a.Detail(&c)
+		updated, err := detail.Updated()
+		if err != nil {
+			continue
+		}
+		updated = updated.Add(shift)
+		detail.DateTime = updated.Format(detailDateTime)
+		detail.Time = updated.Format(detailTime)
+		a.SetDetail(&c, detail)
+	}
+	return c
+}
+
+// simulationStart are the tgju prices a RandomWalk starts from when given
+// none, in rial for toman assets.
+var simulationStart = map[string]string{
+	"usd": "821,500", "eur": "950,000", "gbp": "1,110,000", "cad": "600,000",
+	"aud": "540,000", "aed": "224,000", "try": "21,500", "sek": "86,000",
+	"cny": "114,000", "sar": "219,000", "iqd": "62",
+	"btc": "95,000", "usdt": "830,000", "eth": "1,800",
+	"sekeb": "700,000,000", "sekee": "740,000,000", "nim": "400,000,000",
+	"rob": "240,000,000", "rob_down": "230,000,000",
+	"geram18": "68,000,000", "mesghal": "295,000,000", "ons": "3,300",
+}
+
+// RandomWalk is a Provider that makes prices up: each Latest call moves
+// every asset a random step from where it was, now and then with a jump,
+// to exercise alerts, anomaly detection and charts offline.
+type RandomWalk struct {
+	// Volatility is the standard deviation of a step, as a fraction of the price
+	Volatility float64
+	// SpikeChance is the chance of a jump in a step, SpikeSize its size as
+	// a fraction of the price, up or down
+	SpikeChance, SpikeSize float64
+
+	rand   *rand.Rand
+	now    func() time.Time
+	values map[string]float64 // in tgju's units
+	opens  map[string]float64 // the starting values changes are measured from
+}
+
+// NewRandomWalk starts a walk from the prices in start, or from typical
+// prices for assets start has none of. The same seed makes the same walk.
+func NewRandomWalk(start CurrentData, seed int64) *RandomWalk {
+	w := &RandomWalk{
+		Volatility:  0.001,
+		SpikeChance: 0.01,
+		SpikeSize:   0.05,
+		rand:        rand.New(rand.NewSource(seed)),
+		now:         time.Now,
+		values:      make(map[string]float64),
+		opens:       make(map[string]float64),
+	}
+	for _, a := range Assets {
+		raw := a.Detail(&start).Price
+		if raw == "" {
+			raw = simulationStart[a.Key]
+		}
+		value, err := ParseDecimal(raw)
+		if err != nil || value.Sign() <= 0 {
+			continue
+		}
+		w.values[a.Key] = value.Float64()
+		w.opens[a.Key] = value.Float64()
+	}
+	return w
+}
+
+func (w *RandomWalk) Name() string {
+	return "random"
+}
+
+func (w *RandomWalk) Latest(ctx context.Context) (CurrentData, error) {
+	now := w.now().In(utils.Tehran)
+	var c CurrentData
+	for _, a := range Assets {
+		value, ok := w.values[a.Key]
+		if !ok {
+			continue
+		}
+		value *= math.Exp(w.Volatility * w.rand.NormFloat64())
+		if w.rand.Float64() < w.SpikeChance {
+			if w.rand.Intn(2) == 0 {
+				value *= 1 + w.SpikeSize
+			} else {
+				value *= 1 - w.SpikeSize
+			}
+		}
+		places := int32(0)
+		if a.Unit == USD {
+			places = 2
+		}
+		rounded := DecimalFromFloat(value).Round(places, RoundHalfUp)
+		if rounded.Sign() <= 0 {
+			rounded = NewDecimal(1, places)
+		}
+		w.values[a.Key] = rounded.Float64()
+
+		detail := Detail{
+			Price:    rounded.Format(places),
+			DateTime: now.Format(detailDateTime),
+			Time:     now.Format(detailTime),
+		}
+		change := (w.values[a.Key]/w.opens[a.Key] - 1) * 100
+		detail.ChangePercentage = math.Round(math.Abs(change)*100) / 100
+		switch {
+		case change > 0:
+			detail.ChangeDirection = "high"
+		case change < 0:
+			detail.ChangeDirection = "low"
+		}
+		a.SetDetail(&c, detail)
+	}
+	return c, nil
+}
+
+// History is empty; a walk only exists from its first step.
+func (w *RandomWalk) History(ctx context.Context, asset Asset, from, to time.Time) ([]Bar, error) {
+	return nil, nil
+}
@@ -0,0 +1,258 @@
+package price
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/utils"
+)
+
+const testFixture = "testdata/tgju.jsonl"
+
+// fakeProvider returns fixed prices, or err.
+type fakeProvider struct {
+	current CurrentData
+	err     error
+}
+
+func (f *fakeProvider) Name() string { return "fake" }
+
+func (f *fakeProvider) Latest(ctx context.Context) (CurrentData, error) {
+	return f.current, f.err
+}
+
+func (f *fakeProvider) History(ctx context.Context, asset Asset, from, to time.Time) ([]Bar, error) {
+	return nil, nil
+}
+
+// clock is a fake time source tests move by hand.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func usdValue(t *testing.T, c CurrentData) string {
+	t.Helper()
+	usd, _ := FindAsset("usd")
+	value, err := usd.Value(&c)
+	if err != nil {
+		t.Fatalf("Expected a usd price, got %v", err)
+	}
+	return value.String()
+}
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.jsonl")
+	fake := &fakeProvider{}
+	fake.current.Dollar = Detail{Price: "821,500", DateTime: "2025-05-06 11:58:10"}
+	recorder := NewRecorder(fake, path)
+	c := &clock{time.Date(2025, 5, 6, 12, 0, 0, 0, utils.Tehran)}
+	recorder.now = c.now
+
+	for _, p := range []string{"821,500", "822,000"} {
+		fake.current.Dollar.Price = p
+		if _, err := recorder.Latest(context.Background()); err != nil {
+			t.Fatalf("Latest failed: %v", err)
+		}
+		c.t = c.t.Add(time.Minute)
+	}
+	fake.err = errors.New("down")
+	if _, err := recorder.Latest(context.Background()); err == nil {
+		t.Errorf("Expected the provider error")
+	}
+	if recorder.Name() != "fake" {
+		t.Errorf("Expected the recorded provider's name, got %s", recorder.Name())
+	}
+
+	frames, err := LoadFixture(path)
+	if err != nil {
+		t.Fatalf("LoadFixture failed: %v", err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("Expected 2 frames, failures left out, got %d", len(frames))
+	}
+	if !frames[1].Time.Equal(time.Date(2025, 5, 6, 12, 1, 0, 0, utils.Tehran)) || usdValue(t, frames[1].Current) != "82200" {
+		t.Errorf("Expected 82200 at 12:01, got %+v", frames[1])
+	}
+}
+
+func TestLoadFixture_Errors(t *testing.T) {
+	testCases := map[string]string{
+		"empty":     "",
+		"not json":  "{\n",
+		"backwards": `{"time":"2025-05-06T12:01:00Z","current":{}}` + "\n" + `{"time":"2025-05-06T12:00:00Z","current":{}}` + "\n",
+	}
+	for name, data := range testCases {
+		path := filepath.Join(t.TempDir(), "fixture.jsonl")
+		os.WriteFile(path, []byte(data), 0644)
+		if _, err := LoadFixture(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestReplay_Speed(t *testing.T) {
+	frames, err := LoadFixture(testFixture)
+	if err != nil {
+		t.Fatalf("LoadFixture failed: %v", err)
+	}
+	replay, err := NewReplay(frames, 60, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, utils.Tehran)
+	c := &clock{start}
+	replay.now = c.now
+
+	// at 60x a recorded minute passes every second
+	testCases := []struct {
+		after    time.Duration
+		expected string
+	}{
+		{0, "82150"},
+		{500 * time.Millisecond, "82150"},
+		{time.Second, "82200"},
+		{2 * time.Second, "86500"},
+		{time.Minute, "86500"}, // holds the last frame
+	}
+	for _, tc := range testCases {
+		c.t = start.Add(tc.after)
+		current, _ := replay.Latest(context.Background())
+		if got := usdValue(t, current); got != tc.expected {
+			t.Errorf("After %s: expected %s, got %s", tc.after, tc.expected, got)
+		}
+	}
+
+	// update times move with the playback: 1m50s old when recorded, so
+	// 1m50s old now
+	c.t = start
+	replay, _ = NewReplay(frames, 1, false)
+	replay.now = c.now
+	current, _ := replay.Latest(context.Background())
+	updated, err := current.Dollar.Updated()
+	if err != nil || c.t.Sub(updated) != 110*time.Second {
+		t.Errorf("Expected the dollar updated 1m50s ago, got %s (%v)", c.t.Sub(updated), err)
+	}
+	if current.Dollar.Time != updated.Format("15:04:05") {
+		t.Errorf("Expected the time of day moved too, got %s", current.Dollar.Time)
+	}
+}
+
+func TestReplay_Step(t *testing.T) {
+	frames, _ := LoadFixture(testFixture)
+	for _, tc := range []struct {
+		loop     bool
+		expected []string
+	}{
+		{false, []string{"82150", "82200", "86500", "86500"}},
+		{true, []string{"82150", "82200", "86500", "82150"}},
+	} {
+		replay, _ := NewReplay(frames, 0, tc.loop)
+		for i, expected := range tc.expected {
+			current, _ := replay.Latest(context.Background())
+			if got := usdValue(t, current); got != expected {
+				t.Errorf("Loop %v, call %d: expected %s, got %s", tc.loop, i, expected, got)
+			}
+		}
+	}
+}
+
+func TestReplay_History(t *testing.T) {
+	frames, _ := LoadFixture(testFixture)
+	replay, _ := NewReplay(frames, 1, false)
+	usd, _ := FindAsset("usd")
+
+	day := utils.JDate{Year: 1404, Month: 2, Day: 16}
+	bars, err := replay.History(context.Background(), usd, day.Time(), day.AddDays(1).Time())
+	if err != nil || len(bars) != 1 {
+		t.Fatalf("Expected one bar, got %+v, %v", bars, err)
+	}
+	bar := bars[0]
+	if bar.Date != day || bar.Open.String() != "82150" || bar.High.String() != "86500" || bar.Low.String() != "82150" || bar.Close.String() != "86500" {
+		t.Errorf("Expected 82150 up to 86500 on %s, got %+v", day, bar)
+	}
+}
+
+func TestRandomWalk(t *testing.T) {
+	var start CurrentData
+	start.Dollar = Detail{Price: "1,000,000"}
+	c := &clock{time.Date(2026, 1, 1, 9, 0, 0, 0, utils.Tehran)}
+
+	walk := func(seed int64) []CurrentData {
+		w := NewRandomWalk(start, seed)
+		w.now = c.now
+		var steps []CurrentData
+		for range 50 {
+			current, err := w.Latest(context.Background())
+			if err != nil {
+				t.Fatalf("Latest failed: %v", err)
+			}
+			steps = append(steps, current)
+		}
+		return steps
+	}
+
+	a, b := walk(1), walk(1)
+	moved := false
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Expected the same walk from the same seed, step %d differs", i)
+		}
+		if a[i].Dollar.Price != "1,000,000" {
+			moved = true
+		}
+	}
+	if !moved {
+		t.Errorf("Expected the dollar to move")
+	}
+
+	last := a[len(a)-1]
+	values := last.Values()
+	if len(values) != len(Assets) {
+		t.Errorf("Expected a price for every asset, got %d of %d", len(values), len(Assets))
+	}
+	if v := values["usd"].Float64(); v < 90000 || v > 110000 {
+		t.Errorf("Expected the dollar near 100,000 toman, got %v", v)
+	}
+	direction := map[bool]string{true: "high", false: "low"}[values["usd"].Float64() > 100000]
+	if last.Dollar.ChangeDirection != direction {
+		t.Errorf("Expected direction %s, got %+v", direction, last.Dollar)
+	}
+	if updated, err := last.Dollar.Updated(); err != nil || !updated.Equal(c.t) {
+		t.Errorf("Expected the dollar updated now, got %s (%v)", last.Dollar.DateTime, err)
+	}
+}
+
+func TestRandomWalk_Spikes(t *testing.T) {
+	var start CurrentData
+	start.Dollar = Detail{Price: "1,000,000"}
+	w := NewRandomWalk(start, 7)
+	w.Volatility, w.SpikeChance, w.SpikeSize = 0, 1, 0.1
+
+	current, _ := w.Latest(context.Background())
+	if v := usdValue(t, current); v != "110000" && v != "90000" {
+		t.Errorf("Expected a 10%% jump, got %s", v)
+	}
+	if current.Dollar.ChangePercentage != 10 {
+		t.Errorf("Expected a 10%% change, got %v", current.Dollar.ChangePercentage)
+	}
+}
+
+func TestPrice_RefreshFromReplay(t *testing.T) {
+	frames, _ := LoadFixture(testFixture)
+	replay, _ := NewReplay(frames, 0, false)
+	p := NewPriceFrom(replay)
+
+	if err := p.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if p.Provider() != "replay" {
+		t.Errorf("Expected the replay provider, got %s", p.Provider())
+	}
+	if got := p.Current.Values()["btc"].String(); got != "95120.5" {
+		t.Errorf("Expected btc 95120.5, got %s", got)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/onionj/pricebot/price"
+)
+
+// newProvider returns the price provider PRICE_PROVIDER names: tgju (the
+// default), replay of the PRICE_FIXTURE file, or random prices starting
+// from PRICE_FIXTURE's last frame when set. With PRICE_RECORD_FILE every
+// response is also appended to that fixture.
+func newProvider(getenv func(string) string, logger *slog.Logger) (price.Provider, error) {
+	fixture := getenv("PRICE_FIXTURE")
+	var frames []price.Frame
+	if fixture != "" {
+		var err error
+		if frames, err = price.LoadFixture(fixture); err != nil {
+			return nil, fmt.Errorf("invalid PRICE_FIXTURE: %w", err)
+		}
+	}
+
+	var provider price.Provider
+	switch name := getenv("PRICE_PROVIDER"); name {
+	case "", "tgju":
+		provider = &price.TGJU{}
+	case "replay":
+		if fixture == "" {
+			return nil, errors.New("PRICE_PROVIDER=replay needs PRICE_FIXTURE")
+		}
+		speed := 1.0
+		if s := getenv("PRICE_REPLAY_SPEED"); s != "" {
+			var err error
+			if speed, err = strconv.ParseFloat(s, 64); err != nil {
+				return nil, fmt.Errorf("invalid PRICE_REPLAY_SPEED %q", s)
+			}
+		}
+		replay, err := price.NewReplay(frames, speed, getenv("PRICE_REPLAY_LOOP") != "false")
+		if err != nil {
+			return nil, err
+		}
+		provider = replay
+		logger.Info("replaying prices", "fixture", fixture, "frames", len(frames), "speed", speed)
+	case "random":
+		seed := time.Now().UnixNano()
+		if s := getenv("PRICE_SEED"); s != "" {
+			var err error
+			if seed, err = strconv.ParseInt(s, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid PRICE_SEED %q", s)
+			}
+		}
+		var start price.CurrentData
+		if len(frames) > 0 {
+			start = frames[len(frames)-1].Current
+		}
+		provider = price.NewRandomWalk(start, seed)
+		// the seed is logged so a walk worth looking at again can be repeated
+		logger.Info("simulating prices", "seed", seed)
+	default:
+		return nil, fmt.Errorf("unknown PRICE_PROVIDER %q, want tgju, replay or random", name)
+	}
+
+	if file := getenv("PRICE_RECORD_FILE"); file != "" {
+		provider = price.NewRecorder(provider, file)
+		logger.Info("recording prices", "file", file)
+	}
+	return provider, nil
+}
+
+// recordCommand runs "pricebot record", which appends provider's prices to a
+// fixture file every interval, for replaying later with
+// PRICE_PROVIDER=replay.
+func recordCommand(ctx context.Context, args []string, provider price.Provider, logger *slog.Logger, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("record", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	out := flags.String("o", "prices.jsonl", "fixture file the frames are appended to")
+	every := flags.Duration("every", time.Minute, "time between frames")
+	count := flags.Int("count", 0, "frames to record, 0 until interrupted")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if *every <= 0 {
+		return fmt.Errorf("invalid -every %s", *every)
+	}
+
+	p := price.NewPriceFrom(price.NewRecorder(provider, *out))
+	p.SetLogger(logger)
+	ticker := time.NewTicker(*every)
+	defer ticker.Stop()
+	recorded := 0
+	for *count == 0 || recorded < *count {
+		// a failed refresh is logged and skipped, leaving a longer gap
+		// between frames rather than ending the recording
+		if err := p.Refresh(ctx); err != nil && ctx.Err() == nil {
+			logger.Warn("error recording prices", "error", err)
+		} else if err == nil {
+			recorded++
+			fmt.Fprintf(stdout, "%s frame %d recorded to %s\n", p.LastRefresh.Format(time.DateTime), recorded, *out)
+		}
+		if *count != 0 && recorded == *count {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/onionj/pricebot/state"
+)
+
+// discordDescriptionLimit is the longest embed description Discord accepts.
+const discordDescriptionLimit = 4096
+
+// Discord keeps the live message in a channel through a webhook, editing
+// it by the message ID the webhook returned.
+type Discord struct {
+	webhook string
+	last    *tracker
+}
+
+// NewDiscord publishes through webhookURL, like
+// https://discord.com/api/webhooks/<id>/<token>.
+func NewDiscord(webhookURL string, store state.Store) (*Discord, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Scheme == "" {
+		return nil, fmt.Errorf("invalid discord webhook URL")
+	}
+	// the key holds the webhook ID but not its token
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid discord webhook URL")
+	}
+	last, err := newTracker(store, "discord:"+parts[len(parts)-2])
+	if err != nil {
+		return nil, err
+	}
+	return &Discord{webhook: strings.TrimSuffix(webhookURL, "/"), last: last}, nil
+}
+
+func (d *Discord) Name() string { return "discord" }
+
+type discordMessage struct {
+	ID string `json:"id"`
+}
+
+func (d *Discord) SendMessage(ctx context.Context, s Snapshot) error {
+	var msg discordMessage
+	err := request{
+		platform: "discord",
+		action:   "send message",
+		method:   http.MethodPost,
+		url:      d.webhook + "?wait=true",
+		payload:  discordPayload(s),
+	}.do(ctx, &msg)
+	if err != nil {
+		return err
+	}
+	return d.last.set(msg.ID)
+}
+
+func (d *Discord) UpdateMessage(ctx context.Context, s Snapshot) error {
+	return request{
+		platform: "discord",
+		action:   "update message",
+		method:   http.MethodPatch,
+		url:      d.webhook + "/messages/" + url.PathEscape(d.last.last.Ref),
+		payload:  discordPayload(s),
+	}.do(ctx, nil)
+}
+
+func (d *Discord) HasMessage() bool {
+	return d.last.last.Ref != ""
+}
+
+// discordPayload puts the board in an embed, whose description fits far
+// more than a plain message.
+func discordPayload(s Snapshot) map[string]any {
+	description := DiscordText(s)
+	if runes := []rune(description); len(runes) > discordDescriptionLimit {
+		description = string(runes[:discordDescriptionLimit])
+	}
+	return map[string]any{
+		"content":          "",
+		"embeds":           []map[string]any{{"description": description}},
+		"allowed_mentions": map[string]any{"parse": []string{}},
+	}
+}
+
+// DiscordText renders s in Discord markdown.
+func DiscordText(s Snapshot) string {
+	return render(s, markup{
+		bold:   func(text string) string { return "**" + text + "**" },
+		link:   func(text, url string) string { return fmt.Sprintf("[%s](%s)", text, url) },
+		escape: discordEscape.Replace,
+	})
+}
+
+var discordEscape = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "|", `\|`)
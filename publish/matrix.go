@@ -0,0 +1,113 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/onionj/pricebot/state"
+)
+
+// Matrix keeps the live message in a room, editing it with m.replace
+// relations as clients like Element show them.
+type Matrix struct {
+	homeserver string
+	token      string
+	room       string
+	last       *tracker
+	txn        atomic.Int64
+}
+
+// NewMatrix publishes to room, like !abc:matrix.org, as the user of token.
+func NewMatrix(homeserver, token, room string, store state.Store) (*Matrix, error) {
+	if homeserver == "" || token == "" || room == "" {
+		return nil, fmt.Errorf("matrix needs a homeserver, an access token and a room ID")
+	}
+	last, err := newTracker(store, "matrix:"+room)
+	if err != nil {
+		return nil, err
+	}
+	return &Matrix{homeserver: strings.TrimSuffix(homeserver, "/"), token: token, room: room, last: last}, nil
+}
+
+func (m *Matrix) Name() string { return "matrix" }
+
+type matrixEvent struct {
+	EventID string `json:"event_id"`
+}
+
+func (m *Matrix) SendMessage(ctx context.Context, s Snapshot) error {
+	eventID, err := m.send(ctx, "send message", matrixContent(s, ""))
+	if err != nil {
+		return err
+	}
+	return m.last.set(eventID)
+}
+
+// UpdateMessage sends an edit event replacing the last message. Clients
+// without edit support show the fallback body, marked with a leading "*".
+func (m *Matrix) UpdateMessage(ctx context.Context, s Snapshot) error {
+	content := matrixContent(s, "* ")
+	content["m.new_content"] = matrixContent(s, "")
+	content["m.relates_to"] = map[string]any{"rel_type": "m.replace", "event_id": m.last.last.Ref}
+	_, err := m.send(ctx, "update message", content)
+	return err
+}
+
+func (m *Matrix) HasMessage() bool {
+	return m.last.last.Ref != ""
+}
+
+func (m *Matrix) send(ctx context.Context, action string, content map[string]any) (string, error) {
+	// transaction IDs only need to be unique per access token
+	txnID := fmt.Sprintf("pricebot.%d.%d", time.Now().UnixNano(), m.txn.Add(1))
+
+	var event matrixEvent
+	err := request{
+		platform: "matrix",
+		action:   action,
+		method:   http.MethodPut,
+		url: fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+			m.homeserver, url.PathEscape(m.room), txnID),
+		token:   m.token,
+		payload: content,
+	}.do(ctx, &event)
+	return event.EventID, err
+}
+
+// matrixContent is a notice, the message type meant for bots, with a plain
+// body and an HTML body. prefix marks edit fallbacks.
+func matrixContent(s Snapshot, prefix string) map[string]any {
+	return map[string]any{
+		"msgtype":        "m.notice",
+		"body":           prefix + MatrixText(s),
+		"format":         "org.matrix.custom.html",
+		"formatted_body": prefix + MatrixHTML(s),
+	}
+}
+
+// MatrixText renders s as plain text.
+func MatrixText(s Snapshot) string {
+	return render(s, markup{
+		bold:   func(text string) string { return text },
+		link:   func(text, url string) string { return text + ": " + url },
+		escape: func(text string) string { return text },
+	})
+}
+
+// MatrixHTML renders s in the HTML subset Matrix clients display.
+func MatrixHTML(s Snapshot) string {
+	text := render(s, markup{
+		bold: func(text string) string { return "<b>" + html.EscapeString(text) + "</b>" },
+		link: func(text, url string) string {
+			return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(url), html.EscapeString(text))
+		},
+		escape: html.EscapeString,
+	})
+	return strings.ReplaceAll(text, "\n", "<br>\n")
+}
@@ -0,0 +1,194 @@
+// Package publish posts the live price message to chat platforms. Each
+// Publisher keeps one live message up to date and formats it from a shared
+// Snapshot in its platform's markup.
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/report"
+	"github.com/onionj/pricebot/state"
+)
+
+// Make these package variables so they can be modified in tests
+var (
+	httpClient     = &http.Client{}
+	requestTimeout = 15 * time.Second
+)
+
+// Publisher keeps a live message on one platform.
+type Publisher interface {
+	// Name identifies the publisher in logs and status, like "discord".
+	Name() string
+	// SendMessage posts s as a new live message and tracks it as the last message.
+	SendMessage(ctx context.Context, s Snapshot) error
+	// UpdateMessage edits the last message to show s.
+	UpdateMessage(ctx context.Context, s Snapshot) error
+	// HasMessage reports whether there is a last message to edit.
+	HasMessage() bool
+}
+
+// Snapshot is what a live message shows, independent of any markup.
+type Snapshot struct {
+	Price   price.Price
+	Changes []report.AssetChanges
+	// Notice explains slower updates, like closed markets
+	Notice string
+	// NextUpdate is the time left until the next price refresh
+	NextUpdate time.Duration
+	// Ending is set for the final form of a message about to be replaced
+	Ending    bool
+	Channel   string
+	ProxyLink string
+}
+
+// markup is how a platform formats the parts of a message.
+type markup struct {
+	bold   func(string) string
+	link   func(text, url string) string
+	escape func(string) string
+}
+
+// render lays a snapshot out the same way on every platform but Telegram,
+// whose message keeps its own layout.
+func render(s Snapshot, m markup) string {
+	var lines []string
+	if !s.Ending {
+		if seconds := int64(s.NextUpdate.Seconds()); seconds >= 7 {
+			lines = append(lines, fmt.Sprintf("⏰ تا بروزرسانی بعدی قیمت ها: %s ثانیه", m.bold(fmt.Sprintf("%02d", seconds))))
+		} else {
+			lines = append(lines, "🔄 درحال بروزرسانی قیمت ها")
+		}
+	}
+	if s.Notice != "" {
+		lines = append(lines, m.escape(s.Notice))
+	}
+
+	refresh := s.Price.LastRefresh
+	lines = append(lines, fmt.Sprintf("📆 اخرین بروزرسانی: %02d:%02d:%02d %s",
+		refresh.Hour(), refresh.Minute(), refresh.Second(), s.Price.JLastRefresh.String()))
+
+	var group price.Group
+	for _, row := range s.Price.Rows(nil) {
+		if row.Asset.Group != group {
+			group = row.Asset.Group
+			lines = append(lines, "")
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s %s %s",
+			row.Asset.Emoji, row.Asset.Name, row.Change, m.bold(row.Value), row.Asset.Unit.Label()))
+	}
+
+	var changes []string
+	for _, c := range s.Changes {
+		if text := report.ChangeText(c); text != "" {
+			changes = append(changes, "📈 "+m.escape(text))
+		}
+	}
+	if len(changes) > 0 {
+		lines = append(append(lines, ""), changes...)
+	}
+
+	lines = append(lines, "")
+	if s.ProxyLink != "" && !s.Ending {
+		lines = append(lines, m.link("🗝 پروکسی", s.ProxyLink))
+	}
+	if s.Channel != "" {
+		lines = append(lines, m.escape(s.Channel))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// tracker remembers a publisher's last message in the state store.
+type tracker struct {
+	store state.Store
+	key   string
+	last  state.Message
+}
+
+func newTracker(store state.Store, key string) (*tracker, error) {
+	t := &tracker{store: store, key: key}
+	if _, err := store.Get(state.Messages, key, &t.last); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *tracker) set(ref string) error {
+	t.last = state.Message{Ref: ref, PostedAt: time.Now()}
+	return t.store.Put(state.Messages, t.key, t.last)
+}
+
+// Error is a request a platform rejected.
+type Error struct {
+	Platform string
+	Action   string // what failed, like "send message"
+	Status   int
+	Detail   string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: failed to %s: status:%d, detail:%s", e.Platform, e.Action, e.Status, e.Detail)
+}
+
+// request is an HTTP call to a platform API.
+type request struct {
+	platform string
+	action   string
+	method   string
+	url      string
+	token    string // sent as a bearer token when set
+	payload  any
+}
+
+// do sends r bounded by ctx and requestTimeout and decodes a successful
+// response into out, if not nil. Errors never include the URL, which may
+// hold a secret such as a webhook token.
+func (r request) do(ctx context.Context, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(r.payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, r.url, bytes.NewReader(body))
+	if err != nil {
+		return &Error{Platform: r.platform, Action: r.action, Detail: "invalid request"}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return &Error{Platform: r.platform, Action: r.action, Detail: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return &Error{Platform: r.platform, Action: r.action, Status: resp.StatusCode, Detail: strings.TrimSpace(string(data))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,254 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/state"
+	"github.com/onionj/pricebot/telegram"
+)
+
+func testSnapshot() Snapshot {
+	return Snapshot{
+		Price: price.Price{
+			LastRefresh: time.Date(2025, 5, 6, 12, 30, 0, 0, time.UTC),
+			Current: price.CurrentData{
+				Dollar: price.Detail{Price: "821,500", ChangePercentage: 0.5, ChangeDirection: "high"},
+			},
+		},
+		NextUpdate: 23 * time.Second,
+		Channel:    "@prices",
+		ProxyLink:  "https://t.me/proxy?server=a&port=1",
+	}
+}
+
+func TestTelegramText(t *testing.T) {
+	tests := []struct {
+		name     string
+		next     time.Duration
+		ending   bool
+		notice   string
+		expected []string
+	}{
+		{"countdown", 23 * time.Second, false, "", []string{"ا⏰ تا بروزرسانی بعدی قیمت ها: <b>23</b> ثانیه", "<b>82,150</b>", `<a href="https://t.me/proxy?server=a&port=1">`, "@prices"}},
+		{"refreshing", 2 * time.Second, false, "🔒 بازار بسته است", []string{"ا🔄 درحال بروزرسانی قیمت ها\nا🔒 بازار بسته است\nا📆"}},
+		{"ending", 23 * time.Second, true, "", []string{"<blockquote expandable>ا📆", "@prices</blockquote>"}},
+	}
+
+	for _, tt := range tests {
+		s := testSnapshot()
+		s.NextUpdate, s.Ending, s.Notice = tt.next, tt.ending, tt.notice
+		text := TelegramText(s)
+		for _, expected := range tt.expected {
+			if !strings.Contains(text, expected) {
+				t.Errorf("%s: expected message to contain %q, got %s", tt.name, expected, text)
+			}
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	s := testSnapshot()
+	tests := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{"discord", DiscordText(s), []string{"**23**", "(0.50%🟢) **82,150** تومان", "[🗝 پروکسی](https://t.me/proxy?server=a&port=1)"}},
+		{"slack", SlackText(s), []string{"*23*", "*82,150*", "<https://t.me/proxy?server=a&port=1|🗝 پروکسی>"}},
+		{"matrix html", MatrixHTML(s), []string{"<b>82,150</b>", `href="https://t.me/proxy?server=a&amp;port=1"`, "<br>\n"}},
+		{"matrix text", MatrixText(s), []string{"82,150 تومان", "🗝 پروکسی: https://t.me/proxy"}},
+	}
+
+	for _, tt := range tests {
+		for _, expected := range tt.expected {
+			if !strings.Contains(tt.text, expected) {
+				t.Errorf("%s: expected message to contain %q, got %s", tt.name, expected, tt.text)
+			}
+		}
+		if strings.Contains(tt.text, "<blockquote") || strings.HasPrefix(tt.text, "ا") {
+			t.Errorf("%s: expected no Telegram markup, got %s", tt.name, tt.text)
+		}
+	}
+}
+
+func TestDiscord(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		var body struct {
+			Embeds []struct {
+				Description string `json:"description"`
+			} `json:"embeds"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body.Embeds) != 1 || !strings.Contains(body.Embeds[0].Description, "**82,150**") {
+			t.Errorf("Unexpected embeds %+v", body.Embeds)
+		}
+		if r.Method == http.MethodPatch && strings.HasSuffix(r.URL.Path, "/gone") {
+			http.Error(w, `{"message": "Unknown Message", "code": 10008}`, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"id": "1234567890123456789"}`))
+	}))
+	defer server.Close()
+	httpClient = server.Client()
+
+	store, _ := state.OpenFile("")
+	d, err := NewDiscord(server.URL+"/api/webhooks/42/secret-token", store)
+	if err != nil {
+		t.Fatalf("NewDiscord failed: %v", err)
+	}
+	if d.HasMessage() {
+		t.Fatal("Expected no message before the first send")
+	}
+	if err := d.SendMessage(context.Background(), testSnapshot()); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if err := d.UpdateMessage(context.Background(), testSnapshot()); err != nil {
+		t.Fatalf("UpdateMessage failed: %v", err)
+	}
+
+	expected := []string{
+		"POST /api/webhooks/42/secret-token?wait=true",
+		"PATCH /api/webhooks/42/secret-token/messages/1234567890123456789",
+	}
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected requests %v, got %v", expected, requests)
+	}
+
+	// the message ID survives a restart
+	reopened, _ := NewDiscord(server.URL+"/api/webhooks/42/secret-token", store)
+	if !reopened.HasMessage() {
+		t.Error("Expected the message ID to be restored from the store")
+	}
+
+	reopened.last.last.Ref = "gone"
+	err = reopened.UpdateMessage(context.Background(), testSnapshot())
+	if err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Expected an error without the webhook token, got %v", err)
+	}
+}
+
+func TestSlack(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer xoxb-token" {
+			t.Errorf("Expected the bot token, got %q", r.Header.Get("Authorization"))
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+
+		switch r.URL.Path {
+		case "/chat.postMessage":
+			w.Write([]byte(`{"ok": true, "channel": "C0123", "ts": "1715000000.000100"}`))
+		case "/chat.update":
+			if body["ts"] != "1715000000.000100" || body["channel"] != "C0123" {
+				t.Errorf("Unexpected update %v", body)
+			}
+			w.Write([]byte(`{"ok": false, "error": "message_not_found"}`))
+		}
+	}))
+	defer server.Close()
+	httpClient = server.Client()
+	slackURL = server.URL + "/"
+
+	store, _ := state.OpenFile("")
+	s, err := NewSlack("xoxb-token", "C0123", store)
+	if err != nil {
+		t.Fatalf("NewSlack failed: %v", err)
+	}
+	if err := s.SendMessage(context.Background(), testSnapshot()); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	err = s.UpdateMessage(context.Background(), testSnapshot())
+	if err == nil || !strings.Contains(err.Error(), "message_not_found") {
+		t.Errorf("Expected the Slack error in the body to fail the update, got %v", err)
+	}
+	if len(methods) != 2 {
+		t.Errorf("Expected 2 calls, got %v", methods)
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	var contents []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || !strings.HasPrefix(r.URL.EscapedPath(), "/_matrix/client/v3/rooms/%21room:example.org/send/m.room.message/") {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.EscapedPath())
+		}
+		var content map[string]any
+		json.NewDecoder(r.Body).Decode(&content)
+		contents = append(contents, content)
+		w.Write([]byte(`{"event_id": "$event"}`))
+	}))
+	defer server.Close()
+	httpClient = server.Client()
+
+	store, _ := state.OpenFile("")
+	m, err := NewMatrix(server.URL, "token", "!room:example.org", store)
+	if err != nil {
+		t.Fatalf("NewMatrix failed: %v", err)
+	}
+	if err := m.SendMessage(context.Background(), testSnapshot()); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if err := m.UpdateMessage(context.Background(), testSnapshot()); err != nil {
+		t.Fatalf("UpdateMessage failed: %v", err)
+	}
+
+	if len(contents) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(contents))
+	}
+	if contents[0]["msgtype"] != "m.notice" || contents[0]["format"] != "org.matrix.custom.html" {
+		t.Errorf("Unexpected message %v", contents[0])
+	}
+	relation, _ := contents[1]["m.relates_to"].(map[string]any)
+	if relation["rel_type"] != "m.replace" || relation["event_id"] != "$event" {
+		t.Errorf("Expected an m.replace of $event, got %v", contents[1]["m.relates_to"])
+	}
+	if body, _ := contents[1]["body"].(string); !strings.HasPrefix(body, "* ") {
+		t.Errorf("Expected the edit fallback to start with \"* \", got %q", body)
+	}
+	if _, ok := contents[1]["m.new_content"].(map[string]any); !ok {
+		t.Error("Expected the edit to carry m.new_content")
+	}
+}
+
+func TestTelegram_WithoutEdits(t *testing.T) {
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Text string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		texts = append(texts, payload.Text)
+		w.Write([]byte(`{"ok": true, "result": {"message_id": 9}}`))
+	}))
+	defer server.Close()
+
+	platform := telegram.Eitaa
+	platform.BaseURL = server.URL + "/api/%s%s"
+	store, _ := state.OpenFile("")
+	p := NewTelegram(telegram.NewTelegramOn(platform, "token", "prices", store))
+
+	if err := p.SendMessage(context.Background(), testSnapshot()); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if err := p.UpdateMessage(context.Background(), testSnapshot()); err != nil {
+		t.Fatalf("Expected edits to be skipped, got %v", err)
+	}
+
+	if p.Name() != "eitaa" || len(texts) != 1 {
+		t.Fatalf("Expected one eitaa post, got %s with %d", p.Name(), len(texts))
+	}
+	if strings.Contains(texts[0], "⏰") || strings.Contains(texts[0], "پروکسی") || strings.Contains(texts[0], "<") {
+		t.Errorf("Expected a plain final post without countdown or proxy, got %s", texts[0])
+	}
+}
@@ -0,0 +1,99 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/onionj/pricebot/state"
+)
+
+// slackURL is the Slack Web API, a variable so tests can replace it
+var slackURL = "https://slack.com/api/"
+
+// Slack keeps the live message in a channel with chat.postMessage and
+// chat.update, using a bot token with the chat:write scope.
+type Slack struct {
+	token   string
+	channel string
+	last    *tracker
+}
+
+// NewSlack publishes to channel, which must be a channel ID like C0123ABC
+// since chat.update does not accept names.
+func NewSlack(token, channel string, store state.Store) (*Slack, error) {
+	if token == "" || channel == "" {
+		return nil, fmt.Errorf("slack needs a bot token and a channel ID")
+	}
+	last, err := newTracker(store, "slack:"+channel)
+	if err != nil {
+		return nil, err
+	}
+	return &Slack{token: token, channel: channel, last: last}, nil
+}
+
+func (s *Slack) Name() string { return "slack" }
+
+type slackResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+	TS    string `json:"ts"`
+}
+
+func (s *Slack) SendMessage(ctx context.Context, snap Snapshot) error {
+	ts, err := s.call(ctx, "send message", "chat.postMessage", map[string]any{
+		"channel":      s.channel,
+		"text":         SlackText(snap),
+		"unfurl_links": false,
+	})
+	if err != nil {
+		return err
+	}
+	return s.last.set(ts)
+}
+
+func (s *Slack) UpdateMessage(ctx context.Context, snap Snapshot) error {
+	_, err := s.call(ctx, "update message", "chat.update", map[string]any{
+		"channel": s.channel,
+		"ts":      s.last.last.Ref,
+		"text":    SlackText(snap),
+	})
+	return err
+}
+
+func (s *Slack) HasMessage() bool {
+	return s.last.last.Ref != ""
+}
+
+// call runs a Web API method, which reports failures in the body of a 200
+// response, and returns the message timestamp.
+func (s *Slack) call(ctx context.Context, action, method string, payload map[string]any) (string, error) {
+	var response slackResponse
+	err := request{
+		platform: "slack",
+		action:   action,
+		method:   http.MethodPost,
+		url:      slackURL + method,
+		token:    s.token,
+		payload:  payload,
+	}.do(ctx, &response)
+	if err != nil {
+		return "", err
+	}
+	if !response.OK {
+		return "", &Error{Platform: "slack", Action: action, Status: http.StatusOK, Detail: response.Error}
+	}
+	return response.TS, nil
+}
+
+// SlackText renders s in Slack mrkdwn.
+func SlackText(s Snapshot) string {
+	return render(s, markup{
+		bold:   func(text string) string { return "*" + text + "*" },
+		link:   func(text, url string) string { return fmt.Sprintf("<%s|%s>", url, text) },
+		escape: slackEscape.Replace,
+	})
+}
+
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
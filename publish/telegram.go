@@ -0,0 +1,87 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/onionj/pricebot/report"
+	"github.com/onionj/pricebot/telegram"
+)
+
+// Telegram keeps the live message in a channel on Telegram or a platform
+// with a Telegram-like bot API, such as Bale.
+type Telegram struct {
+	tel *telegram.Telegram
+}
+
+func NewTelegram(tel *telegram.Telegram) *Telegram {
+	return &Telegram{tel: tel}
+}
+
+func (t *Telegram) Name() string { return t.tel.Platform().Name }
+
+// SendMessage posts s. Where messages cannot be edited it posts the final
+// form, since a countdown would never move.
+func (t *Telegram) SendMessage(ctx context.Context, s Snapshot) error {
+	if !t.tel.Platform().Features.Edit {
+		s.Ending = true
+	}
+	return t.tel.SendMessage(ctx, TelegramText(t.adapt(s)))
+}
+
+// UpdateMessage edits the last message, doing nothing where messages cannot
+// be edited; a fresh post replaces it there instead.
+func (t *Telegram) UpdateMessage(ctx context.Context, s Snapshot) error {
+	if !t.tel.Platform().Features.Edit {
+		return nil
+	}
+	return t.tel.UpdateMessage(ctx, TelegramText(t.adapt(s)), t.tel.LastMessageId)
+}
+
+func (t *Telegram) HasMessage() bool {
+	return t.tel.LastMessageId > 0
+}
+
+// adapt drops the proxy link, which only helps Telegram readers, elsewhere.
+func (t *Telegram) adapt(s Snapshot) Snapshot {
+	if t.tel.Platform().Name != "telegram" {
+		s.ProxyLink = ""
+	}
+	return s
+}
+
+// TelegramText renders s as the Telegram HTML live message.
+func TelegramText(s Snapshot) string {
+	priceData := s.Price.String()
+	var changes []string
+	for _, c := range s.Changes {
+		if line := report.ChangeLine(c); line != "" {
+			changes = append(changes, line)
+		}
+	}
+	if len(changes) > 0 {
+		priceData += "\n\n" + strings.Join(changes, "\n")
+	}
+	if s.Notice != "" {
+		priceData = "ا" + s.Notice + "\n" + priceData
+	}
+
+	proxy := ""
+	if s.ProxyLink != "" {
+		proxy = fmt.Sprintf(`<a href="%s">ا🗝 پروکسی</a>`, s.ProxyLink)
+	}
+
+	if s.Ending {
+		return fmt.Sprintf("<blockquote expandable>%s\n\n%s</blockquote>", priceData, s.Channel)
+	}
+
+	nextUpdateSecond := int64(s.NextUpdate.Seconds())
+	if nextUpdateSecond >= 7 {
+		return fmt.Sprintf("ا⏰ تا بروزرسانی بعدی قیمت ها: <b>%02d</b> ثانیه\n%s\n\n%s\n%s", nextUpdateSecond, priceData, proxy, s.Channel)
+	} else if nextUpdateSecond >= 3 {
+		return fmt.Sprintf("ا🔄 درحال بروزرسانی قیمت ها \n%s\n\n%s\n%s", priceData, proxy, s.Channel)
+	} else {
+		return fmt.Sprintf("ا🔄 درحال بروزرسانی قیمت ها\n%s\n\n%s\n%s", priceData, proxy, s.Channel)
+	}
+}
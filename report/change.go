@@ -0,0 +1,208 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/utils"
+)
+
+// DefaultWindows is the window set used when none is configured.
+const DefaultWindows = "1h,24h,7d,30d,ytd"
+
+// Window is a lookback period a change is measured over: either a fixed
+// duration or a span since the start of the current Jalali week, month or year.
+type Window struct {
+	Key   string // as written in the configuration, like "24h" or "ytd"
+	Title string // Persian label used in messages
+
+	duration time.Duration
+	start    func(utils.JDate) utils.JDate
+}
+
+// Start returns when the window that ends at now begins.
+func (w Window) Start(now time.Time) time.Time {
+	if w.start != nil {
+		return w.start(utils.ToJalali(now)).Time()
+	}
+	return now.Add(-w.duration)
+}
+
+// calendarWindows are the windows anchored to the Jalali calendar.
+var calendarWindows = map[string]Window{
+	"wtd": {Key: "wtd", Title: "این هفته", start: utils.JDate.StartOfWeek},
+	"mtd": {Key: "mtd", Title: "این ماه", start: utils.JDate.StartOfMonth},
+	"ytd": {Key: "ytd", Title: "از نوروز", start: utils.JDate.StartOfYear},
+}
+
+// ParseWindow parses one window: a number followed by m, h, d or w, or one of
+// wtd, mtd and ytd for the time since the start of the Jalali week, month or
+// year (Nowruz).
+func ParseWindow(s string) (Window, error) {
+	key := strings.ToLower(strings.TrimSpace(s))
+	if w, ok := calendarWindows[key]; ok {
+		return w, nil
+	}
+	if len(key) < 2 {
+		return Window{}, fmt.Errorf("invalid window %q", s)
+	}
+
+	n, err := strconv.Atoi(key[:len(key)-1])
+	if err != nil || n <= 0 {
+		return Window{}, fmt.Errorf("invalid window %q", s)
+	}
+	var unit time.Duration
+	var title string
+	switch key[len(key)-1] {
+	case 'm':
+		unit, title = time.Minute, "دقیقه"
+	case 'h':
+		unit, title = time.Hour, "ساعت"
+	case 'd':
+		unit, title = 24*time.Hour, "روز"
+	case 'w':
+		unit, title = 7*24*time.Hour, "هفته"
+	default:
+		return Window{}, fmt.Errorf("invalid window %q: unknown unit", s)
+	}
+	return Window{Key: key, Title: fmt.Sprintf("%d %s", n, title), duration: time.Duration(n) * unit}, nil
+}
+
+// ParseWindows parses a comma separated window list such as DefaultWindows.
+func ParseWindows(spec string) ([]Window, error) {
+	var windows []Window
+	for _, field := range strings.Split(spec, ",") {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+		w, err := ParseWindow(field)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("no windows in %q", spec)
+	}
+	return windows, nil
+}
+
+// Change is an asset's movement over one window, from the value in effect at
+// the window start to the latest value.
+type Change struct {
+	Window  string        `json:"window"`
+	Title   string        `json:"title"`
+	From    time.Time     `json:"from"`
+	JFrom   string        `json:"jalali_from"`
+	Base    price.Decimal `json:"base"`
+	Percent float64       `json:"percent"`
+}
+
+// AssetChanges holds the changes of one asset over every window with history.
+type AssetChanges struct {
+	Asset   price.Asset   `json:"-"`
+	Key     string        `json:"asset"`
+	Name    string        `json:"name"`
+	Unit    price.Unit    `json:"unit"`
+	Current price.Decimal `json:"current"`
+	Changes []Change      `json:"changes"`
+}
+
+// Changes measures asset over windows ending at now. Windows reaching back
+// before the first recorded value are left out; ok is false when the asset
+// has no value at now.
+func Changes(store *history.Store, asset price.Asset, now time.Time, windows []Window) (AssetChanges, bool) {
+	current, ok := store.At(asset.Key, now)
+	if !ok {
+		return AssetChanges{}, false
+	}
+
+	result := AssetChanges{Asset: asset, Key: asset.Key, Name: asset.Name, Unit: asset.Unit, Current: current.Value}
+	for _, w := range windows {
+		from := w.Start(now)
+		base, ok := store.At(asset.Key, from)
+		if !ok {
+			continue
+		}
+		result.Changes = append(result.Changes, Change{
+			Window:  w.Key,
+			Title:   w.Title,
+			From:    from,
+			JFrom:   utils.NewJTime(from).String(),
+			Base:    base.Value,
+			Percent: current.Value.PercentChange(base.Value),
+		})
+	}
+	return result, true
+}
+
+// AllChanges returns the changes of every asset with history, in
+// price.Assets order.
+func AllChanges(store *history.Store, now time.Time, windows []Window) []AssetChanges {
+	var result []AssetChanges
+	for _, asset := range price.Assets {
+		if changes, ok := Changes(store, asset, now, windows); ok {
+			result = append(result, changes)
+		}
+	}
+	return result
+}
+
+// ChangeLine renders the changes of one asset as a single message line, like
+// "دلار امریکا: 24 ساعت (0.50%🟢) | 7 روز (3.20%🟢)". It is empty when no
+// window has history yet.
+func ChangeLine(c AssetChanges) string {
+	if text := ChangeText(c); text != "" {
+		return "ا📈 " + text
+	}
+	return ""
+}
+
+// ChangeText is ChangeLine without its Telegram line prefix, for other outputs.
+func ChangeText(c AssetChanges) string {
+	if len(c.Changes) == 0 {
+		return ""
+	}
+	parts := make([]string, len(c.Changes))
+	for i, change := range c.Changes {
+		parts[i] = fmt.Sprintf("%s %s", change.Title, FormatChange(change.Percent))
+	}
+	return fmt.Sprintf("%s: %s", c.Asset.Name, strings.Join(parts, " | "))
+}
+
+// ChangesHandler serves the changes of every asset over windows as JSON.
+// The asset query parameter narrows the result to one asset.
+func ChangesHandler(store *history.Store, windows []Window) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		changes := AllChanges(store, now, windows)
+
+		if key := r.URL.Query().Get("asset"); key != "" {
+			asset, ok := price.FindAsset(key)
+			if !ok {
+				http.Error(w, fmt.Sprintf("unknown asset %q", key), http.StatusNotFound)
+				return
+			}
+			changes = nil
+			if c, ok := Changes(store, asset, now, windows); ok {
+				changes = append(changes, c)
+			}
+		}
+		if changes == nil {
+			changes = []AssetChanges{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Time   time.Time      `json:"time"`
+			JTime  string         `json:"jalali_time"`
+			Assets []AssetChanges `json:"assets"`
+		}{now, utils.NewJTime(now).String(), changes})
+	})
+}
@@ -0,0 +1,148 @@
+package report
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/utils"
+)
+
+func TestParseWindows(t *testing.T) {
+	now := time.Date(2025, 5, 6, 12, 0, 0, 0, utils.Tehran)
+
+	windows, err := ParseWindows(DefaultWindows + ", 2w,mtd")
+	if err != nil {
+		t.Fatalf("ParseWindows failed: %v", err)
+	}
+
+	expected := []struct {
+		key   string
+		title string
+		start time.Time
+	}{
+		{"1h", "1 ساعت", now.Add(-time.Hour)},
+		{"24h", "24 ساعت", now.Add(-24 * time.Hour)},
+		{"7d", "7 روز", now.AddDate(0, 0, -7)},
+		{"30d", "30 روز", now.AddDate(0, 0, -30)},
+		{"ytd", "از نوروز", time.Date(2025, 3, 21, 0, 0, 0, 0, utils.Tehran)},
+		{"2w", "2 هفته", now.AddDate(0, 0, -14)},
+		{"mtd", "این ماه", time.Date(2025, 4, 21, 0, 0, 0, 0, utils.Tehran)},
+	}
+	if len(windows) != len(expected) {
+		t.Fatalf("Expected %d windows, got %d", len(expected), len(windows))
+	}
+	for i, e := range expected {
+		w := windows[i]
+		if w.Key != e.key || w.Title != e.title || !w.Start(now).Equal(e.start) {
+			t.Errorf("Window %d = %s %q %v; want %s %q %v", i, w.Key, w.Title, w.Start(now), e.key, e.title, e.start)
+		}
+	}
+
+	for _, spec := range []string{"", "0d", "5x", "d", "-1h", "1h,week"} {
+		if _, err := ParseWindows(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestChanges(t *testing.T) {
+	store, _ := history.Open("")
+	now := time.Date(2025, 5, 6, 12, 0, 0, 0, utils.Tehran)
+	nowruz := time.Date(2025, 3, 21, 0, 0, 0, 0, utils.Tehran)
+
+	store.Record(nowruz.Add(-time.Hour), decimals(map[string]float64{"usd": 80000}))
+	store.Record(now.AddDate(0, 0, -7), decimals(map[string]float64{"usd": 90000, "eur": 100000}))
+	store.Record(now.Add(-2*time.Hour), decimals(map[string]float64{"usd": 92000}))
+	store.Record(now.Add(-time.Minute), decimals(map[string]float64{"usd": 92920}))
+
+	windows, _ := ParseWindows("1h,7d,30d,ytd")
+	usd, _ := price.FindAsset("usd")
+	changes, ok := Changes(store, usd, now, windows)
+	if !ok {
+		t.Fatal("Expected changes for usd")
+	}
+	if changes.Current.String() != "92920" {
+		t.Errorf("Current = %s; want 92920", changes.Current)
+	}
+
+	expected := map[string]float64{"1h": 1, "7d": 3.2444, "30d": 16.15, "ytd": 16.15}
+	if len(changes.Changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %v", len(expected), changes.Changes)
+	}
+	for _, c := range changes.Changes {
+		if diff := c.Percent - expected[c.Window]; diff > 0.0001 || diff < -0.0001 {
+			t.Errorf("%s change = %v; want %v", c.Window, c.Percent, expected[c.Window])
+		}
+	}
+
+	// eur was first recorded a week ago, so longer windows are left out
+	eur, _ := price.FindAsset("eur")
+	changes, _ = Changes(store, eur, now, windows)
+	if len(changes.Changes) != 2 {
+		t.Errorf("Expected only the 1h and 7d eur changes, got %v", changes.Changes)
+	}
+
+	line := ChangeLine(changes)
+	if line != "ا📈 یورو اروپا: 1 ساعت ⬅️ | 7 روز ⬅️" {
+		t.Errorf("Unexpected change line %q", line)
+	}
+
+	gbp, _ := price.FindAsset("gbp")
+	if _, ok := Changes(store, gbp, now, windows); ok {
+		t.Error("Expected no changes for an asset without history")
+	}
+}
+
+func TestChangesHandler(t *testing.T) {
+	store, _ := history.Open("")
+	now := time.Now()
+	store.Record(now.Add(-2*time.Hour), decimals(map[string]float64{"usd": 80000, "eur": 90000}))
+	store.Record(now.Add(-time.Minute), decimals(map[string]float64{"usd": 84000}))
+
+	windows, _ := ParseWindows("1h")
+	handler := ChangesHandler(store, windows)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/changes?asset=usd", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+
+	var body struct {
+		Assets []struct {
+			Asset   string  `json:"asset"`
+			Current float64 `json:"current"`
+			Changes []struct {
+				Window  string  `json:"window"`
+				Percent float64 `json:"percent"`
+			} `json:"changes"`
+		} `json:"assets"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(body.Assets) != 1 || body.Assets[0].Asset != "usd" || body.Assets[0].Current != 84000 {
+		t.Fatalf("Unexpected assets: %s", rec.Body)
+	}
+	if c := body.Assets[0].Changes; len(c) != 1 || c[0].Window != "1h" || c[0].Percent != 5 {
+		t.Errorf("Unexpected changes: %s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/changes", nil))
+	if !strings.Contains(rec.Body.String(), `"asset":"eur"`) {
+		t.Errorf("Expected every asset, got %s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/changes?asset=xyz", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown asset, got %d", rec.Code)
+	}
+}
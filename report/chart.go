@@ -0,0 +1,145 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strings"
+)
+
+var (
+	chartBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	chartText       = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	chartAxis       = color.RGBA{0x99, 0x99, 0x99, 0xff}
+	chartGain       = color.RGBA{0x2e, 0xa0, 0x43, 0xff}
+	chartLoss       = color.RGBA{0xd7, 0x3a, 0x49, 0xff}
+)
+
+const (
+	chartWidth  = 800
+	chartMargin = 16
+	chartRow    = 28
+	labelWidth  = 120
+	valueWidth  = 100
+	fontScale   = 2
+)
+
+// Chart draws a horizontal bar chart of each asset's percent change over p
+// and returns it as a PNG image.
+func Chart(p Period, performances []Performance) ([]byte, error) {
+	height := chartMargin*2 + chartRow*(len(performances)+1)
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{chartBackground}, image.Point{}, draw.Src)
+
+	drawText(img, chartMargin, chartMargin, fmt.Sprintf("%s - %s", p.From, p.LastDay()), chartText)
+
+	maxChange := 0.0
+	for _, perf := range performances {
+		maxChange = math.Max(maxChange, math.Abs(perf.Change()))
+	}
+	if maxChange == 0 {
+		maxChange = 1
+	}
+
+	barLeft := chartMargin + labelWidth
+	barRight := chartWidth - chartMargin - valueWidth
+	zero := (barLeft + barRight) / 2
+	halfWidth := float64(barRight-barLeft) / 2
+
+	top := chartMargin + chartRow
+	fill(img, image.Rect(zero, top, zero+1, top+chartRow*len(performances)), chartAxis)
+
+	for i, perf := range performances {
+		y := top + i*chartRow
+		change := perf.Change()
+		drawText(img, chartMargin, y+7, strings.ToUpper(perf.Asset.Key), chartText)
+
+		length := int(math.Round(math.Abs(change) / maxChange * halfWidth))
+		bar := image.Rect(zero, y+4, zero+length, y+chartRow-4)
+		barColor := chartGain
+		if change < 0 {
+			bar = image.Rect(zero-length, y+4, zero, y+chartRow-4)
+			barColor = chartLoss
+		}
+		fill(img, bar, barColor)
+
+		drawText(img, barRight+8, y+7, fmt.Sprintf("%+.2f%%", change), chartText)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func fill(img *image.RGBA, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, &image.Uniform{c}, image.Point{}, draw.Src)
+}
+
+// drawText writes s with the built-in 5x7 font; unknown characters are skipped.
+func drawText(img *image.RGBA, x, y int, s string, c color.Color) {
+	for _, r := range s {
+		glyph := font[r]
+		for row, bits := range glyph {
+			for col := 0; col < 5; col++ {
+				if bits&(1<<(4-col)) != 0 {
+					fill(img, image.Rect(x+col*fontScale, y+row*fontScale, x+(col+1)*fontScale, y+(row+1)*fontScale), c)
+				}
+			}
+		}
+		x += 6 * fontScale
+	}
+}
+
+// font is a 5x7 bitmap font; each row's low five bits are pixels, left to right.
+var font = map[rune][7]uint8{
+	'0': {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1': {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3': {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4': {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5': {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6': {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9': {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	'A': {0x0E, 0x11, 0x11, 0x11, 0x1F, 0x11, 0x11},
+	'B': {0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E},
+	'C': {0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E},
+	'D': {0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C},
+	'E': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F},
+	'F': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10},
+	'G': {0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F},
+	'H': {0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'I': {0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'J': {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C},
+	'K': {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L': {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F},
+	'M': {0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N': {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O': {0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'P': {0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10},
+	'Q': {0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D},
+	'R': {0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11},
+	'S': {0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E},
+	'T': {0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U': {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'V': {0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04},
+	'W': {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A},
+	'X': {0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11},
+	'Y': {0x11, 0x11, 0x11, 0x0A, 0x04, 0x04, 0x04},
+	'Z': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F},
+	'+': {0x00, 0x04, 0x04, 0x1F, 0x04, 0x04, 0x00},
+	'-': {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'.': {0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C},
+	'%': {0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03},
+	'/': {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	'_': {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1F},
+	':': {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00},
+	' ': {},
+}
@@ -0,0 +1,108 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/utils"
+)
+
+// how many gainers and losers the summary lists
+const moversCount = 3
+
+// AssetSummary is one asset's movement over a period.
+type AssetSummary struct {
+	Asset price.Asset
+	history.OHLC
+}
+
+// Summarize returns the OHLC of every asset with history in [from, to) of the
+// given Jalali days, in price.Assets order.
+func Summarize(store *history.Store, from, to utils.JDate) []AssetSummary {
+	var result []AssetSummary
+	for _, asset := range price.Assets {
+		ohlc, ok := store.OHLC(asset.Key, from.Time(), to.Time())
+		if ok {
+			result = append(result, AssetSummary{Asset: asset, OHLC: ohlc})
+		}
+	}
+	return result
+}
+
+// Daily renders the end-of-day summary for date as a Telegram HTML message.
+// ok is false when there is no history for that day.
+func Daily(store *history.Store, date utils.JDate) (string, bool) {
+	summaries := Summarize(store, date, date.AddDays(1))
+	if len(summaries) == 0 {
+		return "", false
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "ا📊 خلاصه بازار <b>%s</b>\n", date.Format("EEEE d MMMM yyyy"))
+
+	group := summaries[0].Asset.Group
+	for _, s := range summaries {
+		if s.Asset.Group != group {
+			group = s.Asset.Group
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "\nا%s %s %s\nا   باز %s | بیشینه %s | کمینه %s | پایانی <b>%s</b> %s\n",
+			s.Asset.Emoji, s.Asset.Name, FormatChange(s.Change()),
+			s.Open.Format(2), s.High.Format(2), s.Low.Format(2),
+			s.Close.Format(2), s.Asset.Unit.Label())
+	}
+
+	gainers, losers := Movers(summaries, moversCount)
+	if len(gainers) > 0 {
+		b.WriteString("\nا🚀 بیشترین رشد: " + joinMovers(gainers))
+	}
+	if len(losers) > 0 {
+		b.WriteString("\nا📉 بیشترین افت: " + joinMovers(losers))
+	}
+
+	return b.String(), true
+}
+
+// Movers returns up to n assets with the largest gains and the largest losses.
+func Movers(summaries []AssetSummary, n int) (gainers, losers []AssetSummary) {
+	sorted := append([]AssetSummary(nil), summaries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Change() > sorted[j].Change() })
+
+	for _, s := range sorted {
+		if len(gainers) == n || s.Change() <= 0 {
+			break
+		}
+		gainers = append(gainers, s)
+	}
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if len(losers) == n || sorted[i].Change() >= 0 {
+			break
+		}
+		losers = append(losers, sorted[i])
+	}
+	return gainers, losers
+}
+
+func joinMovers(summaries []AssetSummary) string {
+	parts := make([]string, len(summaries))
+	for i, s := range summaries {
+		parts[i] = fmt.Sprintf("%s %+.2f%%", s.Asset.Name, s.Change())
+	}
+	return strings.Join(parts, "، ")
+}
+
+// FormatChange renders a percent change the way the live message does.
+func FormatChange(change float64) string {
+	switch {
+	case math.Round(change*100) > 0:
+		return fmt.Sprintf("(%.2f%%🟢)", change)
+	case math.Round(change*100) < 0:
+		return fmt.Sprintf("(%.2f%%🔴)", math.Abs(change))
+	default:
+		return "⬅️"
+	}
+}
@@ -0,0 +1,71 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/utils"
+)
+
+// decimals converts test values to the map Record takes.
+func decimals(values map[string]float64) map[string]price.Decimal {
+	result := make(map[string]price.Decimal, len(values))
+	for key, value := range values {
+		result[key] = price.DecimalFromFloat(value)
+	}
+	return result
+}
+
+func TestDaily(t *testing.T) {
+	store, _ := history.Open("")
+	date := utils.JDate{Year: 1404, Month: 2, Day: 16}
+	day := date.Time()
+
+	store.Record(day.Add(-time.Hour), decimals(map[string]float64{"usd": 80000, "eur": 90000, "btc": 60000}))
+	store.Record(day.Add(10*time.Hour), decimals(map[string]float64{"usd": 82000, "eur": 88000, "btc": 60000}))
+	store.Record(day.Add(12*time.Hour), decimals(map[string]float64{"usd": 81600, "eur": 89100, "btc": 60000}))
+
+	message, ok := Daily(store, date)
+	if !ok {
+		t.Fatal("Expected a daily summary")
+	}
+
+	expectedStrings := []string{
+		"خلاصه بازار <b>سه‌شنبه 16 اردیبهشت 1404</b>",
+		"دلار امریکا (2.00%🟢)",
+		"باز 80,000 | بیشینه 82,000 | کمینه 80,000 | پایانی <b>81,600</b> تومان",
+		"یورو اروپا (1.00%🔴)",
+		"بیتکوین ⬅️",
+		"بیشترین رشد: دلار امریکا +2.00%",
+		"بیشترین افت: یورو اروپا -1.00%",
+	}
+	for _, expected := range expectedStrings {
+		if !strings.Contains(message, expected) {
+			t.Errorf("Expected summary to contain '%s', got %s", expected, message)
+		}
+	}
+
+	if _, ok := Daily(store, date.AddDays(-2)); ok {
+		t.Error("Expected no summary for a day without history")
+	}
+}
+
+func TestMovers(t *testing.T) {
+	store, _ := history.Open("")
+	date := utils.JDate{Year: 1404, Month: 2, Day: 16}
+	day := date.Time()
+
+	store.Record(day.Add(-time.Hour), decimals(map[string]float64{"usd": 100, "eur": 100, "gbp": 100, "cad": 100, "aud": 100}))
+	store.Record(day.Add(time.Hour), decimals(map[string]float64{"usd": 105, "eur": 101, "gbp": 110, "cad": 90, "aud": 100}))
+
+	gainers, losers := Movers(Summarize(store, date, date.AddDays(1)), 2)
+	if len(gainers) != 2 || gainers[0].Asset.Key != "gbp" || gainers[1].Asset.Key != "usd" {
+		t.Errorf("Unexpected gainers: %v", gainers)
+	}
+	if len(losers) != 1 || losers[0].Asset.Key != "cad" {
+		t.Errorf("Unexpected losers: %v", losers)
+	}
+}
@@ -0,0 +1,146 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/utils"
+)
+
+// Period is a span of whole Jalali days, From inclusive and To exclusive.
+type Period struct {
+	Title string
+	From  utils.JDate
+	To    utils.JDate
+}
+
+// Week returns the Saturday to Friday week containing d.
+func Week(d utils.JDate) Period {
+	from := d.StartOfWeek()
+	to := from.AddDays(7)
+	return Period{
+		Title: fmt.Sprintf("گزارش هفتگی %s تا %s", from, to.AddDays(-1)),
+		From:  from,
+		To:    to,
+	}
+}
+
+// Month returns the Jalali month containing d.
+func Month(d utils.JDate) Period {
+	from := d.StartOfMonth()
+	return Period{
+		Title: fmt.Sprintf("گزارش ماهانه %s %d", from.MonthName(), from.Year),
+		From:  from,
+		To:    from.AddMonths(1),
+	}
+}
+
+// LastDay returns the final day of the period.
+func (p Period) LastDay() utils.JDate {
+	return p.To.AddDays(-1)
+}
+
+// Performance is an asset's movement over a period.
+type Performance struct {
+	AssetSummary
+	// Volatility is the standard deviation of daily percent changes
+	Volatility float64
+}
+
+var groupTitles = []struct {
+	group price.Group
+	title string
+}{
+	{price.GroupCurrency, "ا💵 ارزها"},
+	{price.GroupCoin, "ا🪙 سکه"},
+	{price.GroupGold, "ا💰 طلا"},
+	{price.GroupCrypto, "ا👑 رمزارزها"},
+}
+
+// Performances computes the movement and volatility of every asset over p.
+func Performances(store *history.Store, p Period) []Performance {
+	summaries := Summarize(store, p.From, p.To)
+	result := make([]Performance, len(summaries))
+	for i, s := range summaries {
+		result[i] = Performance{AssetSummary: s, Volatility: volatility(store, s, p)}
+	}
+	return result
+}
+
+// volatility is the standard deviation of the asset's day-over-day close
+// changes in p. Days without any recorded change, like holidays, are skipped.
+func volatility(store *history.Store, s AssetSummary, p Period) float64 {
+	prev := s.PrevClose
+	var changes []float64
+	for day := p.From; day.Before(p.To); day = day.AddDays(1) {
+		next := day.AddDays(1).Time()
+		if len(store.Range(s.Asset.Key, day.Time(), next)) == 0 {
+			continue
+		}
+		point, _ := store.At(s.Asset.Key, next.Add(-1))
+		if !prev.IsZero() {
+			changes = append(changes, point.Value.PercentChange(prev))
+		}
+		prev = point.Value
+	}
+	if len(changes) < 2 {
+		return 0
+	}
+
+	var mean float64
+	for _, c := range changes {
+		mean += c
+	}
+	mean /= float64(len(changes))
+
+	var variance float64
+	for _, c := range changes {
+		variance += (c - mean) * (c - mean)
+	}
+	return math.Sqrt(variance / float64(len(changes)-1))
+}
+
+// Periodic renders the weekly or monthly report for p as a Telegram HTML
+// message. ok is false when there is no history in p.
+func Periodic(store *history.Store, p Period) (string, []Performance, bool) {
+	performances := Performances(store, p)
+	if len(performances) == 0 {
+		return "", nil, false
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "ا📅 <b>%s</b>\n", p.Title)
+
+	for _, g := range groupTitles {
+		var rows []string
+		for _, perf := range performances {
+			if perf.Asset.Group != g.group {
+				continue
+			}
+			rows = append(rows, fmt.Sprintf("ا%s %s %s\nا   بیشینه %s | کمینه %s | پایانی <b>%s</b> %s | نوسان %.2f%%",
+				perf.Asset.Emoji, perf.Asset.Name, FormatChange(perf.Change()),
+				perf.High.Format(2), perf.Low.Format(2), perf.Close.Format(2),
+				perf.Asset.Unit.Label(), perf.Volatility))
+		}
+		if len(rows) > 0 {
+			fmt.Fprintf(&b, "\n<b>%s</b>\n%s\n", g.title, strings.Join(rows, "\n"))
+		}
+	}
+
+	best, worst := performances[0], performances[0]
+	for _, perf := range performances[1:] {
+		if perf.Change() > best.Change() {
+			best = perf
+		}
+		if perf.Change() < worst.Change() {
+			worst = perf
+		}
+	}
+	fmt.Fprintf(&b, "\nا🏆 بهترین عملکرد: %s %+.2f%%\nا🔻 بدترین عملکرد: %s %+.2f%%",
+		best.Asset.Name, best.Change(), worst.Asset.Name, worst.Change())
+
+	return b.String(), performances, true
+}
@@ -0,0 +1,83 @@
+package report
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/utils"
+)
+
+func TestPeriods(t *testing.T) {
+	week := Week(utils.JDate{Year: 1404, Month: 2, Day: 16})
+	if week.From != (utils.JDate{Year: 1404, Month: 2, Day: 13}) || week.LastDay() != (utils.JDate{Year: 1404, Month: 2, Day: 19}) {
+		t.Errorf("Unexpected week %v - %v", week.From, week.LastDay())
+	}
+
+	month := Month(utils.JDate{Year: 1403, Month: 12, Day: 5})
+	if month.From != (utils.JDate{Year: 1403, Month: 12, Day: 1}) || month.LastDay() != (utils.JDate{Year: 1403, Month: 12, Day: 30}) {
+		t.Errorf("Unexpected month %v - %v", month.From, month.LastDay())
+	}
+	if month.Title != "گزارش ماهانه اسفند 1403" {
+		t.Errorf("Unexpected month title %q", month.Title)
+	}
+}
+
+func TestPeriodic(t *testing.T) {
+	store, _ := history.Open("")
+	week := Week(utils.JDate{Year: 1404, Month: 2, Day: 13})
+
+	// usd closes 100, 102, 101, 104 over the week; eur and sekee fall
+	closes := []map[string]float64{
+		{"usd": 100, "eur": 200, "sekee": 1000},
+		{"usd": 102, "eur": 198, "sekee": 990},
+		{"usd": 101, "eur": 196, "sekee": 980},
+		{"usd": 104, "eur": 190, "sekee": 970},
+	}
+	store.Record(week.From.AddDays(-1).Time(), decimals(closes[0]))
+	for i, values := range closes[1:] {
+		store.Record(week.From.AddDays(i).Time().Add(12*3600e9), decimals(values))
+	}
+
+	message, performances, ok := Periodic(store, week)
+	if !ok {
+		t.Fatal("Expected a weekly report")
+	}
+
+	expectedStrings := []string{
+		"گزارش هفتگی 1404/02/13 تا 1404/02/19",
+		"ارزها",
+		"سکه",
+		"دلار امریکا (4.00%🟢)",
+		"بهترین عملکرد: دلار امریکا +4.00%",
+		"بدترین عملکرد: یورو اروپا -5.00%",
+	}
+	for _, expected := range expectedStrings {
+		if !strings.Contains(message, expected) {
+			t.Errorf("Expected report to contain '%s', got %s", expected, message)
+		}
+	}
+	if strings.Contains(message, "رمزارزها") {
+		t.Error("Expected groups without history to be omitted")
+	}
+
+	// daily changes 2%, -0.98%, 2.97%
+	if v := performances[0].Volatility; math.Abs(v-2.06) > 0.01 {
+		t.Errorf("Expected usd volatility ~2.06, got %v", v)
+	}
+
+	image, err := Chart(week, performances)
+	if err != nil {
+		t.Fatalf("Chart failed: %v", err)
+	}
+	config, err := png.DecodeConfig(bytes.NewReader(image))
+	if err != nil {
+		t.Fatalf("Chart is not a PNG: %v", err)
+	}
+	if config.Width != chartWidth || config.Height != chartMargin*2+chartRow*(len(performances)+1) {
+		t.Errorf("Unexpected chart size %dx%d", config.Width, config.Height)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/onionj/pricebot/report"
+	"github.com/onionj/pricebot/utils"
+)
+
+// postDailyReport posts the day's summary once per trading day after DAILY_REPORT_HOUR:DAILY_REPORT_MINUTE.
+func (b *bot) postDailyReport(ctx context.Context) error {
+	jnow := utils.Now()
+	// the job runs daily, so a calendar going stale at Nowruz is noticed
+	b.calendar.WarnStale(b.logger, jnow.Time())
+	today := jnow.Date()
+	if b.tel.LastDailyReport == today.String() || !b.calendar.IsTradingDay(today) {
+		return nil
+	}
+	if jnow.Hour()*60+jnow.Minute() < DAILY_REPORT_HOUR*60+DAILY_REPORT_MINUTE {
+		return nil
+	}
+
+	message, ok := report.Daily(b.store, today)
+	if !ok {
+		return nil
+	}
+	if _, err := b.tel.Post(ctx, message); err != nil {
+		return fmt.Errorf("post daily report %s: %w", today, err)
+	}
+
+	b.tel.LastDailyReport = today.String()
+	return b.tel.SaveState()
+}
+
+// postPeriodReports posts the weekly and monthly reports on the last day of
+// their period after PERIOD_REPORT_HOUR.
+func (b *bot) postPeriodReports(ctx context.Context) error {
+	jnow := utils.Now()
+	if jnow.Hour() < PERIOD_REPORT_HOUR {
+		return nil
+	}
+	today := jnow.Date()
+
+	if week := report.Week(today); week.LastDay() == today && b.tel.LastWeeklyReport != week.From.String() {
+		posted, err := b.postPeriodReport(ctx, week)
+		if err != nil {
+			return err
+		}
+		if posted {
+			b.tel.LastWeeklyReport = week.From.String()
+			if err := b.tel.SaveState(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if month := report.Month(today); month.LastDay() == today && b.tel.LastMonthlyReport != month.From.String() {
+		posted, err := b.postPeriodReport(ctx, month)
+		if err != nil {
+			return err
+		}
+		if posted {
+			b.tel.LastMonthlyReport = month.From.String()
+			return b.tel.SaveState()
+		}
+	}
+	return nil
+}
+
+// postPeriodReport posts the report message followed by its chart and
+// reports whether the message went out. A failed chart is logged only, since
+// the report itself was already posted.
+func (b *bot) postPeriodReport(ctx context.Context, period report.Period) (bool, error) {
+	message, performances, ok := report.Periodic(b.store, period)
+	if !ok {
+		return false, nil
+	}
+	if _, err := b.tel.Post(ctx, message); err != nil {
+		return false, fmt.Errorf("post %s: %w", period.Title, err)
+	}
+
+	chart, err := report.Chart(period, performances)
+	if err != nil {
+		b.logger.Error("render report chart error", "period", period.Title, "error", err)
+		return true, nil
+	}
+	if _, err := b.tel.SendPhoto(ctx, chart, period.Title); err != nil {
+		b.logger.Error("send report chart error", "period", period.Title, "error", err)
+	}
+	return true, nil
+}
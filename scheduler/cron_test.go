@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/utils"
+)
+
+func TestParse_Next(t *testing.T) {
+	// 2025-05-06 is a Tuesday
+	from := time.Date(2025, 5, 6, 10, 17, 30, 0, utils.Tehran)
+
+	testCases := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2025, 5, 6, 10, 18, 0, 0, utils.Tehran)},
+		{"*/15 * * * *", time.Date(2025, 5, 6, 10, 30, 0, 0, utils.Tehran)},
+		{"30 19 * * *", time.Date(2025, 5, 6, 19, 30, 0, 0, utils.Tehran)},
+		{"0 9 * * sat-wed", time.Date(2025, 5, 7, 9, 0, 0, 0, utils.Tehran)},
+		{"0 12 * * fri", time.Date(2025, 5, 9, 12, 0, 0, 0, utils.Tehran)},
+		{"0 0 1 * *", time.Date(2025, 6, 1, 0, 0, 0, 0, utils.Tehran)},
+		{"0 0 1,15 jun *", time.Date(2025, 6, 1, 0, 0, 0, 0, utils.Tehran)},
+		{"0 8-10/2 * * *", time.Date(2025, 5, 7, 8, 0, 0, 0, utils.Tehran)},
+		{"0 0 13 * 5", time.Date(2025, 5, 9, 0, 0, 0, 0, utils.Tehran)}, // day of month or Friday
+		{"0 0 * * 7", time.Date(2025, 5, 11, 0, 0, 0, 0, utils.Tehran)}, // 7 is Sunday
+		{"@weekly", time.Date(2025, 5, 10, 0, 0, 0, 0, utils.Tehran)},
+		{"@hourly", time.Date(2025, 5, 6, 11, 0, 0, 0, utils.Tehran)},
+		{"@every 4s", from.Add(4 * time.Second)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+
+	for _, tc := range testCases {
+		schedule, err := Parse(tc.spec, utils.Tehran)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tc.spec, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(tc.expected) {
+			t.Errorf("Parse(%q).Next = %s; want %s", tc.spec, got, tc.expected)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	testCases := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@every",
+		"@every -1s",
+		"@every soon",
+	}
+
+	for _, spec := range testCases {
+		if _, err := Parse(spec, utils.Tehran); err == nil {
+			t.Errorf("Parse(%q) expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MissedPolicy decides what happens to activations that passed while a job
+// was running, the process was down or the machine was suspended.
+type MissedPolicy int
+
+const (
+	// SkipMissed drops missed activations and waits for the next one.
+	SkipMissed MissedPolicy = iota
+	// RunMissed runs the job once to catch up, however many activations were missed.
+	RunMissed
+)
+
+// lateTolerance is how late a run may start before it counts as missed.
+const lateTolerance = 5 * time.Second
+
+type Job struct {
+	Name string
+	// Spec is a cron expression, see Parse
+	Spec string
+	Run  func(ctx context.Context) error
+	// Jitter delays each run by a random duration up to Jitter
+	Jitter time.Duration
+	Missed MissedPolicy
+	// Immediately runs the job once as soon as the scheduler starts
+	Immediately bool
+	// LastRun is when the job last ran before the scheduler started, if
+	// known; activations since then count as missed
+	LastRun time.Time
+}
+
+// JobStatus reports a job's recent runs.
+type JobStatus struct {
+	Name      string
+	Spec      string
+	Next      time.Time
+	LastRun   time.Time
+	LastError string
+	Runs      int
+	Failures  int
+	Skipped   int
+}
+
+type entry struct {
+	job      Job
+	schedule Schedule
+
+	mu     sync.Mutex
+	status JobStatus
+}
+
+// Scheduler runs named jobs on cron schedules. A job never overlaps with
+// itself: it runs on its own goroutine and the next activation is computed
+// once the previous run has finished.
+type Scheduler struct {
+	loc     *time.Location
+	logger  *slog.Logger
+	entries []*entry
+
+	// OnError, when set, is called with every failed run
+	OnError func(job string, err error)
+
+	now func() time.Time
+}
+
+// New creates a scheduler evaluating cron expressions in loc.
+func New(loc *time.Location, logger *slog.Logger) *Scheduler {
+	return &Scheduler{loc: loc, logger: logger, now: time.Now}
+}
+
+// Add registers a job; it must be called before Run.
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return fmt.Errorf("job needs a name and a run function")
+	}
+	for _, e := range s.entries {
+		if e.job.Name == job.Name {
+			return fmt.Errorf("duplicate job %q", job.Name)
+		}
+	}
+
+	schedule, err := Parse(job.Spec, s.loc)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+
+	s.entries = append(s.entries, &entry{
+		job:      job,
+		schedule: schedule,
+		status:   JobStatus{Name: job.Name, Spec: job.Spec, LastRun: job.LastRun},
+	})
+	return nil
+}
+
+// Run starts every job and blocks until ctx is done and running jobs have returned.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, e := range s.entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, e)
+		}()
+	}
+	wg.Wait()
+}
+
+// Status returns the state of every job sorted by name.
+func (s *Scheduler) Status() []JobStatus {
+	result := make([]JobStatus, len(s.entries))
+	for i, e := range s.entries {
+		e.mu.Lock()
+		result[i] = e.status
+		e.mu.Unlock()
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+func (s *Scheduler) loop(ctx context.Context, e *entry) {
+	logger := s.logger.With("job", e.job.Name)
+
+	var next time.Time
+	if e.job.Immediately {
+		next = s.now()
+	} else if !e.job.LastRun.IsZero() {
+		// an activation between the last run and now was missed while we were down
+		next = e.schedule.Next(e.job.LastRun)
+	} else {
+		next = e.schedule.Next(s.now())
+	}
+
+	for {
+		if next.IsZero() {
+			logger.Warn("job has no future activations")
+			return
+		}
+
+		if late := s.now().Sub(next); late > lateTolerance {
+			// catch up at most once, then continue from now
+			if e.job.Missed == SkipMissed {
+				e.mu.Lock()
+				e.status.Skipped++
+				e.mu.Unlock()
+				logger.Debug("skipping missed activation", "scheduled", next, "late", late)
+				next = e.schedule.Next(s.now())
+				continue
+			}
+			logger.Info("running missed activation", "scheduled", next, "late", late)
+			next = s.now()
+		}
+
+		e.mu.Lock()
+		e.status.Next = next
+		e.mu.Unlock()
+
+		wait := next.Sub(s.now())
+		if e.job.Jitter > 0 {
+			wait += rand.N(e.job.Jitter)
+		}
+		if !sleep(ctx, wait) {
+			return
+		}
+
+		s.run(ctx, logger, e)
+		next = e.schedule.Next(next)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, logger *slog.Logger, e *entry) {
+	start := s.now()
+	err := e.job.Run(ctx)
+
+	e.mu.Lock()
+	e.status.LastRun = start
+	e.status.Runs++
+	if err != nil {
+		e.status.Failures++
+		e.status.LastError = err.Error()
+	} else {
+		e.status.LastError = ""
+	}
+	e.mu.Unlock()
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Error("job failed", "error", err, "latency", time.Since(start))
+		if s.OnError != nil {
+			s.OnError(e.job.Name, err)
+		}
+		return
+	}
+	logger.Debug("job finished", "latency", time.Since(start))
+}
+
+// sleep waits for d and reports false when ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestScheduler() *Scheduler {
+	return New(time.UTC, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestScheduler_RunsJobs(t *testing.T) {
+	s := newTestScheduler()
+
+	var runs atomic.Int32
+	var mu sync.Mutex
+	var failures []string
+	s.OnError = func(job string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failures = append(failures, job+": "+err.Error())
+	}
+
+	if err := s.Add(Job{Name: "tick", Spec: "@every 20ms", Run: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := s.Add(Job{Name: "broken", Spec: "@every 20ms", Run: func(ctx context.Context) error {
+		return errors.New("boom")
+	}}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 110*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	if n := runs.Load(); n < 3 || n > 6 {
+		t.Errorf("Expected about 5 runs, got %d", n)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failures) == 0 || failures[0] != "broken: boom" {
+		t.Errorf("Expected failures to be reported, got %v", failures)
+	}
+
+	status := s.Status()
+	if status[0].Name != "broken" || status[0].Failures == 0 || status[0].LastError != "boom" {
+		t.Errorf("Unexpected status for broken job: %+v", status[0])
+	}
+	if status[1].Runs != int(runs.Load()) {
+		t.Errorf("Expected %d runs in status, got %d", runs.Load(), status[1].Runs)
+	}
+}
+
+func TestScheduler_NoOverlap(t *testing.T) {
+	s := newTestScheduler()
+
+	var running, maxRunning atomic.Int32
+	s.Add(Job{Name: "slow", Spec: "@every 10ms", Run: func(ctx context.Context) error {
+		n := running.Add(1)
+		if n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		time.Sleep(30 * time.Millisecond)
+		running.Add(-1)
+		return nil
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	if maxRunning.Load() != 1 {
+		t.Errorf("Expected at most one concurrent run, got %d", maxRunning.Load())
+	}
+}
+
+func TestScheduler_MissedPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		policy      MissedPolicy
+		wantRuns    int32
+		wantSkipped int
+	}{
+		{"run missed", RunMissed, 1, 0},
+		{"skip missed", SkipMissed, 0, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestScheduler()
+
+			var runs atomic.Int32
+			s.Add(Job{
+				Name:    "daily",
+				Spec:    "@daily",
+				Missed:  tc.policy,
+				LastRun: time.Now().Add(-48 * time.Hour),
+				Run: func(ctx context.Context) error {
+					runs.Add(1)
+					return nil
+				},
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			s.Run(ctx)
+
+			if runs.Load() != tc.wantRuns {
+				t.Errorf("Expected %d runs, got %d", tc.wantRuns, runs.Load())
+			}
+			if skipped := s.Status()[0].Skipped; skipped != tc.wantSkipped {
+				t.Errorf("Expected %d skipped, got %d", tc.wantSkipped, skipped)
+			}
+		})
+	}
+}
+
+func TestScheduler_Immediately(t *testing.T) {
+	s := newTestScheduler()
+
+	var runs atomic.Int32
+	s.Add(Job{Name: "startup", Spec: "@daily", Immediately: true, Run: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	if runs.Load() != 1 {
+		t.Errorf("Expected 1 run at startup, got %d", runs.Load())
+	}
+}
+
+func TestScheduler_AddInvalid(t *testing.T) {
+	s := newTestScheduler()
+	run := func(ctx context.Context) error { return nil }
+
+	if err := s.Add(Job{Name: "bad", Spec: "nope", Run: run}); err == nil {
+		t.Error("Expected error for an invalid spec")
+	}
+	if err := s.Add(Job{Spec: "@daily", Run: run}); err == nil {
+		t.Error("Expected error for a job without a name")
+	}
+	s.Add(Job{Name: "dup", Spec: "@daily", Run: run})
+	if err := s.Add(Job{Name: "dup", Spec: "@daily", Run: run}); err == nil {
+		t.Error("Expected error for a duplicate job")
+	}
+}
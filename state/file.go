@@ -0,0 +1,119 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+)
+
+// File keeps the whole state in memory and rewrites it as one JSON document
+// on every change. It suits the few keys the bot writes a handful of times
+// a minute.
+type File struct {
+	mu      sync.Mutex
+	path    string
+	buckets map[string]map[string]json.RawMessage
+}
+
+type document struct {
+	Buckets map[string]map[string]json.RawMessage `json:"buckets"`
+}
+
+// OpenFile loads the state document at path, creating it on first write. An
+// empty path keeps the state in memory only.
+func OpenFile(path string) (*File, error) {
+	f := &File{path: path, buckets: make(map[string]map[string]json.RawMessage)}
+	if path == "" {
+		return f, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(data) == 0) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	for bucket, values := range doc.Buckets {
+		f.buckets[bucket] = values
+	}
+	return f, nil
+}
+
+func (f *File) Get(bucket, key string, v any) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	raw, ok := f.buckets[bucket][key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+func (f *File) Put(bucket, key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.buckets[bucket] == nil {
+		f.buckets[bucket] = make(map[string]json.RawMessage)
+	}
+	f.buckets[bucket][key] = raw
+	return f.saveLocked()
+}
+
+func (f *File) Delete(bucket, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.buckets[bucket][key]; !ok {
+		return nil
+	}
+	delete(f.buckets[bucket], key)
+	return f.saveLocked()
+}
+
+func (f *File) Keys(bucket string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return sortedKeys(f.buckets[bucket]), nil
+}
+
+func (f *File) Close() error {
+	return nil
+}
+
+// saveLocked atomically replaces the state file with the in-memory state.
+func (f *File) saveLocked() error {
+	if f.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(document{Buckets: f.buckets}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.path, data)
+}
+
+func sortedKeys(values map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,254 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/onionj/pricebot/filelock"
+)
+
+// compaction rewrites the log once it holds this many records and more than
+// compactRatio records per live key.
+const (
+	compactMin   = 1000
+	compactRatio = 4
+)
+
+// compactWrite replaces the log with its compacted form; tests make it fail.
+var compactWrite = writeFileAtomic
+
+// KV is an embedded key-value store: an append-only log of changes that is
+// replayed into memory on open. Each change is one fsynced line, so unlike
+// File a write costs one small append however large the state grows. A
+// change torn by a crash is dropped on the next open. The log is locked
+// while open, so that no two processes append to or compact it at once.
+type KV struct {
+	mu      sync.Mutex
+	path    string
+	log     *os.File
+	lock    *os.File
+	records int
+	buckets map[string]map[string]json.RawMessage
+}
+
+// kvRecord is one change in the log; a nil Value deletes the key.
+type kvRecord struct {
+	Bucket string          `json:"b"`
+	Key    string          `json:"k"`
+	Value  json.RawMessage `json:"v,omitempty"`
+}
+
+// OpenKV replays the log at path, creating it if missing, and holds its lock
+// until Close. It fails with ErrLocked rather than wait for the lock.
+func OpenKV(path string) (*KV, error) {
+	lock, err := filelock.Lock(path + ".lock")
+	if errors.Is(err, filelock.ErrLocked) {
+		err = ErrLocked
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	kv := &KV{path: path, lock: lock, buckets: make(map[string]map[string]json.RawMessage)}
+	if err := kv.replay(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	log, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	kv.log = log
+	return kv, nil
+}
+
+// replay loads the log into memory. Only the last change can be torn by a
+// crash mid-append, so an unreadable last line without its newline is cut
+// off, while one anywhere else fails the replay rather than drop the
+// changes after it.
+func (kv *KV) replay() error {
+	f, err := os.OpenFile(kv.path, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var good int64
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(data) == 0 {
+			return nil
+		}
+
+		var rec kvRecord
+		if jsonErr := json.Unmarshal(data, &rec); jsonErr != nil {
+			if err != io.EOF {
+				return fmt.Errorf("state log %s line %d: %w", kv.path, line, jsonErr)
+			}
+			if err := f.Truncate(good); err != nil {
+				return err
+			}
+			return f.Sync()
+		}
+		kv.apply(rec)
+		kv.records++
+		good += int64(len(data))
+
+		// a whole change whose newline was lost gets it back, so that the
+		// next change starts on a line of its own
+		if err == io.EOF {
+			if _, err := f.WriteAt([]byte{'\n'}, good); err != nil {
+				return err
+			}
+			return f.Sync()
+		}
+	}
+}
+
+func (kv *KV) apply(rec kvRecord) {
+	if rec.Value == nil {
+		delete(kv.buckets[rec.Bucket], rec.Key)
+		return
+	}
+	if kv.buckets[rec.Bucket] == nil {
+		kv.buckets[rec.Bucket] = make(map[string]json.RawMessage)
+	}
+	kv.buckets[rec.Bucket][rec.Key] = rec.Value
+}
+
+func (kv *KV) Get(bucket, key string, v any) (bool, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	raw, ok := kv.buckets[bucket][key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+func (kv *KV) Put(bucket, key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.appendLocked(kvRecord{Bucket: bucket, Key: key, Value: raw})
+}
+
+func (kv *KV) Delete(bucket, key string) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if _, ok := kv.buckets[bucket][key]; !ok {
+		return nil
+	}
+	return kv.appendLocked(kvRecord{Bucket: bucket, Key: key})
+}
+
+func (kv *KV) Keys(bucket string) ([]string, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	return sortedKeys(kv.buckets[bucket]), nil
+}
+
+func (kv *KV) Close() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if kv.lock == nil {
+		return nil
+	}
+	// the log is already closed if reopening it after a compaction failed
+	var err error
+	if kv.log != nil {
+		err = kv.log.Close()
+	}
+	err = errors.Join(err, kv.lock.Close())
+	kv.log, kv.lock = nil, nil
+	return err
+}
+
+// appendLocked writes rec to the log and applies it.
+func (kv *KV) appendLocked(rec kvRecord) error {
+	if kv.log == nil {
+		return errClosed
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := kv.log.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := kv.log.Sync(); err != nil {
+		return err
+	}
+	kv.apply(rec)
+	kv.records++
+
+	if kv.records >= compactMin && kv.records > compactRatio*kv.liveLocked() {
+		return kv.compactLocked()
+	}
+	return nil
+}
+
+func (kv *KV) liveLocked() int {
+	live := 0
+	for _, values := range kv.buckets {
+		live += len(values)
+	}
+	return live
+}
+
+// compactLocked atomically replaces the log with one record per live key.
+func (kv *KV) compactLocked() error {
+	var buf bytes.Buffer
+	records := 0
+	for bucket, values := range kv.buckets {
+		for _, key := range sortedKeys(values) {
+			line, err := json.Marshal(kvRecord{Bucket: bucket, Key: key, Value: values[key]})
+			if err != nil {
+				return err
+			}
+			buf.Write(line)
+			buf.WriteByte('\n')
+			records++
+		}
+	}
+
+	// the log is closed for the rename, which Windows refuses over an open
+	// file, and reopened whether or not the rewrite worked: a failed one
+	// leaves the old log in place, so writes carry on uncompacted
+	kv.log.Close()
+	err := compactWrite(kv.path, buf.Bytes())
+	log, openErr := os.OpenFile(kv.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if openErr != nil {
+		kv.log = nil
+		return errors.Join(err, openErr)
+	}
+	kv.log = log
+	if err != nil {
+		return fmt.Errorf("compact state log: %w", err)
+	}
+	kv.records = records
+	return nil
+}
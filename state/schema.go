@@ -0,0 +1,113 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const versionKey = "schema_version"
+
+// Migration upgrades a store to Version.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(Store) error
+}
+
+// Version returns the schema version of s, zero for a new store.
+func Version(s Store) (int, error) {
+	var version int
+	_, err := s.Get(Meta, versionKey, &version)
+	return version, err
+}
+
+// Migrate runs the migrations newer than the version of s in order,
+// recording the version after each one.
+func Migrate(s Store, migrations []Migration) error {
+	version, err := Version(s)
+	if err != nil {
+		return err
+	}
+
+	for i, m := range migrations {
+		if i > 0 && m.Version <= migrations[i-1].Version {
+			return fmt.Errorf("migration %d (%s) is out of order", m.Version, m.Name)
+		}
+		if m.Version <= version {
+			continue
+		}
+		if err := m.Up(s); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		if err := s.Put(Meta, versionKey, m.Version); err != nil {
+			return err
+		}
+		version = m.Version
+	}
+	return nil
+}
+
+// Schema returns the migrations to the current schema. legacyFile is the
+// telegram_state.json written by earlier versions, which held the state of
+// chatID only.
+func Schema(legacyFile, chatID string) []Migration {
+	return []Migration{
+		{Version: 1, Name: "import legacy telegram state", Up: func(s Store) error {
+			return importLegacy(s, legacyFile, chatID)
+		}},
+	}
+}
+
+type legacyState struct {
+	LastMessageId     int    `json:"last_message_id"`
+	LastMessageTime   int64  `json:"last_message_time"`
+	LastDailyReport   string `json:"last_daily_report"`
+	LastWeeklyReport  string `json:"last_weekly_report"`
+	LastMonthlyReport string `json:"last_monthly_report"`
+	UpdateOffset      int    `json:"update_offset"`
+}
+
+func importLegacy(s Store, path, chatID string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(data) == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var legacy legacyState
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+
+	if legacy.LastMessageId != 0 {
+		msg := Message{MessageID: legacy.LastMessageId}
+		if legacy.LastMessageTime > 0 {
+			msg.PostedAt = time.Unix(legacy.LastMessageTime, 0)
+		}
+		if err := s.Put(Messages, chatID, msg); err != nil {
+			return err
+		}
+	}
+	chat := Chat{
+		LastDailyReport:   legacy.LastDailyReport,
+		LastWeeklyReport:  legacy.LastWeeklyReport,
+		LastMonthlyReport: legacy.LastMonthlyReport,
+	}
+	if chat != (Chat{}) {
+		if err := s.Put(Chats, chatID, chat); err != nil {
+			return err
+		}
+	}
+	if legacy.UpdateOffset != 0 {
+		return s.Put(Offsets, TelegramOffset, legacy.UpdateOffset)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package state
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	_ "modernc.org/sqlite" // registers the pure Go "sqlite" driver
+)
+
+// SQLite keeps the state in one table of a SQLite database. Each change is
+// its own transaction, so SQLite's journal keeps it whole across a crash.
+type SQLite struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens the SQLite database at path.
+func OpenSQLite(path string) (*SQLite, error) {
+	// a single connection serializes the writes, and the busy timeout
+	// waits out another process, such as "pricebot state", holding the
+	// database instead of failing at once
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	s, err := NewSQLite(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewSQLite keeps the state in db, creating its table if missing.
+func NewSQLite(db *sql.DB) (*SQLite, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS state (
+		bucket TEXT NOT NULL,
+		key    TEXT NOT NULL,
+		value  TEXT NOT NULL,
+		PRIMARY KEY (bucket, key)
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLite{db: db}, nil
+}
+
+func (s *SQLite) Get(bucket, key string, v any) (bool, error) {
+	var raw string
+	err := s.db.QueryRow(`SELECT value FROM state WHERE bucket = ? AND key = ?`, bucket, key).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal([]byte(raw), v)
+}
+
+func (s *SQLite) Put(bucket, key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO state (bucket, key, value) VALUES (?, ?, ?)
+		ON CONFLICT (bucket, key) DO UPDATE SET value = excluded.value`, bucket, key, string(raw))
+	return err
+}
+
+func (s *SQLite) Delete(bucket, key string) error {
+	_, err := s.db.Exec(`DELETE FROM state WHERE bucket = ? AND key = ?`, bucket, key)
+	return err
+}
+
+func (s *SQLite) Keys(bucket string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT key FROM state WHERE bucket = ? ORDER BY key`, bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,142 @@
+// Package state persists the bot's small runtime state: the messages it keeps
+// editing, per-chat report markers, known users with their portfolios and
+// private boards, raised alerts, update offsets and sent digests. Values are stored as JSON under a bucket and key
+// in a Store.
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Buckets of the current schema.
+const (
+	Meta     = "meta"     // schema version
+	Chats    = "chats"    // Chat by chat ID
+	Messages = "messages" // live Message by chat ID
+	Users    = "users"    // User by user ID
+	Alerts   = "alerts"   // Alert by name
+	Offsets  = "offsets"  // update offsets by source
+	Digests  = "digests"  // Digest by name
+	// Portfolios and Boards hold a portfolio.Portfolio and a
+	// subscription.Subscription by user ID
+	Portfolios = "portfolios"
+	Boards     = "boards"
+)
+
+// TelegramOffset is the Offsets key of the Telegram getUpdates offset.
+const TelegramOffset = "telegram"
+
+// Store is a bucketed key-value store of JSON values.
+type Store interface {
+	// Get decodes the value of key in bucket into v, reporting whether it exists.
+	Get(bucket, key string, v any) (bool, error)
+	Put(bucket, key string, v any) error
+	Delete(bucket, key string) error
+	// Keys returns the keys of bucket in order.
+	Keys(bucket string) ([]string, error)
+	Close() error
+}
+
+// Chat is the per-chat state of the channel the bot posts to.
+type Chat struct {
+	// LastDailyReport is the Jalali date of the last posted daily summary
+	LastDailyReport string `json:"last_daily_report,omitempty"`
+	// LastWeeklyReport and LastMonthlyReport are the first days of the last reported periods
+	LastWeeklyReport  string `json:"last_weekly_report,omitempty"`
+	LastMonthlyReport string `json:"last_monthly_report,omitempty"`
+}
+
+// Message is the live message the bot keeps editing in a chat, keyed by the
+// chat ID for Telegram and by publisher and target for other platforms.
+type Message struct {
+	MessageID int `json:"message_id,omitempty"`
+	// Ref is the message ID on platforms whose IDs are not numbers
+	Ref      string    `json:"ref,omitempty"`
+	PostedAt time.Time `json:"posted_at"`
+}
+
+// User is someone who has sent the bot a command.
+type User struct {
+	ID        int64     `json:"id"`
+	ChatID    string    `json:"chat_id"`
+	Username  string    `json:"username,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Alert is a condition the bot has alerted on, kept so that a restart
+// neither repeats nor forgets the alert.
+type Alert struct {
+	Active bool      `json:"active"`
+	Since  time.Time `json:"since"`
+}
+
+// Digest is the delivery state of an email digest.
+type Digest struct {
+	SentAt time.Time `json:"sent_at"`
+}
+
+// Open opens the store of backend at path: "file" for a JSON document,
+// "kv" for an append-only key-value log or "sqlite" for a SQLite database.
+func Open(backend, path string) (Store, error) {
+	switch backend {
+	case "", "file":
+		return OpenFile(path)
+	case "kv":
+		return OpenKV(path)
+	case "sqlite":
+		return OpenSQLite(path)
+	}
+	return nil, fmt.Errorf("unknown state backend %q", backend)
+}
+
+// writeFileAtomic replaces path with data so that a crash leaves either the
+// old or the new file, never a partial one.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0600)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes a rename in dir to disk. Not every platform can sync a
+// directory, so failures are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+var errClosed = errors.New("state store is closed")
+
+// ErrLocked is returned by OpenKV while another process, such as the bot or
+// "pricebot state", has the log open.
+var ErrLocked = errors.New("state log is in use by another process")
@@ -0,0 +1,261 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStores(t *testing.T) {
+	backends := []struct {
+		name string
+		open func(path string) (Store, error)
+	}{
+		{"file", func(path string) (Store, error) { return OpenFile(path) }},
+		{"kv", func(path string) (Store, error) { return OpenKV(path) }},
+		{"sqlite", func(path string) (Store, error) { return OpenSQLite(path) }},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state")
+			s, err := backend.open(path)
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+
+			posted := time.Date(2025, 5, 6, 12, 0, 0, 0, time.UTC)
+			s.Put(Messages, "-100", Message{MessageID: 1, PostedAt: posted})
+			s.Put(Messages, "-100", Message{MessageID: 2, PostedAt: posted})
+			s.Put(Users, "7", User{ID: 7})
+			s.Put(Users, "42", User{ID: 42})
+			if err := s.Delete(Users, "7"); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			s, err = backend.open(path)
+			if err != nil {
+				t.Fatalf("Reopen failed: %v", err)
+			}
+			defer s.Close()
+
+			var msg Message
+			if ok, err := s.Get(Messages, "-100", &msg); !ok || err != nil || msg.MessageID != 2 || !msg.PostedAt.Equal(posted) {
+				t.Errorf("Get = %+v, %v, %v; want message 2", msg, ok, err)
+			}
+			if ok, _ := s.Get(Messages, "-200", &msg); ok {
+				t.Error("Expected a missing key not to be found")
+			}
+			if keys, _ := s.Keys(Users); !slices.Equal(keys, []string{"42"}) {
+				t.Errorf("Expected user keys [42], got %v", keys)
+			}
+		})
+	}
+}
+
+func TestKV_TornWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.kv")
+	s, _ := OpenKV(path)
+	s.Put(Offsets, TelegramOffset, 10)
+	s.Close()
+
+	// a crash in the middle of appending the next change
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"b":"offsets","k":"telegram","v":1`)
+	f.Close()
+
+	s, err := OpenKV(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	var offset int
+	if s.Get(Offsets, TelegramOffset, &offset); offset != 10 {
+		t.Errorf("Expected offset 10 after dropping the torn change, got %d", offset)
+	}
+	s.Put(Offsets, TelegramOffset, 11)
+	s.Close()
+
+	s, _ = OpenKV(path)
+	defer s.Close()
+	if s.Get(Offsets, TelegramOffset, &offset); offset != 11 {
+		t.Errorf("Expected offset 11 written after the torn change, got %d", offset)
+	}
+}
+
+func TestKV_BadLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.kv")
+	log := `{"b":"offsets","k":"telegram","v":10}` + "\n" +
+		`{"b":"offsets","k":"telegram","v":` + "\n" +
+		`{"b":"chats","k":"-100","v":{}}` + "\n"
+	if err := os.WriteFile(path, []byte(log), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// a bad change before others is no torn append, and nothing is dropped
+	if _, err := OpenKV(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error at line 2, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != log {
+		t.Errorf("Expected the log left alone, got %q", data)
+	}
+
+	// a whole last change without its newline is kept
+	log = `{"b":"offsets","k":"telegram","v":10}` + "\n" + `{"b":"offsets","k":"telegram","v":11}`
+	os.WriteFile(path, []byte(log), 0600)
+	s, err := OpenKV(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	s.Put(Offsets, TelegramOffset, 12)
+	s.Close()
+	s, err = OpenKV(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer s.Close()
+	var offset int
+	if s.Get(Offsets, TelegramOffset, &offset); offset != 12 {
+		t.Errorf("Expected offset 12, got %d", offset)
+	}
+}
+
+func TestKV_Locked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.kv")
+	s, err := OpenKV(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := OpenKV(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked while the log is open, got %v", err)
+	}
+	s.Close()
+
+	s, err = OpenKV(path)
+	if err != nil {
+		t.Fatalf("Expected the lock released by Close, got %v", err)
+	}
+	s.Close()
+}
+
+func TestKV_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.kv")
+	s, _ := OpenKV(path)
+	for i := 1; i <= 2*compactMin; i++ {
+		if err := s.Put(Offsets, TelegramOffset, i); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	s.Close()
+
+	data, _ := os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines >= compactMin {
+		t.Errorf("Expected the log to be compacted, got %d records", lines)
+	}
+
+	s, _ = OpenKV(path)
+	defer s.Close()
+	var offset int
+	if s.Get(Offsets, TelegramOffset, &offset); offset != 2*compactMin {
+		t.Errorf("Expected offset %d after compaction, got %d", 2*compactMin, offset)
+	}
+}
+
+func TestKV_CompactFails(t *testing.T) {
+	defer func(write func(string, []byte) error) { compactWrite = write }(compactWrite)
+	compactWrite = func(string, []byte) error { return errors.New("disk full") }
+
+	path := filepath.Join(t.TempDir(), "state.kv")
+	s, _ := OpenKV(path)
+	failed := false
+	for i := 1; i <= compactMin+10; i++ {
+		if err := s.Put(Offsets, TelegramOffset, i); err != nil {
+			failed = true
+		}
+	}
+	if !failed {
+		t.Fatal("Expected the failed compaction to be reported")
+	}
+
+	// the old log is still open and takes writes
+	compactWrite = writeFileAtomic
+	if err := s.Put(Offsets, TelegramOffset, 1); err != nil {
+		t.Fatalf("Expected writes after a failed compaction, got %v", err)
+	}
+	s.Close()
+
+	s, _ = OpenKV(path)
+	defer s.Close()
+	var offset int
+	if s.Get(Offsets, TelegramOffset, &offset); offset != 1 {
+		t.Errorf("Expected offset 1 after reopening, got %d", offset)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	s, _ := OpenFile("")
+	var ran []int
+	migration := func(version int) Migration {
+		return Migration{Version: version, Name: "test", Up: func(Store) error {
+			ran = append(ran, version)
+			return nil
+		}}
+	}
+
+	if err := Migrate(s, []Migration{migration(1), migration(2)}); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if err := Migrate(s, []Migration{migration(1), migration(2), migration(3)}); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if !slices.Equal(ran, []int{1, 2, 3}) {
+		t.Errorf("Expected migrations [1 2 3] to run once each, got %v", ran)
+	}
+	if version, _ := Version(s); version != 3 {
+		t.Errorf("Expected version 3, got %d", version)
+	}
+
+	if err := Migrate(s, []Migration{migration(5), migration(4)}); err == nil {
+		t.Error("Expected out of order migrations to fail")
+	}
+}
+
+func TestSchema_ImportLegacy(t *testing.T) {
+	legacy := filepath.Join(t.TempDir(), "telegram_state.json")
+	os.WriteFile(legacy, []byte(`{"last_message_id":456,"last_message_time":1234567890,"last_daily_report":"1404/02/16","update_offset":11}`), 0644)
+
+	s, _ := OpenFile("")
+	if err := Migrate(s, Schema(legacy, "-100")); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	var msg Message
+	var chat Chat
+	var offset int
+	s.Get(Messages, "-100", &msg)
+	s.Get(Chats, "-100", &chat)
+	s.Get(Offsets, TelegramOffset, &offset)
+	if msg.MessageID != 456 || msg.PostedAt.Unix() != 1234567890 {
+		t.Errorf("Unexpected imported message %+v", msg)
+	}
+	if chat.LastDailyReport != "1404/02/16" || offset != 11 {
+		t.Errorf("Unexpected imported chat %+v and offset %d", chat, offset)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := Open("redis", ""); err == nil {
+		t.Error("Expected an unknown backend to fail")
+	}
+	s, err := Open("sqlite", filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("Expected sqlite to open, got %v", err)
+	}
+	s.Close()
+}
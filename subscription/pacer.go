@@ -0,0 +1,63 @@
+package subscription
+
+import (
+	"sort"
+	"time"
+)
+
+// Pacer decides which boards to edit next. It keeps every board's last edit
+// in memory and spreads edits with a token bucket so that all boards together
+// stay within a global rate, editing the most overdue boards first.
+type Pacer struct {
+	perSecond float64
+	tokens    float64
+	refilled  time.Time
+	lastEdit  map[int64]time.Time
+}
+
+// NewPacer returns a pacer allowing perSecond edits per second.
+func NewPacer(perSecond int) *Pacer {
+	return &Pacer{perSecond: float64(perSecond), tokens: float64(perSecond), lastEdit: make(map[int64]time.Time)}
+}
+
+// Due returns the subscriptions whose interval has passed since their last
+// edit, most overdue first. Boards not posted yet come before all others.
+func (p *Pacer) Due(subscriptions []Subscription, now time.Time) []Subscription {
+	var due []Subscription
+	for _, s := range subscriptions {
+		if s.MessageID == 0 || !now.Before(p.lastEdit[s.UserID].Add(s.Interval)) {
+			due = append(due, s)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		if (due[i].MessageID == 0) != (due[j].MessageID == 0) {
+			return due[i].MessageID == 0
+		}
+		return p.lastEdit[due[i].UserID].Add(due[i].Interval).Before(p.lastEdit[due[j].UserID].Add(due[j].Interval))
+	})
+	return due
+}
+
+// Take consumes one edit from the budget, reporting false when the budget
+// is spent until it refills.
+func (p *Pacer) Take(now time.Time) bool {
+	if !p.refilled.IsZero() {
+		p.tokens = min(p.perSecond, p.tokens+now.Sub(p.refilled).Seconds()*p.perSecond)
+	}
+	p.refilled = now
+	if p.tokens < 1 {
+		return false
+	}
+	p.tokens--
+	return true
+}
+
+// Done records that the board of userID is current as of now.
+func (p *Pacer) Done(userID int64, now time.Time) {
+	p.lastEdit[userID] = now
+}
+
+// Forget drops what the pacer knows about userID, making the board due at once.
+func (p *Pacer) Forget(userID int64) {
+	delete(p.lastEdit, userID)
+}
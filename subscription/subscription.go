@@ -0,0 +1,152 @@
+package subscription
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/state"
+)
+
+const (
+	// MinInterval keeps each board well within Telegram's per-chat limit
+	MinInterval     = 30 * time.Second
+	DefaultInterval = time.Minute
+)
+
+// DefaultAssets are shown when a user subscribes without picking assets.
+var DefaultAssets = []string{"usd", "eur", "usdt", "sekee", "geram18"}
+
+// Subscription is a user's private board: one message in their chat kept
+// up to date with the assets they picked.
+type Subscription struct {
+	UserID   int64         `json:"user_id"`
+	ChatID   string        `json:"chat_id"`
+	Assets   []string      `json:"assets"`
+	Interval time.Duration `json:"interval"`
+	// MessageID is the board message, zero until it is posted
+	MessageID int       `json:"message_id,omitempty"`
+	PostedAt  time.Time `json:"posted_at"`
+	Created   time.Time `json:"created"`
+}
+
+// Parse reads the arguments of "/subscribe usd,sekee btc 2m": asset keys,
+// separated by commas or spaces, and an optional refresh interval.
+func Parse(args []string) (assets []string, interval time.Duration, err error) {
+	interval = DefaultInterval
+	for _, arg := range args {
+		if d, err := time.ParseDuration(arg); err == nil {
+			if d < MinInterval {
+				return nil, 0, fmt.Errorf("interval %s is below the minimum of %s", d, MinInterval)
+			}
+			interval = d
+			continue
+		}
+		for _, key := range strings.Split(arg, ",") {
+			if key == "" {
+				continue
+			}
+			asset, ok := price.FindAsset(key)
+			if !ok {
+				return nil, 0, fmt.Errorf("unknown asset %q", key)
+			}
+			if !slices.Contains(assets, asset.Key) {
+				assets = append(assets, asset.Key)
+			}
+		}
+	}
+	if len(assets) == 0 {
+		assets = append([]string(nil), DefaultAssets...)
+	}
+	return assets, interval, nil
+}
+
+// Board renders the board of s from the latest prices.
+func Board(p price.Price, s Subscription) string {
+	return fmt.Sprintf("ا📋 <b>تابلوی شخصی شما</b>\n%s\n\nا🔄 بروزرسانی هر %s | لغو: /unsubscribe",
+		p.Render(s.Assets), FormatInterval(s.Interval))
+}
+
+// FormatInterval renders d in Persian, in whole minutes when possible.
+func FormatInterval(d time.Duration) string {
+	if d%time.Minute == 0 {
+		return fmt.Sprintf("%d دقیقه", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%d ثانیه", int(d.Seconds()))
+}
+
+// Store keeps every subscription in memory, written through to the Boards
+// bucket of a state store on each change.
+type Store struct {
+	mu    sync.Mutex
+	state state.Store
+	users map[int64]Subscription
+}
+
+// Open loads the subscriptions kept in st.
+func Open(st state.Store) (*Store, error) {
+	s := &Store{state: st, users: make(map[int64]Subscription)}
+	keys, err := st.Keys(state.Boards)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		var sub Subscription
+		if _, err := st.Get(state.Boards, key, &sub); err != nil {
+			return nil, err
+		}
+		s.users[sub.UserID] = sub
+	}
+	return s, nil
+}
+
+// Get returns the subscription of userID, if any.
+func (s *Store) Get(userID int64) (Subscription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.users[userID]
+	return sub, ok
+}
+
+// All returns every subscription ordered by user ID.
+func (s *Store) All() []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscriptions := make([]Subscription, 0, len(s.users))
+	for _, sub := range s.users {
+		subscriptions = append(subscriptions, sub)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].UserID < subscriptions[j].UserID })
+	return subscriptions
+}
+
+// Put adds or replaces a subscription.
+func (s *Store) Put(sub Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.state.Put(state.Boards, strconv.FormatInt(sub.UserID, 10), sub); err != nil {
+		return err
+	}
+	s.users[sub.UserID] = sub
+	return nil
+}
+
+// Delete removes the subscription of userID.
+func (s *Store) Delete(userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.state.Delete(state.Boards, strconv.FormatInt(userID, 10)); err != nil {
+		return err
+	}
+	delete(s.users, userID)
+	return nil
+}
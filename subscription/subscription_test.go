@@ -0,0 +1,141 @@
+package subscription
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/state"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		args     []string
+		assets   []string
+		interval time.Duration
+		wantErr  bool
+	}{
+		{nil, DefaultAssets, DefaultInterval, false},
+		{[]string{"usd,sekee", "BTC"}, []string{"usd", "sekee", "btc"}, DefaultInterval, false},
+		{[]string{"2m", "usd,usd"}, []string{"usd"}, 2 * time.Minute, false},
+		{[]string{"usd", "10s"}, nil, 0, true},
+		{[]string{"doge"}, nil, 0, true},
+	}
+
+	for _, tt := range tests {
+		assets, interval, err := Parse(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%v) error = %v; wantErr %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(assets, tt.assets) || interval != tt.interval {
+			t.Errorf("Parse(%v) = %v, %s; want %v, %s", tt.args, assets, interval, tt.assets, tt.interval)
+		}
+	}
+}
+
+func TestBoard(t *testing.T) {
+	p := price.Price{Current: price.CurrentData{
+		Dollar:  price.Detail{Price: "82,000"},
+		Eur:     price.Detail{Price: "90,000"},
+		SekeE:   price.Detail{Price: "77,000,000"},
+		BitCoin: price.Detail{Price: "65,000"},
+	}}
+	board := Board(p, Subscription{Assets: []string{"usd", "sekee"}, Interval: 2 * time.Minute})
+
+	for _, expected := range []string{"تابلوی شخصی شما", "8,200", "7,700,000", "2 دقیقه"} {
+		if !strings.Contains(board, expected) {
+			t.Errorf("Expected board to contain %q, got %s", expected, board)
+		}
+	}
+	if strings.Contains(board, "9,000") || strings.Contains(board, "65,000") {
+		t.Errorf("Expected board to show only the subscribed assets, got %s", board)
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	st, _ := state.OpenFile(path)
+	s, err := Open(st)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	s.Put(Subscription{UserID: 42, ChatID: "42", Assets: []string{"usd"}, Interval: time.Minute, MessageID: 7})
+	s.Put(Subscription{UserID: 7, ChatID: "7", Assets: []string{"btc"}, Interval: 2 * time.Minute})
+
+	st.Close()
+	st, _ = state.OpenFile(path)
+	reloaded, err := Open(st)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	all := reloaded.All()
+	if len(all) != 2 || all[0].UserID != 7 || all[1].UserID != 42 {
+		t.Fatalf("Expected subscriptions of users 7 and 42 in order, got %+v", all)
+	}
+	if sub, ok := reloaded.Get(42); !ok || sub.MessageID != 7 || sub.Interval != time.Minute {
+		t.Errorf("Unexpected subscription after reload: %+v", sub)
+	}
+
+	if err := reloaded.Delete(42); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	st.Close()
+	st, _ = state.OpenFile(path)
+	reloaded, _ = Open(st)
+	if _, ok := reloaded.Get(42); ok {
+		t.Error("Expected subscription of user 42 to be deleted")
+	}
+}
+
+func TestPacer_Due(t *testing.T) {
+	now := time.Date(2025, 5, 6, 12, 0, 0, 0, time.UTC)
+	p := NewPacer(10)
+	p.Done(1, now.Add(-90*time.Second))
+	p.Done(2, now.Add(-5*time.Minute))
+	p.Done(3, now.Add(-10*time.Second))
+
+	subs := []Subscription{
+		{UserID: 1, MessageID: 1, Interval: time.Minute},
+		{UserID: 2, MessageID: 2, Interval: time.Minute},
+		{UserID: 3, MessageID: 3, Interval: time.Minute},
+		{UserID: 4, Interval: time.Minute},
+	}
+
+	var users []int64
+	for _, s := range p.Due(subs, now) {
+		users = append(users, s.UserID)
+	}
+	// the unposted board first, then the most overdue; 3 is not due yet
+	if !slices.Equal(users, []int64{4, 2, 1}) {
+		t.Errorf("Expected due users [4 2 1], got %v", users)
+	}
+
+	p.Forget(3)
+	if len(p.Due(subs, now)) != 4 {
+		t.Error("Expected a forgotten board to be due at once")
+	}
+}
+
+func TestPacer_Take(t *testing.T) {
+	now := time.Date(2025, 5, 6, 12, 0, 0, 0, time.UTC)
+	p := NewPacer(3)
+
+	taken := 0
+	for p.Take(now) {
+		taken++
+	}
+	if taken != 3 {
+		t.Errorf("Expected 3 edits from a full budget, got %d", taken)
+	}
+	if p.Take(now.Add(100 * time.Millisecond)) {
+		t.Error("Expected the budget not to refill within 100ms")
+	}
+	if !p.Take(now.Add(500 * time.Millisecond)) {
+		t.Error("Expected one edit to refill after 500ms")
+	}
+}
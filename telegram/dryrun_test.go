@@ -0,0 +1,82 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/onionj/pricebot/state"
+)
+
+func TestTelegram_DryRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected no request in a dry run, got %s", r.URL.Path)
+	}))
+	defer server.Close()
+	httpClient = server.Client()
+	baseURL = server.URL + "/bot%s%s"
+
+	store, _ := state.OpenFile("")
+	telegram := NewTelegramWithStore("123456:ABC-DEF", "test_chat_id", store)
+	var logs bytes.Buffer
+	telegram.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	telegram.SetDryRun(true)
+
+	ctx := context.Background()
+	if err := telegram.SendMessage(ctx, "Test message"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if telegram.LastMessageId == 0 {
+		t.Errorf("Expected a made-up message ID, got 0")
+	}
+	if err := telegram.UpdateMessage(ctx, "Edited", telegram.LastMessageId); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if _, err := telegram.SendPhoto(ctx, []byte("png"), "caption"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if updates, err := telegram.GetUpdates(ctx); err != nil || len(updates) != 0 {
+		t.Errorf("Expected no updates, got %v, %v", updates, err)
+	}
+
+	for _, expected := range []string{"method=/sendMessage", "Test message", "method=/editMessageText", "method=/sendPhoto"} {
+		if !strings.Contains(logs.String(), expected) {
+			t.Errorf("Expected the log to contain %q, got %s", expected, logs.String())
+		}
+	}
+	if strings.Contains(logs.String(), "123456:ABC-DEF") {
+		t.Errorf("Expected the token not to be logged, got %s", logs.String())
+	}
+}
+
+func TestTelegram_GetMe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bot123456:ABC-DEF/getMe":
+			w.Write([]byte(`{"ok": true, "result": {"id": 7, "first_name": "Price", "username": "pricebot"}}`))
+		default:
+			w.Write([]byte(`{"ok": false, "error_code": 401, "description": "Unauthorized"}`))
+		}
+	}))
+	defer server.Close()
+	httpClient = server.Client()
+	baseURL = server.URL + "/bot%s%s"
+
+	store, _ := state.OpenFile("")
+	me, err := NewTelegramWithStore("123456:ABC-DEF", "test_chat_id", store).GetMe(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if me.ID != 7 || me.Username != "pricebot" {
+		t.Errorf("Expected pricebot, got %+v", me)
+	}
+
+	_, err = NewTelegramWithStore("bad-token", "test_chat_id", store).GetMe(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("Expected an Unauthorized error, got %v", err)
+	}
+}
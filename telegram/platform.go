@@ -0,0 +1,152 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// ErrUnsupported is returned for requests the platform has no method for.
+var ErrUnsupported = errors.New("not supported on this platform")
+
+// Platform is a messenger serving a Telegram-like bot API.
+type Platform struct {
+	Name string
+	// BaseURL is formatted with the bot token and method, like
+	// "https://api.telegram.org/bot%s%s"
+	BaseURL string
+	// ParseMode is "HTML", "Markdown" or empty for plain text. Messages are
+	// written in Telegram HTML and converted to the platform's mode.
+	ParseMode string
+	Features  Features
+}
+
+// Features are the parts of the Telegram bot API a platform supports.
+type Features struct {
+	Edit                 bool // editMessageText
+	Photos               bool // sendPhoto
+	Documents            bool // sendDocument
+	Updates              bool // getUpdates
+	Blockquote           bool
+	ExpandableBlockquote bool
+}
+
+// TelegramPlatform is Telegram itself, with every feature.
+func TelegramPlatform() Platform {
+	return Platform{
+		Name:      "telegram",
+		BaseURL:   baseURL,
+		ParseMode: "HTML",
+		Features:  Features{Edit: true, Photos: true, Documents: true, Updates: true, Blockquote: true, ExpandableBlockquote: true},
+	}
+}
+
+// Bale's bot API follows Telegram's, but formats messages with Markdown
+// only and has no blockquotes.
+var Bale = Platform{
+	Name:      "bale",
+	BaseURL:   "https://tapi.bale.ai/bot%s%s",
+	ParseMode: "Markdown",
+	Features:  Features{Edit: true, Photos: true, Documents: true, Updates: true},
+}
+
+// Eitaa's bot service (eitaayar) only sends plain text messages and cannot
+// edit them, so its live message is replaced by a new post instead.
+var Eitaa = Platform{
+	Name:    "eitaa",
+	BaseURL: "https://eitaayar.ir/api/%s%s",
+}
+
+// FindPlatform returns the platform named name.
+func FindPlatform(name string) (Platform, error) {
+	switch name {
+	case "telegram":
+		return TelegramPlatform(), nil
+	case "bale":
+		return Bale, nil
+	case "eitaa":
+		return Eitaa, nil
+	}
+	return Platform{}, fmt.Errorf("unknown platform %q", name)
+}
+
+var (
+	linkTag = regexp.MustCompile(`(?s)<a href="([^"]*)">(.*?)</a>`)
+	anyTag  = regexp.MustCompile(`<[^>]+>`)
+	// markdownEntity matches the tags Markdown has entities for: bold, with
+	// its text in group 1, and links, with the URL and text in groups 2 and 3
+	markdownEntity = regexp.MustCompile(`(?s)<b>(.*?)</b>|<a href="([^"]*)">(.*?)</a>`)
+	// markdownEscaper escapes the characters that open an entity
+	markdownEscaper = strings.NewReplacer("_", `\_`, "*", `\*`, "`", "\\`", "[", `\[`)
+)
+
+// Adapt converts msg, written in Telegram HTML, to what the platform can show.
+func (p Platform) Adapt(msg string) string {
+	if !p.Features.ExpandableBlockquote {
+		msg = strings.ReplaceAll(msg, "<blockquote expandable>", "<blockquote>")
+	}
+	if !p.Features.Blockquote {
+		msg = strings.ReplaceAll(msg, "<blockquote>", "")
+		msg = strings.ReplaceAll(msg, "</blockquote>", "")
+	}
+
+	switch p.ParseMode {
+	case "HTML":
+		return msg
+	case "Markdown":
+		return toMarkdown(msg)
+	default:
+		msg = linkTag.ReplaceAllString(msg, "$2: $1")
+	}
+	return plainText(msg)
+}
+
+// toMarkdown converts msg to the legacy Markdown Bale parses: bold and links
+// become entities, and everywhere else the characters that would open one,
+// as in a channel name like @price_bot, are escaped. Entities can't escape
+// their own delimiter, so bold text holding a "*" and link text holding a
+// "]" are left plain.
+func toMarkdown(msg string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range markdownEntity.FindAllStringSubmatchIndex(msg, -1) {
+		b.WriteString(markdownEscaper.Replace(plainText(msg[last:m[0]])))
+		last = m[1]
+
+		if m[2] >= 0 {
+			text := plainText(msg[m[2]:m[3]])
+			if text == "" || strings.Contains(text, "*") {
+				b.WriteString(markdownEscaper.Replace(text))
+			} else {
+				b.WriteString("*" + text + "*")
+			}
+			continue
+		}
+		url := html.UnescapeString(msg[m[4]:m[5]])
+		text := plainText(msg[m[6]:m[7]])
+		if text == "" || strings.Contains(text, "]") {
+			b.WriteString(markdownEscaper.Replace(text + ": " + url))
+		} else {
+			// a ")" would end the URL early
+			b.WriteString("[" + text + "](" + strings.ReplaceAll(url, ")", "%29") + ")")
+		}
+	}
+	b.WriteString(markdownEscaper.Replace(plainText(msg[last:])))
+	return b.String()
+}
+
+// plainText strips the tags from HTML and unescapes it.
+func plainText(s string) string {
+	return html.UnescapeString(anyTag.ReplaceAllString(s, ""))
+}
+
+// stateKey is the state store key of chatID, which is the plain chat ID on
+// Telegram and prefixed with the platform name elsewhere.
+func (p Platform) stateKey(chatID string) string {
+	if p.Name == "telegram" {
+		return chatID
+	}
+	return p.Name + ":" + chatID
+}
@@ -0,0 +1,107 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/onionj/pricebot/state"
+)
+
+func TestPlatform_Adapt(t *testing.T) {
+	msg := `<blockquote expandable>ا🇺🇸 دلار امریکا <b>82,150</b> تومان</blockquote>
+<a href="https://t.me/proxy?server=a&amp;port=1">ا🗝 پروکسی</a> &lt;3`
+
+	tests := []struct {
+		name     string
+		platform Platform
+		expected string
+	}{
+		{"telegram", TelegramPlatform(), msg},
+		{"no expandable", Platform{ParseMode: "HTML", Features: Features{Blockquote: true}},
+			`<blockquote>ا🇺🇸 دلار امریکا <b>82,150</b> تومان</blockquote>
+<a href="https://t.me/proxy?server=a&amp;port=1">ا🗝 پروکسی</a> &lt;3`},
+		{"markdown", Bale,
+			`ا🇺🇸 دلار امریکا *82,150* تومان
+[ا🗝 پروکسی](https://t.me/proxy?server=a&port=1) <3`},
+		{"plain", Eitaa,
+			`ا🇺🇸 دلار امریکا 82,150 تومان
+ا🗝 پروکسی: https://t.me/proxy?server=a&port=1 <3`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.platform.Adapt(msg); got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestPlatform_AdaptMarkdown(t *testing.T) {
+	tests := []struct {
+		msg, expected string
+	}{
+		{"ا📢 @price_bot", `ا📢 @price\_bot`},
+		{"<b>@price_bot</b> 2*3 `x` [1]", "*@price_bot* 2\\*3 \\`x\\` \\[1]"},
+		{`<a href="https://t.me/proxy?server=a&amp;port=1&amp;secret=ee_ab">پروکسی_ما</a>`,
+			"[پروکسی_ما](https://t.me/proxy?server=a&port=1&secret=ee_ab)"},
+		{"https://t.me/proxy?secret=ee_ab", `https://t.me/proxy?secret=ee\_ab`},
+		// entities can't hold their own delimiter
+		{"<b>2*3</b>", `2\*3`},
+		{`<a href="https://a.b/(c)">منبع</a>`, "[منبع](https://a.b/(c%29)"},
+		{`<a href="https://a.b/(c)">[1]</a>`, `\[1]: https://a.b/(c)`},
+	}
+
+	for _, tt := range tests {
+		if got := Bale.Adapt(tt.msg); got != tt.expected {
+			t.Errorf("Adapt(%q): expected %q, got %q", tt.msg, tt.expected, got)
+		}
+	}
+}
+
+func TestTelegram_Platform(t *testing.T) {
+	var paths []string
+	var payloads []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+		w.Write([]byte(`{"ok": true, "result": {"message_id": 9}}`))
+	}))
+	defer server.Close()
+	httpClient = server.Client()
+
+	store, _ := state.OpenFile("")
+	platform := Eitaa
+	platform.BaseURL = server.URL + "/api/%s%s"
+	eitaa := NewTelegramOn(platform, "eitaa-token", "prices", store)
+
+	if err := eitaa.SendMessage(context.Background(), "<b>82,150</b> تومان"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if paths[0] != "/api/eitaa-token/sendMessage" {
+		t.Errorf("Expected path '/api/eitaa-token/sendMessage', got %s", paths[0])
+	}
+	if _, ok := payloads[0]["parse_mode"]; ok || payloads[0]["text"] != "82,150 تومان" {
+		t.Errorf("Expected plain text without a parse mode, got %v", payloads[0])
+	}
+
+	if err := eitaa.UpdateMessage(context.Background(), "x", 9); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported for an edit, got %v", err)
+	}
+	if len(paths) != 1 {
+		t.Errorf("Expected no request for an unsupported edit, got %v", paths)
+	}
+
+	// mirrors keep their state apart from the Telegram channel's
+	var msg state.Message
+	if ok, _ := store.Get(state.Messages, "eitaa:prices", &msg); !ok || msg.MessageID != 9 {
+		t.Errorf("Expected message 9 under 'eitaa:prices', got %+v", msg)
+	}
+	if ok, _ := store.Get(state.Messages, "prices", &msg); ok {
+		t.Error("Expected no state under the bare chat ID")
+	}
+}
@@ -3,6 +3,7 @@ package telegram
 import (
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -44,6 +45,7 @@ func TestLoadState_Success(t *testing.T) {
 func TestLoadState_FileNotExists(t *testing.T) {
 	// Set non-existent file
 	stateFile = "nonexistent_state.json"
+	defer os.Remove(stateFile) // created when the schema version is recorded
 
 	// Create new telegram instance - should not panic
 	telegram := NewTelegram("test_token", "test_chat")
@@ -126,17 +128,8 @@ func TestSaveState_Success(t *testing.T) {
 		t.Fatalf("Failed to save state: %v", err)
 	}
 
-	// Read saved file
-	data, err := os.ReadFile(tmpStateFile)
-	if err != nil {
-		t.Fatalf("Failed to read saved file: %v", err)
-	}
-
-	// Parse saved data
-	var savedState Telegram
-	if err := json.Unmarshal(data, &savedState); err != nil {
-		t.Fatalf("Failed to parse saved state: %v", err)
-	}
+	// Reload the saved state
+	savedState := NewTelegram("test_token", "test_chat")
 
 	// Verify saved state
 	if savedState.LastMessageId != telegram.LastMessageId {
@@ -145,4 +138,13 @@ func TestSaveState_Success(t *testing.T) {
 	if savedState.LastMessageTime != telegram.LastMessageTime {
 		t.Errorf("Expected saved LastMessageTime %d, got %d", telegram.LastMessageTime, savedState.LastMessageTime)
 	}
+
+	// The file holds the store document, not the legacy flat state
+	data, err := os.ReadFile(tmpStateFile)
+	if err != nil {
+		t.Fatalf("Failed to read saved file: %v", err)
+	}
+	if !strings.Contains(string(data), `"buckets"`) {
+		t.Errorf("Expected the state file in the store format, got %s", data)
+	}
 }
@@ -2,25 +2,52 @@ package telegram
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
-	"os"
+	"net/url"
+	"strings"
+	"sync/atomic"
 	"time"
+
+	"github.com/onionj/pricebot/state"
 )
 
 // Make these package variables so they can be modified in tests
 var (
-	stateFile  = "telegram_state.json"
-	httpClient = &http.Client{}
-	baseURL    = "https://api.telegram.org/bot%s%s"
+	stateFile      = "telegram_state.json" // used by NewTelegram
+	httpClient     = &http.Client{}
+	baseURL        = "https://api.telegram.org/bot%s%s"
+	requestTimeout = 15 * time.Second
 )
 
 type Telegram struct {
 	botToken        string
 	chatID          string
+	logger          *slog.Logger
 	LastMessageId   int   `json:"last_message_id"`
 	LastMessageTime int64 `json:"last_message_time"`
+	// LastDailyReport is the Jalali date of the last posted daily summary
+	LastDailyReport string `json:"last_daily_report,omitempty"`
+	// LastWeeklyReport and LastMonthlyReport are the first days of the last reported periods
+	LastWeeklyReport  string `json:"last_weekly_report,omitempty"`
+	LastMonthlyReport string `json:"last_monthly_report,omitempty"`
+	// UpdateOffset is the ID after the last handled bot update. Only the
+	// goroutine polling updates uses it, and only Acknowledge saves it
+	UpdateOffset int `json:"update_offset,omitempty"`
+
+	store    state.Store
+	platform Platform
+
+	// dryRun logs requests instead of sending them, see SetDryRun; the
+	// calls are counted atomically as updates are polled beside the jobs
+	dryRun      bool
+	dryRunCalls atomic.Int64
 }
 
 type messageResponse struct {
@@ -32,104 +59,350 @@ type messageResponse struct {
 	ErrCode     int    `json:"error_code"`
 }
 
-// NewTelegram initializes a Telegram bot and loads state from a file
+// NewTelegram initializes a Telegram bot with its state in the file store at
+// stateFile, importing a legacy state file found there.
 func NewTelegram(botToken, chatID string) *Telegram {
-	t := &Telegram{botToken: botToken, chatID: chatID}
+	store, err := state.OpenFile(stateFile)
+	if err == nil {
+		err = state.Migrate(store, state.Schema(stateFile, chatID))
+	}
+	if err != nil {
+		slog.Warn("could not open state, keeping it in memory", "file", stateFile, "error", err)
+		store, _ = state.OpenFile("")
+	}
+	return NewTelegramWithStore(botToken, chatID, store)
+}
+
+// NewTelegramWithStore initializes a Telegram bot and loads its state from store.
+func NewTelegramWithStore(botToken, chatID string, store state.Store) *Telegram {
+	return NewTelegramOn(TelegramPlatform(), botToken, chatID, store)
+}
+
+// NewTelegramOn initializes a bot on platform, such as Bale, and loads its
+// state from store.
+func NewTelegramOn(platform Platform, botToken, chatID string, store state.Store) *Telegram {
+	t := &Telegram{botToken: botToken, chatID: chatID, store: store, platform: platform}
+	t.SetLogger(slog.Default())
 
-	// Load state from file
 	if err := t.loadState(); err != nil {
-		fmt.Println("Warning: Could not load state,", err)
+		t.logger.Warn("could not load state", "error", err)
 	}
 
 	return t
 }
 
-// Save state to file
+// SetLogger replaces the logger used for API diagnostics
+func (t *Telegram) SetLogger(logger *slog.Logger) {
+	t.logger = logger.With("chat_id", t.chatID)
+	if t.platform.Name != "telegram" {
+		t.logger = t.logger.With("platform", t.platform.Name)
+	}
+}
+
+// Platform returns the platform the bot runs on.
+func (t *Telegram) Platform() Platform {
+	return t.platform
+}
+
+// format adds the message text and parse mode to payload, adapted to the platform.
+func (t *Telegram) format(payload map[string]any, field, msg string) map[string]any {
+	payload[field] = t.platform.Adapt(msg)
+	if t.platform.ParseMode != "" {
+		payload["parse_mode"] = t.platform.ParseMode
+	}
+	return payload
+}
+
+// unsupported is the error for a request the platform lacks.
+func (t *Telegram) unsupported(action string) error {
+	return fmt.Errorf("%s on %s: %w", action, t.platform.Name, ErrUnsupported)
+}
+
+// Save the live message and report markers to the store. The update offset
+// is saved by Acknowledge alone, since updates are polled beside the jobs
+// that save the rest.
 func (t *Telegram) saveState() error {
-	data, err := json.Marshal(t)
-	if err != nil {
-		return err
+	msg := state.Message{MessageID: t.LastMessageId}
+	if t.LastMessageTime > 0 {
+		msg.PostedAt = time.Unix(t.LastMessageTime, 0)
+	}
+	chat := state.Chat{
+		LastDailyReport:   t.LastDailyReport,
+		LastWeeklyReport:  t.LastWeeklyReport,
+		LastMonthlyReport: t.LastMonthlyReport,
+	}
+	return errors.Join(
+		t.store.Put(state.Messages, t.platform.stateKey(t.chatID), msg),
+		t.store.Put(state.Chats, t.platform.stateKey(t.chatID), chat),
+	)
+}
+
+func (t *Telegram) offsetKey() string {
+	if t.platform.Name == "telegram" {
+		return state.TelegramOffset
 	}
-	return os.WriteFile(stateFile, data, 0644)
+	return t.platform.Name
 }
 
-// Load state from file
+// Load state from the store
 func (t *Telegram) loadState() error {
-	data, err := os.ReadFile(stateFile)
+	var msg state.Message
+	var chat state.Chat
+	_, msgErr := t.store.Get(state.Messages, t.platform.stateKey(t.chatID), &msg)
+	_, chatErr := t.store.Get(state.Chats, t.platform.stateKey(t.chatID), &chat)
+	_, offsetErr := t.store.Get(state.Offsets, t.offsetKey(), &t.UpdateOffset)
+
+	t.LastMessageId = msg.MessageID
+	if !msg.PostedAt.IsZero() {
+		t.LastMessageTime = msg.PostedAt.Unix()
+	}
+	t.LastDailyReport = chat.LastDailyReport
+	t.LastWeeklyReport = chat.LastWeeklyReport
+	t.LastMonthlyReport = chat.LastMonthlyReport
+	return errors.Join(msgErr, chatErr, offsetErr)
+}
+
+// SendMessage posts msg as a new message and remembers it as the last message.
+func (t *Telegram) SendMessage(ctx context.Context, msg string) error {
+	messageId, err := t.Post(ctx, msg)
 	if err != nil {
 		return err
 	}
 
-	if err := json.Unmarshal(data, t); err != nil {
+	t.LastMessageId = messageId
+	t.LastMessageTime = time.Now().Unix()
+	return t.saveState()
+}
+
+// Post sends msg as a standalone message, such as a report, without
+// touching the last message state. It returns the new message ID.
+func (t *Telegram) Post(ctx context.Context, msg string) (int, error) {
+	return t.PostTo(ctx, t.chatID, msg)
+}
+
+// PostTo sends msg to chatID, such as a reply to a user, and returns the
+// new message ID.
+func (t *Telegram) PostTo(ctx context.Context, chatID string, msg string) (int, error) {
+	payload := t.format(map[string]any{"chat_id": chatID}, "text", msg)
+
+	start := time.Now()
+	response, err := t.post(ctx, "/sendMessage", payload)
+	if err != nil {
+		return 0, err
+	}
+
+	if !response.OK {
+		t.logger.Warn("send message rejected", "to", chatID,
+			"error_code", response.ErrCode, "description", response.Description, "latency", time.Since(start))
+		return 0, &APIError{Action: "send message", Code: response.ErrCode, Description: response.Description}
+	}
+
+	t.logger.Info("message sent", "to", chatID,
+		"message_id", response.Result.MessageID, "latency", time.Since(start))
+	return response.Result.MessageID, nil
+}
+
+// UpdateMessage replaces the text of messageId with msg.
+func (t *Telegram) UpdateMessage(ctx context.Context, msg string, messageId int) error {
+	return t.UpdateMessageIn(ctx, t.chatID, msg, messageId)
+}
+
+// UpdateMessageIn replaces the text of messageId in chatID with msg.
+func (t *Telegram) UpdateMessageIn(ctx context.Context, chatID string, msg string, messageId int) error {
+	if !t.platform.Features.Edit {
+		return t.unsupported("update message")
+	}
+	payload := t.format(map[string]any{"chat_id": chatID, "message_id": messageId}, "text", msg)
+
+	start := time.Now()
+	response, err := t.post(ctx, "/editMessageText", payload)
+	if err != nil {
 		return err
 	}
 
+	if !response.OK {
+		t.logger.Warn("update message rejected", "to", chatID,
+			"message_id", messageId, "error_code", response.ErrCode, "description", response.Description, "latency", time.Since(start))
+		return &APIError{Action: "update message", Code: response.ErrCode, Description: response.Description}
+	}
+
+	t.logger.Debug("message updated", "to", chatID, "message_id", messageId, "latency", time.Since(start))
 	return nil
 }
 
-func (t *Telegram) SendMessage(msg string) error {
-	payload := map[string]string{
-		"chat_id":    t.chatID,
-		"text":       msg,
-		"parse_mode": "HTML",
+// SendPhoto uploads a PNG image with an HTML caption as a standalone message.
+func (t *Telegram) SendPhoto(ctx context.Context, image []byte, caption string) (int, error) {
+	if !t.platform.Features.Photos {
+		return 0, t.unsupported("send photo")
 	}
-	body, err := json.Marshal(payload)
+	start := time.Now()
+	response, err := t.upload(ctx, "/sendPhoto", t.format(map[string]any{"chat_id": t.chatID}, "caption", caption),
+		"photo", "chart.png", image)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	resp, err := httpClient.Post(
-		fmt.Sprintf(baseURL, t.botToken, "/sendMessage"),
-		"application/json", bytes.NewBuffer(body))
+	if !response.OK {
+		t.logger.Warn("send photo rejected",
+			"error_code", response.ErrCode, "description", response.Description, "latency", time.Since(start))
+		return 0, &APIError{Action: "send photo", Code: response.ErrCode, Description: response.Description}
+	}
+
+	t.logger.Info("photo sent",
+		"message_id", response.Result.MessageID, "latency", time.Since(start))
+	return response.Result.MessageID, nil
+}
 
+// SendDocumentTo uploads data as a file named fileName to chatID, such as
+// an export requested by a user.
+func (t *Telegram) SendDocumentTo(ctx context.Context, chatID, fileName string, data []byte, caption string) (int, error) {
+	if !t.platform.Features.Documents {
+		return 0, t.unsupported("send document")
+	}
+	start := time.Now()
+	response, err := t.upload(ctx, "/sendDocument", t.format(map[string]any{"chat_id": chatID}, "caption", caption),
+		"document", fileName, data)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer resp.Body.Close()
 
-	var response messageResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return err
+	if !response.OK {
+		t.logger.Warn("send document rejected", "to", chatID,
+			"error_code", response.ErrCode, "description", response.Description, "latency", time.Since(start))
+		return 0, &APIError{Action: "send document", Code: response.ErrCode, Description: response.Description}
 	}
 
+	t.logger.Info("document sent", "to", chatID,
+		"message_id", response.Result.MessageID, "latency", time.Since(start))
+	return response.Result.MessageID, nil
+}
+
+// GetMe returns the bot the token belongs to, which checks the token.
+func (t *Telegram) GetMe(ctx context.Context) (User, error) {
+	var response struct {
+		OK          bool   `json:"ok"`
+		Result      User   `json:"result"`
+		Description string `json:"description"`
+		ErrCode     int    `json:"error_code"`
+	}
+	if err := t.call(ctx, "/getMe", map[string]any{}, &response); err != nil {
+		return User{}, err
+	}
 	if !response.OK {
-		return fmt.Errorf("failed to send message: code:%d, description:%s", response.ErrCode, response.Description)
+		return User{}, &APIError{Action: "get me", Code: response.ErrCode, Description: response.Description}
 	}
+	return response.Result, nil
+}
 
-	t.LastMessageId = response.Result.MessageID
-	t.LastMessageTime = time.Now().Unix()
+// SaveState flushes the last message state to disk.
+func (t *Telegram) SaveState() error {
 	return t.saveState()
 }
 
-func (t *Telegram) UpdateMessage(msg string, messageId int) error {
-	payload := map[string]interface{}{
-		"chat_id":    t.chatID,
-		"message_id": messageId,
-		"text":       msg,
-		"parse_mode": "HTML",
+// post calls a bot API method with a JSON payload.
+func (t *Telegram) post(ctx context.Context, method string, payload any) (*messageResponse, error) {
+	var response messageResponse
+	if err := t.call(ctx, method, payload, &response); err != nil {
+		return nil, err
 	}
+	return &response, nil
+}
+
+// call sends a JSON payload to a bot API method and decodes the response into out.
+func (t *Telegram) call(ctx context.Context, method string, payload any, out any) error {
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
-	resp, err := httpClient.Post(
-		fmt.Sprintf(baseURL, t.botToken, "/editMessageText"),
-		"application/json", bytes.NewBuffer(body))
+	return t.do(ctx, method, "application/json", bytes.NewBuffer(body), out)
+}
+
+// do sends a request body to a bot API method, bounded by ctx and
+// requestTimeout, and decodes the response into out.
+func (t *Telegram) do(ctx context.Context, method, contentType string, body io.Reader, out any) error {
+	if t.dryRun {
+		return t.dryRunCall(method, contentType, body, out)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
 
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf(t.platform.BaseURL, t.botToken, method), body)
 	if err != nil {
-		return err
+		return redactURLError(err)
 	}
+	req.Header.Set("Content-Type", contentType)
 
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return redactURLError(err)
+	}
 	defer resp.Body.Close()
 
-	var response messageResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return err
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// upload calls a bot API method with a multipart form holding fields and one file.
+func (t *Telegram) upload(ctx context.Context, method string, fields map[string]any, fileField, fileName string, data []byte) (*messageResponse, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for key, value := range fields {
+		if err := form.WriteField(key, fmt.Sprint(value)); err != nil {
+			return nil, err
+		}
+	}
+	part, err := form.CreateFormFile(fileField, fileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
 	}
 
-	if !response.OK {
-		return fmt.Errorf("failed to update message: code:%d, description:%s", response.ErrCode, response.Description)
+	var response messageResponse
+	if err := t.do(ctx, method, form.FormDataContentType(), &body, &response); err != nil {
+		return nil, err
 	}
+	return &response, nil
+}
 
-	return nil
+// APIError is a request the Bot API rejected.
+type APIError struct {
+	Action      string // what failed, like "send message"
+	Code        int
+	Description string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("failed to %s: code:%d, description:%s", e.Action, e.Code, e.Description)
+}
+
+// IsNotModified reports whether err rejected an edit that would not change the message.
+func IsNotModified(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && strings.Contains(apiErr.Description, "message is not modified")
+}
+
+// IsMessageGone reports whether err rejected an edit of a deleted message.
+func IsMessageGone(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && strings.Contains(apiErr.Description, "message to edit not found")
+}
+
+// IsBlocked reports whether err means the user blocked the bot or the chat is gone.
+func IsBlocked(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden
+}
+
+// redactURLError strips the request URL, which embeds the bot token, from
+// transport errors so they can be shown or stored safely.
+func redactURLError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return &url.Error{Op: urlErr.Op, URL: "[REDACTED]", Err: urlErr.Err}
+	}
+	return err
 }
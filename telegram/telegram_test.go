@@ -1,11 +1,15 @@
 package telegram
 
 import (
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 // Define request body struct for better type safety
@@ -72,7 +76,7 @@ func TestTelegram_SendMessage(t *testing.T) {
 	telegram := NewTelegram("123456:ABC-DEF", "test_chat_id")
 
 	// Test SendMessage
-	err := telegram.SendMessage("Test message")
+	err := telegram.SendMessage(context.Background(), "Test message")
 	if err != nil {
 		t.Errorf("SendMessage failed: %v", err)
 	}
@@ -136,7 +140,7 @@ func TestTelegram_UpdateMessage(t *testing.T) {
 	telegram := NewTelegram("123456:ABC-DEF", "test_chat_id")
 
 	// Test UpdateMessage
-	err := telegram.UpdateMessage("Updated message", 123)
+	err := telegram.UpdateMessage(context.Background(), "Updated message", 123)
 	if err != nil {
 		t.Errorf("UpdateMessage failed: %v", err)
 	}
@@ -176,3 +180,126 @@ func TestTelegram_StateManagement(t *testing.T) {
 		t.Errorf("Expected LastMessageTime to be 1234567890, got %d", telegram.LastMessageTime)
 	}
 }
+
+func TestTelegram_RequestTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	originalTimeout := requestTimeout
+	defer func() { requestTimeout = originalTimeout }()
+
+	tmpStateFile := "test_state.json"
+	defer os.Remove(tmpStateFile)
+
+	stateFile = tmpStateFile
+	httpClient = server.Client()
+	baseURL = server.URL + "/bot%s%s"
+	requestTimeout = 50 * time.Millisecond
+
+	telegram := NewTelegram("123456:ABC-DEF", "test_chat_id")
+
+	err := telegram.UpdateMessage(context.Background(), "Updated message", 123)
+	if err == nil {
+		t.Fatal("Expected UpdateMessage to fail on a hung server")
+	}
+	if strings.Contains(err.Error(), "123456:ABC-DEF") {
+		t.Errorf("Expected error without bot token, got %v", err)
+	}
+}
+
+func TestTelegram_SendPhoto(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123456:ABC-DEF/sendPhoto" {
+			t.Errorf("Expected path '/bot123456:ABC-DEF/sendPhoto', got %s", r.URL.Path)
+		}
+
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("Failed to parse multipart form: %v", err)
+		}
+		if r.FormValue("chat_id") != "test_chat_id" {
+			t.Errorf("Expected chat_id 'test_chat_id', got '%s'", r.FormValue("chat_id"))
+		}
+		if r.FormValue("caption") != "Weekly chart" {
+			t.Errorf("Expected caption 'Weekly chart', got '%s'", r.FormValue("caption"))
+		}
+
+		file, _, err := r.FormFile("photo")
+		if err != nil {
+			t.Fatalf("Expected photo file: %v", err)
+		}
+		defer file.Close()
+		data, _ := io.ReadAll(file)
+		if string(data) != "png-bytes" {
+			t.Errorf("Unexpected photo content %q", data)
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": map[string]int{"message_id": 77}})
+	}))
+	defer server.Close()
+
+	tmpStateFile := "test_state.json"
+	defer os.Remove(tmpStateFile)
+
+	stateFile = tmpStateFile
+	httpClient = server.Client()
+	baseURL = server.URL + "/bot%s%s"
+
+	telegram := NewTelegram("123456:ABC-DEF", "test_chat_id")
+
+	messageId, err := telegram.SendPhoto(context.Background(), []byte("png-bytes"), "Weekly chart")
+	if err != nil {
+		t.Fatalf("SendPhoto failed: %v", err)
+	}
+	if messageId != 77 {
+		t.Errorf("Expected message ID 77, got %d", messageId)
+	}
+	if telegram.LastMessageId != 0 {
+		t.Errorf("Expected SendPhoto to leave LastMessageId untouched, got %d", telegram.LastMessageId)
+	}
+}
+
+func TestTelegram_UpdateMessageErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    string
+		notModified bool
+		messageGone bool
+		blocked     bool
+	}{
+		{"not modified", `{"ok": false, "error_code": 400, "description": "Bad Request: message is not modified: specified new message content and reply markup are exactly the same"}`, true, false, false},
+		{"deleted", `{"ok": false, "error_code": 400, "description": "Bad Request: message to edit not found"}`, false, true, false},
+		{"blocked", `{"ok": false, "error_code": 403, "description": "Forbidden: bot was blocked by the user"}`, false, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			tmpStateFile := "test_state.json"
+			defer os.Remove(tmpStateFile)
+
+			stateFile = tmpStateFile
+			httpClient = server.Client()
+			baseURL = server.URL + "/bot%s%s"
+
+			telegram := NewTelegram("123456:ABC-DEF", "test_chat_id")
+			err := telegram.UpdateMessageIn(context.Background(), "42", "board", 7)
+			if err == nil {
+				t.Fatal("Expected an error, got nil")
+			}
+			if IsNotModified(err) != tt.notModified || IsMessageGone(err) != tt.messageGone || IsBlocked(err) != tt.blocked {
+				t.Errorf("Unexpected classification of %v", err)
+			}
+		})
+	}
+}
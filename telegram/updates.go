@@ -0,0 +1,94 @@
+package telegram
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onionj/pricebot/state"
+)
+
+// pollTimeout is how long getUpdates waits for new updates; it must stay
+// below requestTimeout.
+var pollTimeout = 10 * time.Second
+
+// Update is an incoming bot update. Only messages are requested.
+type Update struct {
+	UpdateID int      `json:"update_id"`
+	Message  *Message `json:"message"`
+}
+
+type Message struct {
+	MessageID int    `json:"message_id"`
+	From      *User  `json:"from"`
+	Chat      Chat   `json:"chat"`
+	Date      int64  `json:"date"`
+	Text      string `json:"text"`
+}
+
+type User struct {
+	ID        int64  `json:"id"`
+	FirstName string `json:"first_name"`
+	Username  string `json:"username"`
+}
+
+type Chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"` // private, group, supergroup or channel
+}
+
+// ChatID returns the chat ID in the form the send methods take.
+func (c Chat) ChatID() string {
+	return strconv.FormatInt(c.ID, 10)
+}
+
+// Command splits a message like "/hold@pricebot 2 sekee" into its command,
+// without the leading slash and bot name, and arguments. ok is false when
+// the message is not a command.
+func (m Message) Command() (command string, args []string, ok bool) {
+	fields := strings.Fields(m.Text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", nil, false
+	}
+	command, _, _ = strings.Cut(strings.TrimPrefix(fields[0], "/"), "@")
+	return strings.ToLower(command), fields[1:], true
+}
+
+type updatesResponse struct {
+	OK          bool     `json:"ok"`
+	Result      []Update `json:"result"`
+	Description string   `json:"description"`
+	ErrCode     int      `json:"error_code"`
+}
+
+// GetUpdates long-polls for updates after UpdateOffset. Handled updates are
+// acknowledged by advancing UpdateOffset, which is saved with the state.
+func (t *Telegram) GetUpdates(ctx context.Context) ([]Update, error) {
+	if !t.platform.Features.Updates {
+		return nil, t.unsupported("get updates")
+	}
+	payload := map[string]any{
+		"offset":          t.UpdateOffset,
+		"timeout":         int(pollTimeout.Seconds()),
+		"allowed_updates": []string{"message"},
+	}
+
+	var response updatesResponse
+	if err := t.call(ctx, "/getUpdates", payload, &response); err != nil {
+		return nil, err
+	}
+	if !response.OK {
+		return nil, &APIError{Action: "get updates", Code: response.ErrCode, Description: response.Description}
+	}
+	return response.Result, nil
+}
+
+// Acknowledge marks every update up to u as handled.
+func (t *Telegram) Acknowledge(u Update) error {
+	if u.UpdateID < t.UpdateOffset {
+		return nil
+	}
+	t.UpdateOffset = u.UpdateID + 1
+	return t.store.Put(state.Offsets, t.offsetKey(), t.UpdateOffset)
+}
@@ -0,0 +1,79 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestTelegram_GetUpdates(t *testing.T) {
+	var offsets []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123456:ABC-DEF/getUpdates" {
+			t.Errorf("Expected path '/bot123456:ABC-DEF/getUpdates', got %s", r.URL.Path)
+		}
+		var body struct {
+			Offset int `json:"offset"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		offsets = append(offsets, body.Offset)
+
+		w.Write([]byte(`{"ok": true, "result": [
+			{"update_id": 10, "message": {"message_id": 1, "from": {"id": 42}, "chat": {"id": 42, "type": "private"}, "text": "/hold@pricebot 2 sekee"}},
+			{"update_id": 11, "message": {"message_id": 2, "from": {"id": 42}, "chat": {"id": -100, "type": "group"}, "text": "hello"}}
+		]}`))
+	}))
+	defer server.Close()
+
+	tmpStateFile := "test_state.json"
+	defer os.Remove(tmpStateFile)
+
+	stateFile = tmpStateFile
+	httpClient = server.Client()
+	baseURL = server.URL + "/bot%s%s"
+
+	telegram := NewTelegram("123456:ABC-DEF", "test_chat_id")
+	updates, err := telegram.GetUpdates(context.Background())
+	if err != nil {
+		t.Fatalf("GetUpdates failed: %v", err)
+	}
+	if len(updates) != 2 {
+		t.Fatalf("Expected 2 updates, got %d", len(updates))
+	}
+
+	command, args, ok := updates[0].Message.Command()
+	if !ok || command != "hold" || len(args) != 2 || args[0] != "2" || args[1] != "sekee" {
+		t.Errorf("Command() = %q, %v, %v", command, args, ok)
+	}
+	if updates[0].Message.Chat.ChatID() != "42" {
+		t.Errorf("Expected chat ID '42', got '%s'", updates[0].Message.Chat.ChatID())
+	}
+	if _, _, ok := updates[1].Message.Command(); ok {
+		t.Error("Expected plain text not to be a command")
+	}
+
+	for _, u := range updates {
+		if err := telegram.Acknowledge(u); err != nil {
+			t.Fatalf("Acknowledge failed: %v", err)
+		}
+	}
+	telegram.GetUpdates(context.Background())
+	if len(offsets) != 2 || offsets[0] != 0 || offsets[1] != 12 {
+		t.Errorf("Expected offsets [0 12], got %v", offsets)
+	}
+
+	// a job saving the rest of the state leaves the offset alone
+	telegram.UpdateOffset = 5
+	if err := telegram.SaveState(); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+
+	// the offset survives a restart
+	reloaded := NewTelegram("123456:ABC-DEF", "test_chat_id")
+	if reloaded.UpdateOffset != 12 {
+		t.Errorf("Expected UpdateOffset 12 after reload, got %d", reloaded.UpdateOffset)
+	}
+}
@@ -0,0 +1,315 @@
+// Package tui is a full-screen terminal dashboard of live prices: a color
+// coded table with each asset's change direction and a sparkline of its
+// recent history, filtered by asset group from the keyboard, with a
+// countdown to the next refresh.
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/price"
+	"github.com/onionj/pricebot/utils"
+)
+
+// Groups are the asset groups in the order of their keys, 1 to 4; 0 shows
+// every group.
+var Groups = []price.Group{price.GroupCurrency, price.GroupCrypto, price.GroupCoin, price.GroupGold}
+
+// ANSI colors of the board.
+const (
+	green  = "\x1b[32m"
+	red    = "\x1b[31m"
+	dim    = "\x1b[2m"
+	bold   = "\x1b[1m"
+	invert = "\x1b[7m"
+	reset  = "\x1b[0m"
+)
+
+// Board is what the dashboard shows.
+type Board struct {
+	Lang  Lang
+	Color bool
+	// Group narrows the table to one asset group, every asset when empty
+	Group price.Group
+
+	Price price.Price
+	// Trends holds the sparkline samples of each asset, oldest first
+	Trends map[string][]float64
+	// TrendLabel names the span of the trends, like "24h"
+	TrendLabel string
+
+	NextRefresh time.Time
+	Refreshing  bool
+	Err         error
+	// Closed is set while the currency and gold markets are closed
+	Closed bool
+}
+
+// NextGroup moves the filter to the group after the current one, and from
+// the last group back to every group.
+func (b *Board) NextGroup() {
+	for i, g := range Groups {
+		if g == b.Group {
+			if i+1 < len(Groups) {
+				b.Group = Groups[i+1]
+			} else {
+				b.Group = ""
+			}
+			return
+		}
+	}
+	b.Group = Groups[0]
+}
+
+// Render lays the board out for a terminal of width columns and height
+// lines at now.
+func (b *Board) Render(now time.Time, width, height int) string {
+	l := boardLabels[b.Lang]
+	var lines []string
+
+	title := b.paint(bold, l.title)
+	if !b.Price.LastRefresh.IsZero() {
+		title += b.paint(dim, fmt.Sprintf("  %s %s", l.updated, utils.NewJTime(b.Price.LastRefresh).Format("yyyy/MM/dd HH:mm:ss")))
+	}
+	lines = append(lines, title, b.tabs(l), "")
+
+	rows := b.rows()
+	if len(rows) == 0 {
+		lines = append(lines, b.paint(dim, l.noPrices))
+	} else {
+		lines = append(lines, b.table(l, rows, height-len(lines)-3)...)
+	}
+
+	lines = append(lines, "", b.status(l, now), b.paint(dim, l.keys))
+	for i, line := range lines {
+		lines[i] = truncate(line, width)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tabs renders the group filter with the selected group highlighted.
+func (b *Board) tabs(l labels) string {
+	var tabs []string
+	for i, g := range append([]price.Group{""}, Groups...) {
+		label := l.all
+		if g != "" {
+			label = l.groups[g]
+		}
+		tab := fmt.Sprintf(" %d %s ", i, label)
+		if g == b.Group {
+			tab = b.paint(invert, tab)
+		}
+		tabs = append(tabs, tab)
+	}
+	return strings.Join(tabs, " ")
+}
+
+// row is one asset of the table before layout.
+type row struct {
+	name, value, change, trend string
+	color                      string
+}
+
+func (b *Board) rows() []row {
+	if b.Price.LastRefresh.IsZero() {
+		return nil
+	}
+	var keys []string
+	for _, a := range price.Assets {
+		if (b.Group == "" || a.Group == b.Group) && a.Detail(&b.Price.Current).Price != "" {
+			keys = append(keys, a.Key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	l := boardLabels[b.Lang]
+	var rows []row
+	for _, r := range b.Price.Rows(keys) {
+		detail := r.Asset.Detail(&b.Price.Current)
+		line := row{
+			name:  name(r.Asset, b.Lang),
+			value: r.Value + " " + l.units[r.Asset.Unit],
+			trend: Sparkline(b.Trends[r.Asset.Key]),
+		}
+		switch detail.ChangeDirection {
+		case "high":
+			line.change, line.color = fmt.Sprintf("▲ %.2f%%", detail.ChangePercentage), green
+		case "low":
+			line.change, line.color = fmt.Sprintf("▼ %.2f%%", detail.ChangePercentage), red
+		default:
+			line.change, line.color = fmt.Sprintf("  %.2f%%", detail.ChangePercentage), dim
+		}
+		rows = append(rows, line)
+	}
+	return rows
+}
+
+// table lays rows out in aligned columns, at most maxLines lines with its
+// header.
+func (b *Board) table(l labels, rows []row, maxLines int) []string {
+	header := row{name: l.asset, value: l.value, change: l.change, trend: l.trend}
+	if b.TrendLabel != "" {
+		header.trend = fmt.Sprintf("%s (%s)", l.trend, b.TrendLabel)
+	}
+
+	nameWidth, valueWidth, changeWidth := textWidth(header.name), textWidth(header.value), textWidth(header.change)
+	for _, r := range rows {
+		nameWidth = max(nameWidth, textWidth(r.name))
+		valueWidth = max(valueWidth, textWidth(r.value))
+		changeWidth = max(changeWidth, textWidth(r.change))
+	}
+
+	format := func(r row, color string) string {
+		return fmt.Sprintf("%s  %s  %s  %s",
+			padRight(r.name, nameWidth),
+			b.paint(color, padLeft(r.value, valueWidth)),
+			b.paint(color, padLeft(r.change, changeWidth)),
+			b.paint(color, r.trend))
+	}
+
+	lines := []string{b.paint(bold, format(header, ""))}
+	shown := rows
+	if maxLines > 1 && len(rows)+1 > maxLines {
+		shown = rows[:maxLines-2]
+	}
+	for _, r := range shown {
+		lines = append(lines, format(r, r.color))
+	}
+	if len(shown) < len(rows) {
+		lines = append(lines, b.paint(dim, fmt.Sprintf("… +%d", len(rows)-len(shown))))
+	}
+	return lines
+}
+
+// status renders the refresh countdown and market state.
+func (b *Board) status(l labels, now time.Time) string {
+	var parts []string
+	switch {
+	case b.Refreshing:
+		parts = append(parts, l.refreshing)
+	case !b.NextRefresh.IsZero():
+		seconds := max(int(b.NextRefresh.Sub(now).Round(time.Second).Seconds()), 0)
+		parts = append(parts, fmt.Sprintf("%s "+l.secondsFormat, l.next, seconds))
+	}
+	if b.Closed {
+		parts = append(parts, l.closed)
+	}
+	status := strings.Join(parts, " · ")
+	if b.Err != nil {
+		status += b.paint(red, fmt.Sprintf("  %s: %v", l.failed, b.Err))
+	}
+	return status
+}
+
+// paint colors s when the board is in color.
+func (b *Board) paint(color, s string) string {
+	if !b.Color || color == "" || s == "" {
+		return s
+	}
+	return color + s + reset
+}
+
+// sparks are the bars of a sparkline, lowest first.
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as a line of bars scaled from their minimum to
+// their maximum.
+func Sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	low, high := values[0], values[0]
+	for _, v := range values {
+		low, high = min(low, v), max(high, v)
+	}
+
+	line := make([]rune, len(values))
+	for i, v := range values {
+		level := len(sparks) / 2
+		if high > low {
+			level = int((v - low) / (high - low) * float64(len(sparks)-1))
+		}
+		line[i] = sparks[level]
+	}
+	return string(line)
+}
+
+// Trend samples the value of asset in store at n evenly spaced times over
+// window ending at now, oldest first. Times before the first recorded
+// value are left out.
+func Trend(store *history.Store, asset string, now time.Time, window time.Duration, n int) []float64 {
+	var values []float64
+	for i := 0; i < n; i++ {
+		t := now.Add(-window + window*time.Duration(i)/time.Duration(max(n-1, 1)))
+		if p, ok := store.At(asset, t); ok {
+			values = append(values, p.Value.Float64())
+		}
+	}
+	return values
+}
+
+// textWidth is how many terminal columns s takes, leaving out ANSI escapes,
+// combining marks and format characters such as the zero width non-joiner
+// in Persian names.
+func textWidth(s string) int {
+	width := 0
+	escape := false
+	for _, r := range s {
+		switch {
+		case escape:
+			escape = r != 'm'
+		case r == '\x1b':
+			escape = true
+		case unicode.Is(unicode.Mn, r), unicode.Is(unicode.Cf, r):
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-textWidth(s), 0))
+}
+
+func padLeft(s string, width int) string {
+	return strings.Repeat(" ", max(width-textWidth(s), 0)) + s
+}
+
+// truncate cuts s to width columns, keeping its ANSI escapes.
+func truncate(s string, width int) string {
+	if width <= 0 || textWidth(s) <= width {
+		return s
+	}
+	var b strings.Builder
+	columns, escape := 0, false
+	for _, r := range s {
+		visible := false
+		switch {
+		case escape:
+			escape = r != 'm'
+		case r == '\x1b':
+			escape = true
+		case unicode.Is(unicode.Mn, r), unicode.Is(unicode.Cf, r):
+		default:
+			visible = true
+		}
+		if visible {
+			if columns == width {
+				continue
+			}
+			columns++
+		}
+		b.WriteRune(r)
+	}
+	if strings.Contains(s, "\x1b") {
+		b.WriteString(reset)
+	}
+	return b.String()
+}
@@ -0,0 +1,170 @@
+package tui
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/price"
+)
+
+func testPrice(refreshed time.Time) price.Price {
+	var p price.Price
+	p.LastRefresh = refreshed
+	p.Current.Dollar = price.Detail{Price: "821,500", ChangePercentage: 0.5, ChangeDirection: "high"}
+	p.Current.Eur = price.Detail{Price: "900,000", ChangePercentage: 1.25, ChangeDirection: "low"}
+	p.Current.BitCoin = price.Detail{Price: "95,000", ChangePercentage: 0}
+	return p
+}
+
+func TestSparkline(t *testing.T) {
+	testCases := []struct {
+		values   []float64
+		expected string
+	}{
+		{nil, ""},
+		{[]float64{1, 2, 3, 4, 5, 6, 7, 8}, "▁▂▃▄▅▆▇█"},
+		{[]float64{10, 10, 10}, "▅▅▅"},
+		{[]float64{5, 0, 10}, "▄▁█"},
+	}
+	for _, tc := range testCases {
+		if got := Sparkline(tc.values); got != tc.expected {
+			t.Errorf("Sparkline(%v): expected %q, got %q", tc.values, tc.expected, got)
+		}
+	}
+}
+
+func TestDetectLang(t *testing.T) {
+	testCases := []struct {
+		env      map[string]string
+		expected Lang
+	}{
+		{map[string]string{}, English},
+		{map[string]string{"VTE_VERSION": "6800"}, Persian},
+		{map[string]string{"VTE_VERSION": "5202"}, English},
+		{map[string]string{"KONSOLE_VERSION": "230804"}, Persian},
+		{map[string]string{"TERM_PROGRAM": "Apple_Terminal"}, English},
+	}
+	for _, tc := range testCases {
+		getenv := func(key string) string { return tc.env[key] }
+		if got := DetectLang(getenv); got != tc.expected {
+			t.Errorf("%v: expected %s, got %s", tc.env, tc.expected, got)
+		}
+	}
+
+	if lang, err := ParseLang("fa", func(string) string { return "" }); err != nil || lang != Persian {
+		t.Errorf("Expected fa to force Persian, got %s, %v", lang, err)
+	}
+	if _, err := ParseLang("de", nil); err == nil {
+		t.Errorf("Expected an error for an unknown language")
+	}
+}
+
+func TestBoard_Render(t *testing.T) {
+	now := time.Date(2025, 5, 6, 12, 0, 0, 0, time.UTC)
+	b := &Board{
+		Lang:        English,
+		Price:       testPrice(now),
+		Trends:      map[string][]float64{"usd": {1, 2, 3}},
+		TrendLabel:  "24h",
+		NextRefresh: now.Add(42 * time.Second),
+	}
+
+	out := b.Render(now, 200, 40)
+	for _, expected := range []string{"US Dollar", "82,150 toman", "▲ 0.50%", "▼ 1.25%", "▁▄█", "Trend (24h)", "Bitcoin", "next refresh in 42s"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected the board to contain %q, got\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "\x1b[") {
+		t.Errorf("Expected no colors, got\n%q", out)
+	}
+
+	b.Group = price.GroupCrypto
+	out = b.Render(now, 200, 40)
+	if strings.Contains(out, "US Dollar") || !strings.Contains(out, "Bitcoin") {
+		t.Errorf("Expected only crypto assets, got\n%s", out)
+	}
+
+	b.Group, b.Color = "", true
+	out = b.Render(now, 200, 40)
+	if !strings.Contains(out, green+"82,150 toman") || !strings.Contains(out, red+"90,000 toman") {
+		t.Errorf("Expected rising prices green and falling red, got\n%q", out)
+	}
+
+	b.Lang = Persian
+	out = b.Render(now, 200, 40)
+	if !strings.Contains(out, "دلار امریکا") || !strings.Contains(out, "تومان") {
+		t.Errorf("Expected Persian labels, got\n%s", out)
+	}
+}
+
+func TestBoard_RenderFits(t *testing.T) {
+	now := time.Date(2025, 5, 6, 12, 0, 0, 0, time.UTC)
+	b := &Board{Lang: English, Color: true, Price: testPrice(now)}
+
+	lines := strings.Split(b.Render(now, 30, 9), "\n")
+	if len(lines) > 9 {
+		t.Errorf("Expected at most 9 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		if textWidth(line) > 30 {
+			t.Errorf("Expected lines of at most 30 columns, got %d: %q", textWidth(line), line)
+		}
+	}
+	if !strings.Contains(strings.Join(lines, "\n"), "… +") {
+		t.Errorf("Expected the rows left out to be counted, got\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestBoard_NextGroup(t *testing.T) {
+	b := &Board{}
+	var seen []price.Group
+	for range len(Groups) + 1 {
+		b.NextGroup()
+		seen = append(seen, b.Group)
+	}
+	expected := append(append([]price.Group{}, Groups...), "")
+	for i := range expected {
+		if seen[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, seen)
+			break
+		}
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	testCases := map[string]int{
+		"US Dollar":               9,
+		"ربع سکه قبل ۸۶":          14,
+		"دارایی‌ها":               8, // the zero width non-joiner takes no column
+		green + "▲ 0.50%" + reset: 7,
+	}
+	for s, expected := range testCases {
+		if got := textWidth(s); got != expected {
+			t.Errorf("textWidth(%q): expected %d, got %d", s, expected, got)
+		}
+	}
+}
+
+func TestTrend(t *testing.T) {
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 5, 6, 12, 0, 0, 0, time.UTC)
+	for i, value := range []float64{100, 110, 120} {
+		at := now.Add(time.Duration(i-2) * 10 * time.Hour)
+		if err := store.Record(at, map[string]price.Decimal{"usd": price.DecimalFromFloat(value)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// samples at -24h, -12h and now; nothing was recorded before -20h
+	values := Trend(store, "usd", now, 24*time.Hour, 3)
+	if len(values) != 2 || values[0] != 100 || values[1] != 120 {
+		t.Errorf("Expected [100 120], got %v", values)
+	}
+}
@@ -0,0 +1,112 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/onionj/pricebot/price"
+)
+
+// Lang is the language of the board's labels.
+type Lang string
+
+const (
+	English Lang = "en"
+	Persian Lang = "fa"
+)
+
+// ParseLang parses "en", "fa" or "auto", which picks Persian only where
+// DetectLang finds a terminal that lays out right-to-left text.
+func ParseLang(s string, getenv func(string) string) (Lang, error) {
+	switch strings.ToLower(s) {
+	case "", "auto":
+		return DetectLang(getenv), nil
+	case "en":
+		return English, nil
+	case "fa":
+		return Persian, nil
+	}
+	return "", fmt.Errorf("unknown language %q, want auto, en or fa", s)
+}
+
+// DetectLang returns Persian in terminals known to join Persian letters and
+// order right-to-left text, and English elsewhere, where Persian labels
+// would show as disjoint letters in reverse.
+func DetectLang(getenv func(string) string) Lang {
+	// VTE terminals (GNOME Terminal, Tilix, ...) handle bidi from 0.58
+	if version, err := strconv.Atoi(getenv("VTE_VERSION")); err == nil && version >= 5800 {
+		return Persian
+	}
+	if getenv("KONSOLE_VERSION") != "" || getenv("MLTERM") != "" {
+		return Persian
+	}
+	return English
+}
+
+// englishNames are the asset names of the English board.
+var englishNames = map[string]string{
+	"usd":      "US Dollar",
+	"eur":      "Euro",
+	"gbp":      "British Pound",
+	"cad":      "Canadian Dollar",
+	"aud":      "Australian Dollar",
+	"aed":      "UAE Dirham",
+	"try":      "Turkish Lira",
+	"sek":      "Swedish Krona",
+	"cny":      "Chinese Yuan",
+	"sar":      "Saudi Riyal",
+	"iqd":      "Iraqi Dinar",
+	"btc":      "Bitcoin",
+	"usdt":     "Tether",
+	"eth":      "Ethereum",
+	"sekeb":    "Bahar Azadi Coin",
+	"sekee":    "Emami Coin",
+	"nim":      "Half Coin",
+	"rob":      "Quarter Coin",
+	"rob_down": "Quarter Coin (pre-86)",
+	"geram18":  "Gold 18k, 1g",
+	"mesghal":  "Gold Mesghal",
+	"ons":      "Gold Ounce",
+}
+
+// labels are the fixed texts of the board in one language.
+type labels struct {
+	title, all, asset, value, change, trend string
+	updated, next, refreshing, failed, keys string
+	groups                                  map[price.Group]string
+	units                                   map[price.Unit]string
+	closed                                  string
+	noPrices                                string
+	secondsFormat                           string
+}
+
+var boardLabels = map[Lang]labels{
+	English: {
+		title: "Live prices", all: "All", asset: "Asset", value: "Price", change: "Change", trend: "Trend",
+		updated: "Updated", next: "next refresh in", refreshing: "refreshing…", failed: "refresh failed",
+		keys:   "0-4 group · tab next group · r refresh · l language · q quit",
+		groups: map[price.Group]string{price.GroupCurrency: "Currency", price.GroupCrypto: "Crypto", price.GroupCoin: "Coins", price.GroupGold: "Gold"},
+		units:  map[price.Unit]string{price.Rial: "rial", price.Toman: "toman", price.USD: "USD"},
+		closed: "markets closed", noPrices: "waiting for prices…", secondsFormat: "%ds",
+	},
+	Persian: {
+		title: "قیمت لحظه‌ای", all: "همه", asset: "دارایی", value: "قیمت", change: "تغییر", trend: "روند",
+		updated: "بروزرسانی", next: "بروزرسانی بعدی", refreshing: "درحال بروزرسانی…", failed: "بروزرسانی ناموفق",
+		keys:   "۰ تا ۴ گروه · tab گروه بعد · r بروزرسانی · l زبان · q خروج",
+		groups: map[price.Group]string{price.GroupCurrency: "ارز", price.GroupCrypto: "رمزارز", price.GroupCoin: "سکه", price.GroupGold: "طلا"},
+		units:  map[price.Unit]string{price.Rial: price.Rial.Label(), price.Toman: price.Toman.Label(), price.USD: price.USD.Label()},
+		closed: "بازار بسته است", noPrices: "در انتظار قیمت‌ها…", secondsFormat: "%d ثانیه",
+	},
+}
+
+// name returns the asset's name in lang.
+func name(a price.Asset, lang Lang) string {
+	if lang == Persian {
+		return a.Name
+	}
+	if n, ok := englishNames[a.Key]; ok {
+		return n
+	}
+	return a.Key
+}
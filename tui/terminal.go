@@ -0,0 +1,185 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onionj/pricebot/history"
+	"github.com/onionj/pricebot/price"
+)
+
+// DefaultTrendPoints is how many samples a sparkline has.
+const DefaultTrendPoints = 24
+
+// Config sets up a dashboard.
+type Config struct {
+	Price *price.Price
+	// HistoryFile is read again after every refresh for the sparklines
+	HistoryFile string
+	TrendWindow time.Duration
+	// TrendLabel names TrendWindow on the board, like "24h"
+	TrendLabel  string
+	TrendPoints int
+	// Period returns how long to wait after a refresh at now, and whether
+	// the markets are closed
+	Period func(now time.Time) (time.Duration, bool)
+
+	Lang  Lang
+	Group price.Group
+	Color bool
+
+	In  *os.File
+	Out io.Writer
+}
+
+// refreshResult is a finished refresh, handed from its goroutine to the loop.
+type refreshResult struct {
+	price  price.Price
+	trends map[string][]float64
+	err    error
+}
+
+// Run shows the dashboard on cfg.Out until q is pressed or ctx is done.
+// Keys are read one at a time where stty can switch the terminal to raw
+// mode, and need Enter elsewhere.
+func Run(ctx context.Context, cfg Config) error {
+	if cfg.TrendPoints <= 0 {
+		cfg.TrendPoints = DefaultTrendPoints
+	}
+	if restore, err := rawMode(cfg.In); err == nil {
+		defer restore()
+	}
+	fmt.Fprint(cfg.Out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(cfg.Out, "\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := cfg.In.Read(buf); err != nil {
+				close(keys)
+				return
+			}
+			keys <- buf[0]
+		}
+	}()
+
+	board := &Board{Lang: cfg.Lang, Color: cfg.Color, Group: cfg.Group, TrendLabel: cfg.TrendLabel}
+	refreshed := make(chan refreshResult, 1)
+	refresh := func() {
+		board.Refreshing = true
+		go func() { refreshed <- cfg.refresh(ctx) }()
+	}
+	refresh()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		width, height := terminalSize(cfg.In)
+		// draw over the last frame, clearing what is left of each line, so
+		// the board doesn't flicker
+		frame := strings.ReplaceAll(board.Render(time.Now(), width, height), "\n", "\x1b[K\n")
+		fmt.Fprint(cfg.Out, "\x1b[H"+frame+"\x1b[K\x1b[J")
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case result := <-refreshed:
+			board.Refreshing = false
+			board.Err = result.err
+			if result.err == nil {
+				board.Price, board.Trends = result.price, result.trends
+			}
+			period, closed := cfg.Period(time.Now())
+			board.NextRefresh, board.Closed = time.Now().Add(period), closed
+		case <-ticker.C:
+			if !board.Refreshing && !time.Now().Before(board.NextRefresh) {
+				refresh()
+			}
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil // stdin closed, keep showing until ctx is done
+				continue
+			}
+			switch key {
+			case 'q', 'Q':
+				return nil
+			case '0':
+				board.Group = ""
+			case '1', '2', '3', '4':
+				board.Group = Groups[key-'1']
+			case '\t', 'g':
+				board.NextGroup()
+			case 'l', 'L':
+				board.Lang = map[Lang]Lang{English: Persian, Persian: English}[board.Lang]
+			case 'r', 'R':
+				if !board.Refreshing {
+					refresh()
+				}
+			}
+		}
+	}
+}
+
+// refresh refreshes cfg.Price and samples the trends of every asset from the
+// history file, ending at the new prices.
+func (cfg Config) refresh(ctx context.Context) refreshResult {
+	if err := cfg.Price.Refresh(ctx); err != nil {
+		return refreshResult{err: err}
+	}
+	result := refreshResult{price: *cfg.Price, trends: make(map[string][]float64)}
+
+	store, err := history.Open(cfg.HistoryFile)
+	if err != nil {
+		store, _ = history.Open("")
+	}
+	now := time.Now()
+	for key, value := range cfg.Price.Current.Values() {
+		result.trends[key] = append(Trend(store, key, now, cfg.TrendWindow, cfg.TrendPoints-1), value.Float64())
+	}
+	return result
+}
+
+// rawMode makes in deliver key presses without Enter or echo, returning how
+// to restore it.
+func rawMode(in *os.File) (func(), error) {
+	saved, err := stty(in, "-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty(in, "-icanon", "-echo", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { stty(in, strings.TrimSpace(saved)) }, nil
+}
+
+func stty(in *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = in
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// terminalSize returns the columns and lines of the terminal at in, from
+// stty or else COLUMNS and LINES, 80 by 24 when neither is known.
+func terminalSize(in *os.File) (width, height int) {
+	if out, err := stty(in, "size"); err == nil {
+		if _, err := fmt.Sscan(out, &height, &width); err == nil && width > 0 && height > 0 {
+			return width, height
+		}
+	}
+	width, height = 80, 24
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		width = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LINES")); err == nil && n > 0 {
+		height = n
+	}
+	return width, height
+}
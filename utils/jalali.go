@@ -1,6 +1,35 @@
 package utils
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	// embed the zone database so Asia/Tehran resolves in minimal containers
+	_ "time/tzdata"
+)
+
+// Tehran is the Asia/Tehran location every Jalali time is expressed in.
+var Tehran = loadTehran()
+
+func loadTehran() *time.Location {
+	loc, err := time.LoadLocation("Asia/Tehran")
+	if err != nil {
+		// Iran has not observed daylight saving time since 2022
+		return time.FixedZone("IRST", 3*60*60+30*60)
+	}
+	return loc
+}
+
+var monthNames = [12]string{
+	"فروردین", "اردیبهشت", "خرداد", "تیر", "مرداد", "شهریور",
+	"مهر", "آبان", "آذر", "دی", "بهمن", "اسفند",
+}
+
+// weekdayNames is indexed by time.Weekday.
+var weekdayNames = [7]string{
+	"یکشنبه", "دوشنبه", "سه‌شنبه", "چهارشنبه", "پنجشنبه", "جمعه", "شنبه",
+}
 
 type JDate struct {
 	Year  int
@@ -24,8 +53,9 @@ func GregorianToJalali(year int, month int, day int) JDate {
 		result.Year = 979
 	}
 
+	// the leap day of the current Gregorian year only counts after February
 	var temp int
-	if year > 2 {
+	if month > 2 {
 		temp = year + 1
 	} else {
 		temp = year
@@ -58,3 +88,370 @@ func GregorianToJalali(year int, month int, day int) JDate {
 
 	return result
 }
+
+// JalaliToGregorian converts a Jalali date to its Gregorian year, month and day.
+func JalaliToGregorian(year int, month int, day int) (int, int, int) {
+	days := jalaliDayNumber(year, month, day)
+
+	gy := 400 * (days / 146097)
+	days = days % 146097
+	if days > 36524 {
+		days--
+		gy += 100 * (days / 36524)
+		days = days % 36524
+		if days >= 365 {
+			days++
+		}
+	}
+
+	gy += 4 * (days / 1461)
+	days = days % 1461
+	if days > 365 {
+		gy += (days - 1) / 365
+		days = (days - 1) % 365
+	}
+
+	gd := days + 1
+	monthDays := [12]int{31, 28, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+	if (gy%4 == 0 && gy%100 != 0) || gy%400 == 0 {
+		monthDays[1] = 29
+	}
+
+	gm := 0
+	for gm < 12 && gd > monthDays[gm] {
+		gd -= monthDays[gm]
+		gm++
+	}
+	return gy, gm + 1, gd
+}
+
+// jalaliDayNumber counts days from the Gregorian epoch used by JalaliToGregorian.
+func jalaliDayNumber(year, month, day int) int {
+	year += 1595
+	days := -355668 + 365*year + (year/33)*8 + ((year%33)+3)/4 + day
+	if month < 7 {
+		days += (month - 1) * 31
+	} else {
+		days += (month-7)*30 + 186
+	}
+	return days
+}
+
+// IsLeapYear reports whether the Jalali year has 366 days.
+func IsLeapYear(year int) bool {
+	return jalaliDayNumber(year+1, 1, 1)-jalaliDayNumber(year, 1, 1) == 366
+}
+
+// DaysInMonth returns the number of days in a Jalali month.
+func DaysInMonth(year, month int) int {
+	switch {
+	case month <= 6:
+		return 31
+	case month <= 11:
+		return 30
+	case IsLeapYear(year):
+		return 30
+	default:
+		return 29
+	}
+}
+
+// MonthName returns the Persian name of a Jalali month (1 is فروردین).
+func MonthName(month int) string {
+	if month < 1 || month > 12 {
+		return ""
+	}
+	return monthNames[month-1]
+}
+
+// WeekdayName returns the Persian name of a weekday.
+func WeekdayName(day time.Weekday) string {
+	return weekdayNames[day]
+}
+
+// ToJalali converts t, in Tehran time, to a Jalali date.
+func ToJalali(t time.Time) JDate {
+	t = t.In(Tehran)
+	return GregorianToJalali(t.Year(), int(t.Month()), t.Day())
+}
+
+// Valid reports whether d is a real Jalali date.
+func (d JDate) Valid() bool {
+	return d.Year > 0 && d.Month >= 1 && d.Month <= 12 && d.Day >= 1 && d.Day <= DaysInMonth(d.Year, d.Month)
+}
+
+// Time returns midnight of d in Tehran.
+func (d JDate) Time() time.Time {
+	gy, gm, gd := JalaliToGregorian(d.Year, d.Month, d.Day)
+	return time.Date(gy, time.Month(gm), gd, 0, 0, 0, 0, Tehran)
+}
+
+func (d JDate) Weekday() time.Weekday {
+	return d.Time().Weekday()
+}
+
+func (d JDate) MonthName() string {
+	return MonthName(d.Month)
+}
+
+func (d JDate) WeekdayName() string {
+	return WeekdayName(d.Weekday())
+}
+
+func (d JDate) IsLeapYear() bool {
+	return IsLeapYear(d.Year)
+}
+
+// AddDays returns d shifted by n days; n may be negative.
+func (d JDate) AddDays(n int) JDate {
+	t := d.Time()
+	// noon keeps the result on the right day regardless of zone transitions
+	t = time.Date(t.Year(), t.Month(), t.Day()+n, 12, 0, 0, 0, Tehran)
+	return GregorianToJalali(t.Year(), int(t.Month()), t.Day())
+}
+
+// AddMonths returns d shifted by n Jalali months; the day is clamped to the
+// length of the target month, so 1403/06/31 plus one month is 1403/07/30.
+func (d JDate) AddMonths(n int) JDate {
+	months := d.Year*12 + (d.Month - 1) + n
+	year, month := months/12, months%12+1
+	day := d.Day
+	if last := DaysInMonth(year, month); day > last {
+		day = last
+	}
+	return JDate{Year: year, Month: month, Day: day}
+}
+
+// StartOfWeek returns the Saturday on or before d; the Persian week runs Saturday to Friday.
+func (d JDate) StartOfWeek() JDate {
+	return d.AddDays(-((int(d.Weekday()) + 1) % 7))
+}
+
+// StartOfMonth returns the first day of d's month.
+func (d JDate) StartOfMonth() JDate {
+	return JDate{Year: d.Year, Month: d.Month, Day: 1}
+}
+
+// StartOfYear returns Nowruz, the first day of d's year.
+func (d JDate) StartOfYear() JDate {
+	return JDate{Year: d.Year, Month: 1, Day: 1}
+}
+
+// Before reports whether d comes before other.
+func (d JDate) Before(other JDate) bool {
+	return d.Compare(other) < 0
+}
+
+// After reports whether d comes after other.
+func (d JDate) After(other JDate) bool {
+	return d.Compare(other) > 0
+}
+
+// Compare returns -1, 0 or +1 depending on whether d is before, equal to or after other.
+func (d JDate) Compare(other JDate) int {
+	a := d.Year*10000 + d.Month*100 + d.Day
+	b := other.Year*10000 + other.Month*100 + other.Day
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Format renders d using the layout tokens described on JTime.Format.
+// Time-of-day tokens render as midnight.
+func (d JDate) Format(layout string) string {
+	return JTime{t: d.Time()}.Format(layout)
+}
+
+// ParseJDate parses a Jalali date written as yyyy/mm/dd or yyyy-mm-dd.
+// Persian and Arabic-Indic digits are accepted.
+func ParseJDate(s string) (JDate, error) {
+	s = strings.TrimSpace(latinDigits(s))
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == '/' || r == '-' })
+	if len(parts) != 3 {
+		return JDate{}, fmt.Errorf("invalid jalali date %q", s)
+	}
+
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return JDate{}, fmt.Errorf("invalid jalali date %q", s)
+		}
+		nums[i] = n
+	}
+
+	d := JDate{Year: nums[0], Month: nums[1], Day: nums[2]}
+	if !d.Valid() {
+		return JDate{}, fmt.Errorf("jalali date %q out of range", s)
+	}
+	return d, nil
+}
+
+// JTime is an instant expressed in the Jalali calendar at Asia/Tehran time.
+type JTime struct {
+	t time.Time
+}
+
+// NewJTime converts t to Tehran time.
+func NewJTime(t time.Time) JTime {
+	return JTime{t: t.In(Tehran)}
+}
+
+// Now returns the current time in Tehran.
+func Now() JTime {
+	return NewJTime(time.Now())
+}
+
+// Time returns the underlying time in Tehran.
+func (t JTime) Time() time.Time {
+	return t.t
+}
+
+// Date returns the Jalali calendar date of t.
+func (t JTime) Date() JDate {
+	return GregorianToJalali(t.t.Year(), int(t.t.Month()), t.t.Day())
+}
+
+func (t JTime) Hour() int   { return t.t.Hour() }
+func (t JTime) Minute() int { return t.t.Minute() }
+func (t JTime) Second() int { return t.t.Second() }
+
+func (t JTime) Weekday() time.Weekday {
+	return t.t.Weekday()
+}
+
+func (t JTime) Add(d time.Duration) JTime {
+	return JTime{t: t.t.Add(d)}
+}
+
+func (t JTime) AddDays(n int) JTime {
+	return JTime{t: t.t.AddDate(0, 0, n)}
+}
+
+// AddMonths shifts t by n Jalali months keeping the time of day.
+func (t JTime) AddMonths(n int) JTime {
+	d := t.Date().AddMonths(n).Time()
+	return JTime{t: time.Date(d.Year(), d.Month(), d.Day(), t.t.Hour(), t.t.Minute(), t.t.Second(), t.t.Nanosecond(), Tehran)}
+}
+
+func (t JTime) String() string {
+	return t.Format("yyyy/MM/dd HH:mm:ss")
+}
+
+// formatTokens are tried longest first at each position of a layout.
+var formatTokens = []string{"yyyy", "MMMM", "EEEE", "yy", "MM", "dd", "HH", "mm", "ss", "M", "d"}
+
+// Format renders t with these layout tokens:
+//
+//	yyyy  four digit year      yy  two digit year
+//	MMMM  Persian month name   MM  zero padded month   M  month
+//	dd    zero padded day      d   day
+//	EEEE  Persian weekday name
+//	HH    hour (00-23)         mm  minute              ss  second
+//
+// Any other character is copied as is.
+func (t JTime) Format(layout string) string {
+	date := t.Date()
+	var b strings.Builder
+
+	for i := 0; i < len(layout); {
+		matched := ""
+		for _, token := range formatTokens {
+			if strings.HasPrefix(layout[i:], token) {
+				matched = token
+				break
+			}
+		}
+
+		switch matched {
+		case "yyyy":
+			fmt.Fprintf(&b, "%04d", date.Year)
+		case "yy":
+			fmt.Fprintf(&b, "%02d", date.Year%100)
+		case "MMMM":
+			b.WriteString(date.MonthName())
+		case "MM":
+			fmt.Fprintf(&b, "%02d", date.Month)
+		case "M":
+			b.WriteString(strconv.Itoa(date.Month))
+		case "dd":
+			fmt.Fprintf(&b, "%02d", date.Day)
+		case "d":
+			b.WriteString(strconv.Itoa(date.Day))
+		case "EEEE":
+			b.WriteString(WeekdayName(t.t.Weekday()))
+		case "HH":
+			fmt.Fprintf(&b, "%02d", t.t.Hour())
+		case "mm":
+			fmt.Fprintf(&b, "%02d", t.t.Minute())
+		case "ss":
+			fmt.Fprintf(&b, "%02d", t.t.Second())
+		default:
+			b.WriteByte(layout[i])
+			i++
+			continue
+		}
+		i += len(matched)
+	}
+	return b.String()
+}
+
+// ParseJTime parses "yyyy/mm/dd HH:MM[:SS]" in Tehran time. The time part is optional.
+func ParseJTime(s string) (JTime, error) {
+	fields := strings.Fields(latinDigits(s))
+	if len(fields) == 0 || len(fields) > 2 {
+		return JTime{}, fmt.Errorf("invalid jalali time %q", s)
+	}
+
+	date, err := ParseJDate(fields[0])
+	if err != nil {
+		return JTime{}, err
+	}
+
+	var clock [3]int
+	if len(fields) == 2 {
+		parts := strings.Split(fields[1], ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return JTime{}, fmt.Errorf("invalid jalali time %q", s)
+		}
+		limits := [3]int{23, 59, 59}
+		for i, part := range parts {
+			n, err := strconv.Atoi(part)
+			if err != nil || n < 0 || n > limits[i] {
+				return JTime{}, fmt.Errorf("invalid jalali time %q", s)
+			}
+			clock[i] = n
+		}
+	}
+
+	d := date.Time()
+	return JTime{t: time.Date(d.Year(), d.Month(), d.Day(), clock[0], clock[1], clock[2], 0, Tehran)}, nil
+}
+
+// PersianDigits replaces ASCII digits in s with Persian digits.
+func PersianDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return '۰' + (r - '0')
+		}
+		return r
+	}, s)
+}
+
+// latinDigits replaces Persian and Arabic-Indic digits in s with ASCII digits.
+func latinDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '۰' && r <= '۹':
+			return '0' + (r - '۰')
+		case r >= '٠' && r <= '٩':
+			return '0' + (r - '٠')
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGregorianToJalali(t *testing.T) {
+	testCases := []struct {
+		year, month, day int
+		expected         JDate
+	}{
+		{2024, 3, 20, JDate{1403, 1, 1}},
+		{2025, 3, 20, JDate{1403, 12, 30}},
+		{2025, 3, 21, JDate{1404, 1, 1}},
+		{2023, 3, 21, JDate{1402, 1, 1}},
+		{2000, 1, 1, JDate{1378, 10, 11}},
+		{1979, 2, 11, JDate{1357, 11, 22}},
+	}
+
+	for _, tc := range testCases {
+		got := GregorianToJalali(tc.year, tc.month, tc.day)
+		if got != tc.expected {
+			t.Errorf("GregorianToJalali(%d, %d, %d) = %v; want %v", tc.year, tc.month, tc.day, got, tc.expected)
+		}
+	}
+}
+
+func TestJalaliToGregorian_RoundTrip(t *testing.T) {
+	for day := time.Date(1950, 1, 1, 12, 0, 0, 0, time.UTC); day.Year() < 2100; day = day.AddDate(0, 0, 1) {
+		j := GregorianToJalali(day.Year(), int(day.Month()), day.Day())
+		if !j.Valid() {
+			t.Fatalf("GregorianToJalali(%s) = %v is not a valid date", day.Format("2006-01-02"), j)
+		}
+		gy, gm, gd := JalaliToGregorian(j.Year, j.Month, j.Day)
+		if gy != day.Year() || gm != int(day.Month()) || gd != day.Day() {
+			t.Fatalf("JalaliToGregorian(%v) = %d-%02d-%02d; want %s", j, gy, gm, gd, day.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestIsLeapYear(t *testing.T) {
+	leap := map[int]bool{1395: true, 1399: true, 1403: true, 1408: true}
+	for year := 1395; year <= 1410; year++ {
+		if IsLeapYear(year) != leap[year] {
+			t.Errorf("IsLeapYear(%d) = %v; want %v", year, IsLeapYear(year), leap[year])
+		}
+	}
+
+	if DaysInMonth(1403, 12) != 30 || DaysInMonth(1404, 12) != 29 {
+		t.Errorf("Unexpected Esfand length: 1403=%d 1404=%d", DaysInMonth(1403, 12), DaysInMonth(1404, 12))
+	}
+}
+
+func TestJDate_Names(t *testing.T) {
+	d := JDate{1404, 1, 1}
+	if d.MonthName() != "فروردین" {
+		t.Errorf("Expected فروردین, got %s", d.MonthName())
+	}
+	if d.Weekday() != time.Friday || d.WeekdayName() != "جمعه" {
+		t.Errorf("Expected 1404/01/01 to be a Friday (جمعه), got %v (%s)", d.Weekday(), d.WeekdayName())
+	}
+	if MonthName(12) != "اسفند" || MonthName(13) != "" {
+		t.Errorf("Unexpected month names: %q %q", MonthName(12), MonthName(13))
+	}
+}
+
+func TestJDate_Arithmetic(t *testing.T) {
+	testCases := []struct {
+		name     string
+		got      JDate
+		expected JDate
+	}{
+		{"AddDays across year", JDate{1403, 12, 30}.AddDays(1), JDate{1404, 1, 1}},
+		{"AddDays negative", JDate{1404, 1, 1}.AddDays(-1), JDate{1403, 12, 30}},
+		{"AddDays many", JDate{1403, 1, 1}.AddDays(366), JDate{1404, 1, 1}},
+		{"AddMonths clamp", JDate{1403, 6, 31}.AddMonths(1), JDate{1403, 7, 30}},
+		{"AddMonths across year", JDate{1403, 11, 15}.AddMonths(3), JDate{1404, 2, 15}},
+		{"AddMonths negative", JDate{1404, 1, 31}.AddMonths(-1), JDate{1403, 12, 30}},
+		{"AddMonths leap clamp", JDate{1404, 11, 30}.AddMonths(1), JDate{1404, 12, 29}},
+	}
+
+	for _, tc := range testCases {
+		if tc.got != tc.expected {
+			t.Errorf("%s = %v; want %v", tc.name, tc.got, tc.expected)
+		}
+	}
+
+	if !(JDate{1403, 12, 30}).Before(JDate{1404, 1, 1}) || (JDate{1404, 1, 1}).Compare(JDate{1404, 1, 1}) != 0 {
+		t.Error("Unexpected date comparison result")
+	}
+}
+
+func TestJDate_Boundaries(t *testing.T) {
+	testCases := []struct {
+		name     string
+		got      JDate
+		expected JDate
+	}{
+		{"week from tuesday", JDate{1404, 2, 16}.StartOfWeek(), JDate{1404, 2, 13}},
+		{"week from saturday", JDate{1404, 2, 13}.StartOfWeek(), JDate{1404, 2, 13}},
+		{"week from friday", JDate{1404, 2, 19}.StartOfWeek(), JDate{1404, 2, 13}},
+		{"week across year", JDate{1404, 1, 1}.StartOfWeek(), JDate{1403, 12, 25}},
+		{"month", JDate{1404, 2, 16}.StartOfMonth(), JDate{1404, 2, 1}},
+		{"year", JDate{1404, 2, 16}.StartOfYear(), JDate{1404, 1, 1}},
+	}
+
+	for _, tc := range testCases {
+		if tc.got != tc.expected {
+			t.Errorf("%s = %v; want %v", tc.name, tc.got, tc.expected)
+		}
+	}
+}
+
+func TestJTime_Format(t *testing.T) {
+	jt := NewJTime(time.Date(2025, 3, 21, 5, 4, 3, 0, time.UTC))
+
+	testCases := []struct {
+		layout   string
+		expected string
+	}{
+		{"yyyy/MM/dd HH:mm:ss", "1404/01/01 08:34:03"},
+		{"EEEE d MMMM yyyy", "جمعه 1 فروردین 1404"},
+		{"yy-M-d", "04-1-1"},
+		{"[yyyy]", "[1404]"},
+	}
+
+	for _, tc := range testCases {
+		if got := jt.Format(tc.layout); got != tc.expected {
+			t.Errorf("Format(%q) = %q; want %q", tc.layout, got, tc.expected)
+		}
+	}
+
+	if got := (JDate{1403, 12, 30}).Format("yyyy/MM/dd"); got != "1403/12/30" {
+		t.Errorf("JDate.Format = %q; want 1403/12/30", got)
+	}
+}
+
+func TestParseJDate(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected JDate
+		wantErr  bool
+	}{
+		{"1403/12/30", JDate{1403, 12, 30}, false},
+		{"1404-1-1", JDate{1404, 1, 1}, false},
+		{"۱۴۰۴/۰۲/۱۵", JDate{1404, 2, 15}, false},
+		{"1404/12/30", JDate{}, true},
+		{"1404/13/01", JDate{}, true},
+		{"1404/01", JDate{}, true},
+		{"abc", JDate{}, true},
+	}
+
+	for _, tc := range testCases {
+		got, err := ParseJDate(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseJDate(%q) error = %v; wantErr %v", tc.input, err, tc.wantErr)
+		}
+		if got != tc.expected {
+			t.Errorf("ParseJDate(%q) = %v; want %v", tc.input, got, tc.expected)
+		}
+	}
+}
+
+func TestParseJTime(t *testing.T) {
+	jt, err := ParseJTime("1404/01/01 08:34")
+	if err != nil {
+		t.Fatalf("ParseJTime failed: %v", err)
+	}
+	expected := time.Date(2025, 3, 21, 5, 4, 0, 0, time.UTC)
+	if !jt.Time().Equal(expected) {
+		t.Errorf("ParseJTime = %s; want %s", jt.Time(), expected)
+	}
+
+	if _, err := ParseJTime("1404/01/01 24:00"); err == nil {
+		t.Error("Expected error for hour 24")
+	}
+
+	if got := jt.AddMonths(1).String(); got != "1404/02/01 08:34:00" {
+		t.Errorf("AddMonths(1) = %s; want 1404/02/01 08:34:00", got)
+	}
+}
+
+func TestPersianDigits(t *testing.T) {
+	if got := PersianDigits("1404/01/01"); got != "۱۴۰۴/۰۱/۰۱" {
+		t.Errorf("PersianDigits = %s", got)
+	}
+}
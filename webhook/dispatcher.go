@@ -0,0 +1,310 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/onionj/pricebot/price"
+)
+
+// Make these package variables so they can be modified in tests
+var (
+	httpClient     = &http.Client{}
+	requestTimeout = 10 * time.Second
+)
+
+// Config tunes deliveries.
+type Config struct {
+	// MaxAttempts is how often a delivery is tried before it is dead-lettered
+	MaxAttempts int
+	// Backoff is the wait after the first failed attempt, doubling up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// QueueSize bounds the deliveries waiting per endpoint
+	QueueSize int
+	// DeadLetter is the JSONL file failed deliveries are appended to, none when empty
+	DeadLetter string
+	// Provider names the price provider in provider events
+	Provider string
+}
+
+func DefaultConfig() Config {
+	return Config{MaxAttempts: 6, Backoff: 5 * time.Second, MaxBackoff: 5 * time.Minute, QueueSize: 100, Provider: "tgju"}
+}
+
+// Dispatcher turns price refreshes and provider failures into events and
+// delivers them. Every endpoint has its own queue and worker, so a slow
+// endpoint delays only its own deliveries, which arrive in order.
+type Dispatcher struct {
+	cfg     Config
+	logger  *slog.Logger
+	targets []*target
+	// failing holds the provider failure reasons already announced
+	failing map[string]time.Time
+
+	deadMu sync.Mutex
+	wg     sync.WaitGroup
+}
+
+type target struct {
+	Endpoint
+	queue chan delivery
+	// baselines are the prices of the last price.changed per asset
+	baselines map[string]baseline
+}
+
+type baseline struct {
+	value price.Decimal
+	at    time.Time
+}
+
+type delivery struct {
+	event Event
+	body  []byte
+}
+
+// New returns a dispatcher for endpoints. It delivers nothing until Start.
+func New(endpoints []Endpoint, cfg Config, logger *slog.Logger) *Dispatcher {
+	d := &Dispatcher{cfg: cfg, logger: logger, failing: make(map[string]time.Time)}
+	for _, e := range endpoints {
+		if e.Threshold == 0 {
+			e.Threshold = DefaultThreshold
+		}
+		d.targets = append(d.targets, &target{
+			Endpoint:  e,
+			queue:     make(chan delivery, cfg.QueueSize),
+			baselines: make(map[string]baseline),
+		})
+	}
+	return d
+}
+
+// Start runs a delivery worker per endpoint until ctx is done. Deliveries
+// still queued then are dead-lettered.
+func (d *Dispatcher) Start(ctx context.Context) {
+	for _, t := range d.targets {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.work(ctx, t)
+		}()
+	}
+}
+
+// Wait blocks until the workers have stopped.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// Refreshed sends prices.refreshed and, for assets that moved past an
+// endpoint's threshold since its last price.changed, price.changed. The
+// first refresh only sets the baselines.
+func (d *Dispatcher) Refreshed(at time.Time, values map[string]price.Decimal) {
+	for _, t := range d.targets {
+		if t.wants(EventRefreshed) {
+			prices := make(map[string]price.Decimal)
+			for key, value := range values {
+				if t.watches(key) {
+					prices[key] = value
+				}
+			}
+			d.enqueue(t, EventRefreshed, RefreshedData{At: at, Prices: prices})
+		}
+
+		for _, asset := range price.Assets {
+			value, ok := values[asset.Key]
+			if !ok || !t.watches(asset.Key) {
+				continue
+			}
+			base, ok := t.baselines[asset.Key]
+			if !ok || base.value.Sign() <= 0 {
+				t.baselines[asset.Key] = baseline{value, at}
+				continue
+			}
+			percent := value.PercentChange(base.value)
+			if percent < t.Threshold && -percent < t.Threshold {
+				continue
+			}
+			t.baselines[asset.Key] = baseline{value, at}
+			if t.wants(EventChanged) {
+				d.enqueue(t, EventChanged, ChangedData{
+					Asset: asset.Key, Name: asset.Name, Unit: asset.Unit,
+					From: base.value, To: value, Percent: percent, Since: base.at, At: at,
+				})
+			}
+		}
+	}
+}
+
+// ProviderFailed sends provider.failed the first time the provider fails
+// for reason until it recovers.
+func (d *Dispatcher) ProviderFailed(reason string, err error, since time.Time) {
+	if _, ok := d.failing[reason]; ok {
+		return
+	}
+	d.failing[reason] = since
+	d.broadcast(EventProviderFailed, ProviderData{Provider: d.cfg.Provider, Reason: reason, Error: err.Error(), Since: since})
+}
+
+// ProviderRecovered sends provider.recovered when the failure for reason
+// announced by ProviderFailed is over.
+func (d *Dispatcher) ProviderRecovered(reason string) {
+	since, ok := d.failing[reason]
+	if !ok {
+		return
+	}
+	delete(d.failing, reason)
+	d.broadcast(EventProviderRecovered, ProviderData{Provider: d.cfg.Provider, Reason: reason, Since: since})
+}
+
+func (d *Dispatcher) broadcast(eventType string, data any) {
+	for _, t := range d.targets {
+		if t.wants(eventType) {
+			d.enqueue(t, eventType, data)
+		}
+	}
+}
+
+// enqueue queues an event for t without blocking; a full queue dead-letters it.
+func (d *Dispatcher) enqueue(t *target, eventType string, data any) {
+	event := Event{ID: newEventID(), Type: eventType, Created: time.Now(), Data: data}
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.logger.Error("encode webhook event error", "event", eventType, "error", err)
+		return
+	}
+
+	select {
+	case t.queue <- delivery{event, body}:
+	default:
+		d.deadLetter(t, delivery{event, body}, 0, fmt.Errorf("queue full"))
+	}
+}
+
+func (d *Dispatcher) work(ctx context.Context, t *target) {
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case del := <-t.queue:
+					d.deadLetter(t, del, 0, ctx.Err())
+				default:
+					return
+				}
+			}
+		case del := <-t.queue:
+			d.deliver(ctx, t, del)
+		}
+	}
+}
+
+// deliver tries del until it succeeds or MaxAttempts have failed, waiting
+// with exponential backoff between attempts.
+func (d *Dispatcher) deliver(ctx context.Context, t *target, del delivery) {
+	backoff := d.cfg.Backoff
+	var err error
+	for attempt := 1; attempt <= d.cfg.MaxAttempts; attempt++ {
+		start := time.Now()
+		if err = d.post(ctx, t, del); err == nil {
+			d.logger.Debug("webhook delivered", "endpoint", t.origin(), "event", del.event.Type,
+				"id", del.event.ID, "attempt", attempt, "latency", time.Since(start))
+			return
+		}
+		d.logger.Warn("webhook delivery failed", "endpoint", t.origin(), "event", del.event.Type,
+			"id", del.event.ID, "attempt", attempt, "error", err)
+		if attempt == d.cfg.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			d.deadLetter(t, del, attempt, ctx.Err())
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, d.cfg.MaxBackoff)
+	}
+	d.deadLetter(t, del, d.cfg.MaxAttempts, err)
+}
+
+// post sends one signed attempt; any status but 2xx fails it.
+func (d *Dispatcher) post(ctx context.Context, t *target, del delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(del.body))
+	if err != nil {
+		return errors.New("invalid endpoint URL")
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pricebot-webhook")
+	req.Header.Set(EventHeader, del.event.Type)
+	req.Header.Set(DeliveryHeader, del.event.ID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(t.Secret, timestamp, del.body))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// the error would repeat the URL into the logs and dead letters
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// deadLetter is one line of the dead-letter file.
+type deadLetter struct {
+	Time time.Time `json:"time"`
+	// Endpoint is the scheme and host of the URL, whose path and query may
+	// hold a token
+	Endpoint string          `json:"endpoint"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Event    json.RawMessage `json:"event"`
+}
+
+// deadLetter logs a delivery that will not be retried and appends it to
+// the dead-letter file, from where an operator can replay it.
+func (d *Dispatcher) deadLetter(t *target, del delivery, attempts int, err error) {
+	d.logger.Error("webhook dead-lettered", "endpoint", t.origin(), "event", del.event.Type,
+		"id", del.event.ID, "attempts", attempts, "error", err)
+	if d.cfg.DeadLetter == "" {
+		return
+	}
+
+	line, _ := json.Marshal(deadLetter{Time: time.Now(), Endpoint: t.origin(), Attempts: attempts, Error: err.Error(), Event: del.body})
+	d.deadMu.Lock()
+	defer d.deadMu.Unlock()
+
+	f, ferr := os.OpenFile(d.cfg.DeadLetter, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if ferr != nil {
+		d.logger.Error("open dead-letter file error", "file", d.cfg.DeadLetter, "error", ferr)
+		return
+	}
+	defer f.Close()
+	if _, ferr := f.Write(append(line, '\n')); ferr != nil {
+		d.logger.Error("write dead-letter file error", "file", d.cfg.DeadLetter, "error", ferr)
+	}
+}
@@ -0,0 +1,176 @@
+// Package webhook posts price events as signed JSON to endpoints operators
+// register, retrying failed deliveries and logging the ones that never
+// succeed to a dead-letter file.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/onionj/pricebot/price"
+)
+
+// Event types.
+const (
+	EventRefreshed         = "prices.refreshed"
+	EventChanged           = "price.changed"
+	EventProviderFailed    = "provider.failed"
+	EventProviderRecovered = "provider.recovered"
+)
+
+var eventTypes = []string{EventRefreshed, EventChanged, EventProviderFailed, EventProviderRecovered}
+
+// Headers of a delivery. The signature covers the timestamp and the body.
+const (
+	SignatureHeader = "X-Pricebot-Signature"
+	TimestampHeader = "X-Pricebot-Timestamp"
+	EventHeader     = "X-Pricebot-Event"
+	DeliveryHeader  = "X-Pricebot-Delivery"
+)
+
+// DefaultThreshold is the percent move that triggers price.changed.
+const DefaultThreshold = 1.0
+
+// Endpoint is a registered receiver of events.
+type Endpoint struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+	// Events it receives, all when empty
+	Events []string `json:"events,omitempty"`
+	// Assets whose price events it receives, all when empty
+	Assets []string `json:"assets,omitempty"`
+	// Threshold is the percent move since the last price.changed of an
+	// asset that triggers the next one, DefaultThreshold when zero
+	Threshold float64 `json:"threshold,omitempty"`
+}
+
+// origin returns the scheme and host of the URL, which is what gets logged:
+// the path and query may hold a token.
+func (e Endpoint) origin() string {
+	u, err := url.Parse(e.URL)
+	if err != nil || u.Host == "" {
+		return "invalid URL"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func (e Endpoint) wants(event string) bool {
+	return len(e.Events) == 0 || slices.Contains(e.Events, event)
+}
+
+func (e Endpoint) watches(asset string) bool {
+	return len(e.Assets) == 0 || slices.Contains(e.Assets, asset)
+}
+
+// LoadEndpoints reads a JSON list of endpoints from path.
+func LoadEndpoints(path string) ([]Endpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var endpoints []Endpoint
+	if err := json.Unmarshal(data, &endpoints); err != nil {
+		return nil, err
+	}
+
+	for i, e := range endpoints {
+		if u, err := url.Parse(e.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("endpoint %d: invalid url", i)
+		}
+		if e.Secret == "" {
+			return nil, fmt.Errorf("endpoint %d: missing secret", i)
+		}
+		for _, event := range e.Events {
+			if !slices.Contains(eventTypes, event) {
+				return nil, fmt.Errorf("endpoint %d: unknown event %q", i, event)
+			}
+		}
+		for _, key := range e.Assets {
+			if _, ok := price.FindAsset(key); !ok {
+				return nil, fmt.Errorf("endpoint %d: unknown asset %q", i, key)
+			}
+		}
+		if e.Threshold < 0 {
+			return nil, fmt.Errorf("endpoint %d: negative threshold", i)
+		}
+	}
+	return endpoints, nil
+}
+
+// Event is the JSON body of a delivery.
+type Event struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Created time.Time `json:"created"`
+	Data    any       `json:"data"`
+}
+
+// RefreshedData is the data of prices.refreshed: every accepted price.
+type RefreshedData struct {
+	At     time.Time                `json:"at"`
+	Prices map[string]price.Decimal `json:"prices"`
+}
+
+// ChangedData is the data of price.changed.
+type ChangedData struct {
+	Asset   string        `json:"asset"`
+	Name    string        `json:"name"`
+	Unit    price.Unit    `json:"unit"`
+	From    price.Decimal `json:"from"`
+	To      price.Decimal `json:"to"`
+	Percent float64       `json:"percent"`
+	Since   time.Time     `json:"since"`
+	At      time.Time     `json:"at"`
+}
+
+// ProviderData is the data of provider.failed and provider.recovered.
+type ProviderData struct {
+	Provider string    `json:"provider"`
+	Reason   string    `json:"reason"` // "refresh" or "stalled"
+	Error    string    `json:"error,omitempty"`
+	Since    time.Time `json:"since"`
+}
+
+func newEventID() string {
+	var b [12]byte
+	rand.Read(b[:])
+	return "evt_" + hex.EncodeToString(b[:])
+}
+
+// Sign returns the signature header of body sent at timestamp: the
+// hex HMAC-SHA256 of "<timestamp>.<body>" keyed by secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery as a receiver would, rejecting signatures that
+// don't match and timestamps further than tolerance from now, which stops
+// replays of old deliveries.
+func Verify(secret, timestamp, signature string, body []byte, now time.Time, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	if math.Abs(now.Sub(time.Unix(ts, 0)).Seconds()) > tolerance.Seconds() {
+		return errors.New("timestamp outside tolerance")
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, ts, body))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onionj/pricebot/price"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"type":"prices.refreshed"}`)
+	now := time.Unix(1700000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign("secret", now.Unix(), body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		now       time.Time
+		valid     bool
+	}{
+		{"valid", "secret", ts, string(body), now, true},
+		{"within tolerance", "secret", ts, string(body), now.Add(4 * time.Minute), true},
+		{"replayed", "secret", ts, string(body), now.Add(10 * time.Minute), false},
+		{"wrong secret", "other", ts, string(body), now, false},
+		{"tampered body", "secret", ts, `{"type":"price.changed"}`, now, false},
+		{"tampered timestamp", "secret", strconv.FormatInt(now.Unix()+1, 10), string(body), now, false},
+		{"invalid timestamp", "secret", "abc", string(body), now, false},
+	}
+
+	for _, tt := range tests {
+		err := Verify(tt.secret, tt.timestamp, signature, []byte(tt.body), tt.now, 5*time.Minute)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %v, got error %v", tt.name, tt.valid, err)
+		}
+	}
+}
+
+func TestLoadEndpoints(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		valid bool
+	}{
+		{"valid", `[{"url":"https://example.com/hook","secret":"s","events":["price.changed"],"assets":["usd"],"threshold":2}]`, true},
+		{"invalid url", `[{"url":"example.com","secret":"s"}]`, false},
+		{"missing secret", `[{"url":"https://example.com/hook"}]`, false},
+		{"unknown event", `[{"url":"https://example.com/hook","secret":"s","events":["price.moved"]}]`, false},
+		{"unknown asset", `[{"url":"https://example.com/hook","secret":"s","assets":["xyz"]}]`, false},
+		{"negative threshold", `[{"url":"https://example.com/hook","secret":"s","threshold":-1}]`, false},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "webhooks.json")
+		os.WriteFile(path, []byte(tt.data), 0600)
+		_, err := LoadEndpoints(path)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %v, got error %v", tt.name, tt.valid, err)
+		}
+	}
+}
+
+// receiver records the verified events posted to it, failing the first
+// fail requests with a 500.
+type receiver struct {
+	mu     sync.Mutex
+	fail   int
+	calls  int
+	events []Event
+	got    chan struct{}
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.calls <= r.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := Verify("secret", req.Header.Get(TimestampHeader), req.Header.Get(SignatureHeader), body, time.Now(), time.Minute); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var event Event
+	json.Unmarshal(body, &event)
+	if req.Header.Get(EventHeader) != event.Type || req.Header.Get(DeliveryHeader) != event.ID {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.events = append(r.events, event)
+	r.got <- struct{}{}
+}
+
+func testDispatcher(t *testing.T, endpoints []Endpoint) (*Dispatcher, context.CancelFunc) {
+	cfg := Config{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, QueueSize: 10,
+		DeadLetter: filepath.Join(t.TempDir(), "dead.jsonl")}
+	d := New(endpoints, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx, cancel := context.WithCancel(context.Background())
+	d.Start(ctx)
+	return d, cancel
+}
+
+func wait(t *testing.T, r *receiver, n int) {
+	for range n {
+		select {
+		case <-r.got:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected %d deliveries, timed out", n)
+		}
+	}
+}
+
+func TestDispatcher_Retry(t *testing.T) {
+	r := &receiver{fail: 2, got: make(chan struct{}, 10)}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	d, cancel := testDispatcher(t, []Endpoint{{URL: server.URL, Secret: "secret"}})
+	d.ProviderFailed("refresh", io.ErrUnexpectedEOF, time.Now())
+	d.ProviderFailed("refresh", io.ErrUnexpectedEOF, time.Now())
+	d.ProviderRecovered("refresh")
+	wait(t, r, 2)
+	cancel()
+	d.Wait()
+
+	if r.calls != 4 {
+		t.Errorf("Expected 4 requests, got %d", r.calls)
+	}
+	if len(r.events) != 2 || r.events[0].Type != EventProviderFailed || r.events[1].Type != EventProviderRecovered {
+		t.Errorf("Expected failed then recovered, got %+v", r.events)
+	}
+}
+
+func TestDispatcher_DeadLetter(t *testing.T) {
+	r := &receiver{fail: 100, got: make(chan struct{}, 10)}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	d, cancel := testDispatcher(t, []Endpoint{{URL: server.URL + "/hooks/token123", Secret: "secret"}})
+	d.ProviderFailed("stalled", io.ErrUnexpectedEOF, time.Now())
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if data, _ := os.ReadFile(d.cfg.DeadLetter); len(data) > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	d.Wait()
+
+	f, err := os.Open(d.cfg.DeadLetter)
+	if err != nil {
+		t.Fatalf("Expected dead-letter file, got %v", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		t.Fatal("Expected a dead letter")
+	}
+	var letter deadLetter
+	if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+		t.Fatalf("Expected valid dead letter, got %v", err)
+	}
+	var event Event
+	json.Unmarshal(letter.Event, &event)
+	if letter.Attempts != 3 || letter.Error != "status 500" || letter.Endpoint != server.URL || event.Type != EventProviderFailed {
+		t.Errorf("Expected 3 failed attempts of provider.failed, got %+v", letter)
+	}
+	if r.calls != 3 {
+		t.Errorf("Expected 3 requests, got %d", r.calls)
+	}
+}
+
+func TestDispatcher_DeadLetterUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	d, cancel := testDispatcher(t, []Endpoint{{URL: server.URL + "/hooks/token123", Secret: "secret"}})
+	d.ProviderFailed("stalled", io.ErrUnexpectedEOF, time.Now())
+	var data []byte
+	for deadline := time.Now().Add(2 * time.Second); len(data) == 0 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+		data, _ = os.ReadFile(d.cfg.DeadLetter)
+	}
+	cancel()
+	d.Wait()
+
+	if !strings.Contains(string(data), "refused") {
+		t.Errorf("Expected the transport error dead-lettered, got %s", data)
+	}
+	if strings.Contains(string(data), "token123") {
+		t.Errorf("Expected the URL path left out of the dead letter, got %s", data)
+	}
+}
+
+func TestDispatcher_Refreshed(t *testing.T) {
+	r := &receiver{got: make(chan struct{}, 10)}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	d, cancel := testDispatcher(t, []Endpoint{{URL: server.URL, Secret: "secret", Events: []string{EventChanged}, Assets: []string{"usd"}, Threshold: 2}})
+	start := time.Date(2025, 5, 6, 12, 0, 0, 0, time.UTC)
+	steps := []map[string]string{
+		{"usd": "80000", "eur": "90000"}, // baseline
+		{"usd": "81000", "eur": "99000"}, // +1.25%, eur not watched
+		{"usd": "81700", "eur": "90000"}, // +2.13% since the baseline
+		{"usd": "80500", "eur": "90000"}, // -1.47% since the last event
+		{"usd": "79000", "eur": "90000"}, // -3.30%
+	}
+	for i, step := range steps {
+		values := make(map[string]price.Decimal)
+		for key, value := range step {
+			values[key] = price.MustParseDecimal(value)
+		}
+		d.Refreshed(start.Add(time.Duration(i)*time.Minute), values)
+	}
+	wait(t, r, 2)
+	cancel()
+	d.Wait()
+
+	expected := []struct{ from, to string }{{"80000", "81700"}, {"81700", "79000"}}
+	if len(r.events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d", len(expected), len(r.events))
+	}
+	for i, e := range expected {
+		data, _ := json.Marshal(r.events[i].Data)
+		var changed ChangedData
+		json.Unmarshal(data, &changed)
+		if r.events[i].Type != EventChanged || changed.Asset != "usd" || changed.From.String() != e.from || changed.To.String() != e.to {
+			t.Errorf("Expected usd %s → %s, got %s %+v", e.from, e.to, r.events[i].Type, changed)
+		}
+	}
+}